@@ -0,0 +1,163 @@
+// Package bpnet is a client for the bahaiprayers.net API, which has the
+// prayers of the Bahá'í Faith in many languages. It doesn't depend on the
+// rest of the scraper, so other programs can use it directly:
+//
+//	client := &bpnet.Client{Middleware: []bpnet.Middleware{bpnet.Retry(3, time.Second)}}
+//	langs, err := client.Languages(ctx)
+//	...
+//	pr, err := client.PrayersByLanguage(ctx, bpnet.English)
+//
+// Failed requests return an *HTTPError, responses that can't be parsed a
+// *DecodeError, and responses the API flags as in error an *APIError, which
+// matches ErrAPIError. A Strict Client also fails responses with fields it
+// doesn't know with an *UnknownFieldsError. Queries that match no
+// language, or more than one, wrap ErrLanguageNotFound or
+// ErrAmbiguousLanguage.
+package bpnet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// BaseURL is where the prayers are scraped from
+const BaseURL = "https://bahaiprayers.net/api/prayer"
+
+// Client makes requests to the API. The zero value is ready to use.
+type Client struct {
+	// BaseURL is the address of the API, the public BaseURL when empty
+	BaseURL string
+	// HTTPClient makes the requests, http.DefaultClient when nil. Its
+	// Transport is what the Middleware wraps, so it can instrument, proxy or
+	// record the requests.
+	HTTPClient *http.Client
+	// Middleware wraps the transport of the requests, the first one
+	// outermost, e.g. to Retry or Cache them
+	Middleware []Middleware
+	// Strict makes responses with fields the client doesn't know fail with
+	// an *UnknownFieldsError, so changes to the API are noticed before they
+	// silently drop data
+	Strict bool
+}
+
+// DefaultClient is the Client the package level functions use
+var DefaultClient = &Client{}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+	return BaseURL
+}
+
+// httpClient returns HTTPClient with the Middleware around its transport
+func (c *Client) httpClient() *http.Client {
+	client := http.DefaultClient
+	if c.HTTPClient != nil {
+		client = c.HTTPClient
+	}
+	if len(c.Middleware) == 0 {
+		return client
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		transport = c.Middleware[i](transport)
+	}
+	wrapped := *client
+	wrapped.Transport = transport
+	return &wrapped
+}
+
+// get requests urlStr and decodes the JSON it responds with into v
+func (c *Client) get(ctx context.Context, urlStr string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError(resp)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return &DecodeError{URL: urlStr, Err: err}
+	}
+	if c.Strict {
+		return checkFields(urlStr, body, v)
+	}
+	return nil
+}
+
+// PrayersByLanguage retrieves all the prayers of the language with the id
+func (c *Client) PrayersByLanguage(ctx context.Context, languageID int) (*PrayersResponse, error) {
+	urlStr := fmt.Sprintf("%s/prayersystembylanguage?html=false&languageid=%d", c.baseURL(), languageID)
+	pr := PrayersResponse{}
+	if err := c.get(ctx, urlStr, &pr); err != nil {
+		return nil, fmt.Errorf("unable to retrieve prayers: %w", err)
+	}
+	if pr.IsInError {
+		return nil, fmt.Errorf("unable to retrieve prayers: %w", &APIError{ErrorMessage: pr.ErrorMessage, IsInError: true})
+	}
+	return &pr, nil
+}
+
+// Languages retrieves the languages the API has prayers in
+func (c *Client) Languages(ctx context.Context) ([]Language, error) {
+	var langs []Language
+	if err := c.get(ctx, c.baseURL()+"/languages", &langs); err != nil {
+		return nil, fmt.Errorf("unable to look up languages: %w", err)
+	}
+	return langs, nil
+}
+
+// LookUpLanguage retrieves the languages and resolves query among them, as
+// ResolveLanguage does
+func (c *Client) LookUpLanguage(ctx context.Context, query string) (*Language, error) {
+	langs, err := c.Languages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ResolveLanguage(langs, query)
+}
+
+// Prayers retrieves all the prayers of the language with the id, using
+// DefaultClient
+func Prayers(ctx context.Context, languageID int) (*PrayersResponse, error) {
+	return DefaultClient.PrayersByLanguage(ctx, languageID)
+}
+
+// Languages retrieves the languages the API has prayers in, using
+// DefaultClient
+func Languages(ctx context.Context) ([]Language, error) {
+	return DefaultClient.Languages(ctx)
+}
+
+// LookUpLanguage resolves query among the languages of the API, using
+// DefaultClient
+func LookUpLanguage(ctx context.Context, query string) (*Language, error) {
+	return DefaultClient.LookUpLanguage(ctx, query)
+}
+
+// newHTTPError describes an unsuccessful response, along with its body
+func newHTTPError(resp *http.Response) error {
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: err.Error()}
+	}
+	return &HTTPError{StatusCode: resp.StatusCode, Body: string(buf)}
+}
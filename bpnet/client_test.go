@@ -0,0 +1,186 @@
+package bpnet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// testAPI serves body with status for every request, recording the query
+// of the last one
+func testAPI(t *testing.T, status int, body string) (*httptest.Server, *string) {
+	query := new(string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*query = r.URL.Path + "?" + r.URL.RawQuery
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, query
+}
+
+func TestClientLanguages(t *testing.T) {
+	srv, query := testAPI(t, http.StatusOK, `[{"id":1,"Name":"English","English":"English","Culture":"en","IsLeftToRight":true,"PrayerCount":300}]`)
+	langs, err := (&Client{BaseURL: srv.URL}).Languages(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Language{{ID: 1, Name: "English", EnglishName: "English", ISOName: "en", LeftToRight: true, PrayerCount: 300}}
+	if !reflect.DeepEqual(langs, want) {
+		t.Errorf("the languages are %+v, want %+v", langs, want)
+	}
+	if *query != "/languages?" {
+		t.Errorf("requested %s, want /languages", *query)
+	}
+}
+
+func TestClientPrayersByLanguage(t *testing.T) {
+	srv, query := testAPI(t, http.StatusOK, `{"Version":3,"Prayers":[{"Id":5,"AuthorId":2,"LanguageId":7,"Text":"Ô Dieu !","Tags":[{"Id":10,"Name":"Général","Kind":"GENERAL"}]}]}`)
+	pr, err := (&Client{BaseURL: srv.URL}).PrayersByLanguage(context.Background(), French)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PrayersResponse{Version: 3, Prayers: []Prayer{{ID: 5, AuthorID: 2, LanguageID: 7, Text: "Ô Dieu !", Tags: []Tag{{ID: 10, Name: "Général", Kind: "GENERAL"}}}}}
+	if !reflect.DeepEqual(pr, want) {
+		t.Errorf("the response is %+v, want %+v", pr, want)
+	}
+	if want := "/prayersystembylanguage?html=false&languageid=7"; *query != want {
+		t.Errorf("requested %s, want %s", *query, want)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	cases := []struct {
+		name, body string
+		status     int
+		strict     bool
+		check      func(t *testing.T, err error)
+	}{
+		{"HTTP error", "down for maintenance", http.StatusServiceUnavailable, false, func(t *testing.T, err error) {
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable || httpErr.Body != "down for maintenance" {
+				t.Errorf("the error is %v, want an *HTTPError of the status and body", err)
+			}
+		}},
+		{"malformed JSON", `{"Prayers":`, http.StatusOK, false, func(t *testing.T, err error) {
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Errorf("the error is %v, want a *DecodeError", err)
+			}
+		}},
+		{"in error", `{"IsInError":true,"ErrorMessage":"no such language"}`, http.StatusOK, false, func(t *testing.T, err error) {
+			var apiErr *APIError
+			if !errors.Is(err, ErrAPIError) || !errors.As(err, &apiErr) || apiErr.ErrorMessage != "no such language" {
+				t.Errorf("the error is %v, want an *APIError saying why", err)
+			}
+		}},
+		{"unknown fields", `{"Version":1,"Prayers":[{"Id":1,"Audio":"a.mp3"},{"Id":2,"Audio":"b.mp3","Tags":[{"Id":1,"Color":"red"}]}],"Total":2}`, http.StatusOK, true, func(t *testing.T, err error) {
+			var fieldsErr *UnknownFieldsError
+			if !errors.As(err, &fieldsErr) {
+				t.Fatalf("the error is %v, want an *UnknownFieldsError", err)
+			}
+			if want := []string{"Prayers[].Audio", "Prayers[].Tags[].Color", "Total"}; !reflect.DeepEqual(fieldsErr.Fields, want) {
+				t.Errorf("the unknown fields are %q, want %q", fieldsErr.Fields, want)
+			}
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv, _ := testAPI(t, c.status, c.body)
+			_, err := (&Client{BaseURL: srv.URL, Strict: c.strict}).PrayersByLanguage(context.Background(), English)
+			if err == nil {
+				t.Fatal("the request succeeded, want an error")
+			}
+			c.check(t, err)
+		})
+	}
+}
+
+func TestClientUnknownFieldsWithoutStrict(t *testing.T) {
+	srv, _ := testAPI(t, http.StatusOK, `{"Version":1,"Prayers":[{"Id":1,"Audio":"a.mp3"}]}`)
+	if _, err := (&Client{BaseURL: srv.URL}).PrayersByLanguage(context.Background(), English); err != nil {
+		t.Errorf("a response with unknown fields failed without Strict: %v", err)
+	}
+}
+
+func TestClientMiddleware(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, "server "+r.Header.Get("X-Order"))
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	// the transport of HTTPClient is innermost, under the middleware in the
+	// order they're listed
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("X-Order", req.Header.Get("X-Order")+name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		seen = append(seen, "transport "+req.Header.Get("X-Order"))
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	c := &Client{BaseURL: srv.URL, HTTPClient: &http.Client{Transport: transport}, Middleware: []Middleware{mark("a"), mark("b")}}
+	if _, err := c.Languages(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"transport ab", "server ab"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("the request went through %q, want %q", seen, want)
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	langs := []Language{
+		{ID: 1, Name: "English", EnglishName: "English", ISOName: "en"},
+		{ID: 5, Name: "فارسى", EnglishName: "Persian", ISOName: "fa"},
+		{ID: 8, Name: "Português", EnglishName: "Portuguese", ISOName: "pt"},
+		{ID: 9, Name: "简体中文", EnglishName: "Chinese Simplified", ISOName: "zh-Hans"},
+		{ID: 30, Name: "繁體中文", EnglishName: "Chinese Traditional", ISOName: "zh-Hant"},
+	}
+	cases := []struct {
+		name, query string
+		// id is the ID of the language found, or 0 when err is
+		id  int
+		err error
+	}{
+		{"id", "5", 5, nil},
+		{"code", "en", 1, nil},
+		{"code in caps", "EN", 1, nil},
+		{"English name", "persian", 5, nil},
+		{"native name", "Português", 8, nil},
+		{"alias", "farsi", 5, nil},
+		{"three letter code", "por", 8, nil},
+		{"region", "pt_BR", 8, nil},
+		{"script", "zh-hant", 30, nil},
+		{"script and region", "zh-Hant-TW", 30, nil},
+		{"ambiguous", "zh", 0, ErrAmbiguousLanguage},
+		{"unknown id", "99", 0, ErrLanguageNotFound},
+		{"unknown code", "de", 0, ErrLanguageNotFound},
+		{"not a language", "klingon!", 0, ErrLanguageNotFound},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l, err := ResolveLanguage(langs, c.query)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Errorf("ResolveLanguage(%q) = %v, want %v", c.query, err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveLanguage(%q) failed: %v", c.query, err)
+			}
+			if l.ID != c.id {
+				t.Errorf("ResolveLanguage(%q) = %d, want %d", c.query, l.ID, c.id)
+			}
+		})
+	}
+}
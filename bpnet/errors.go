@@ -0,0 +1,75 @@
+package bpnet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrLanguageNotFound is returned when no language matches a query
+var ErrLanguageNotFound = errors.New("language not found")
+
+// ErrAmbiguousLanguage is returned when more than one language matches a
+// query
+var ErrAmbiguousLanguage = errors.New("language is ambiguous")
+
+// ErrAPIError matches every *APIError with errors.Is, for callers that only
+// care that the API refused a request
+var ErrAPIError = errors.New("the API reported an error")
+
+// HTTPError is returned when the API responds with a status other than 200
+type HTTPError struct {
+	StatusCode int
+	// Body is what the API responded with, which usually says what's wrong
+	Body string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http code %d - %s", e.StatusCode, e.Body)
+}
+
+// DecodeError is returned when a response of the API isn't the JSON it
+// should be
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error parsing the response of %s: %v", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// APIError is returned when the API responds with 200, but flags the
+// response as in error, saying why in its ErrorMessage
+type APIError struct {
+	ErrorMessage string
+	IsInError    bool
+}
+
+func (e *APIError) Error() string {
+	if e.ErrorMessage == "" {
+		return "the API reported an error"
+	}
+	return "the API reported an error - " + e.ErrorMessage
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrAPIError
+}
+
+// UnknownFieldsError is returned by a Strict Client when a response of the
+// API has fields the client doesn't know, which usually means the API added
+// or renamed some. What the client knows was decoded all the same.
+type UnknownFieldsError struct {
+	URL string
+	// Fields are the paths of the unknown fields, like Prayers[].Audio
+	Fields []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return fmt.Sprintf("the response of %s has unknown fields: %s", e.URL, strings.Join(e.Fields, ", "))
+}
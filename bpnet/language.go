@@ -0,0 +1,186 @@
+package bpnet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Language ids
+const (
+	English    int = 1
+	Icelandic      = 2
+	German         = 3
+	Spanish        = 4
+	Persian        = 5
+	Arabic         = 6
+	French         = 7
+	Portuguese     = 8
+	Chinese        = 9
+	Italian        = 10
+	Dutch          = 11
+	Romanian       = 12
+	Latvian        = 13
+	Belarusian     = 14
+	Russian        = 15
+	Hungarian      = 16
+	Albanian       = 17
+	Czech          = 18
+	Japanese       = 19
+	Afrikaans      = 20
+	Korean         = 21
+	Bulgarian      = 22
+)
+
+// Language is a language the API has prayers in
+type Language struct {
+	ID          int `json:"id"`
+	Name        string
+	EnglishName string `json:"English"`
+	ISOName     string `json:"Culture"`
+	LeftToRight bool   `json:"IsLeftToRight"`
+	PrayerCount int
+	// Source names where the prayers of the language come from when it's
+	// not the API, like a directory of text files or another API
+	Source string `json:",omitempty"`
+}
+
+// DefaultSource is the source of the languages of the API
+const DefaultSource = "bahaiprayers.net"
+
+// SourceName returns the Source of the language, or DefaultSource
+func (l Language) SourceName() string {
+	if l.Source != "" {
+		return l.Source
+	}
+	return DefaultSource
+}
+
+// rightToLeft lists the languages of the corpus written right to left
+var rightToLeft = map[string]bool{"ar": true, "fa": true, "he": true, "ur": true}
+
+// RightToLeft reports whether the language with the ISO code is written
+// right to left, for when only its code is known
+func RightToLeft(isoName string) bool {
+	return rightToLeft[isoName]
+}
+
+// languageAliases maps alternative names and codes for a language to the code
+// we'd expect the API to use as its Culture
+var languageAliases = map[string]string{
+	"farsi":   "fa",
+	"per":     "fa",
+	"fas":     "fa",
+	"zh-cn":   "zh",
+	"zh-hans": "zh",
+	"chi":     "zh",
+	"zho":     "zh",
+	"pt-br":   "pt",
+	"pt-pt":   "pt",
+	"por":     "pt",
+	"ger":     "de",
+	"deu":     "de",
+	"fre":     "fr",
+	"fra":     "fr",
+	"ice":     "is",
+	"isl":     "is",
+	"dut":     "nl",
+	"nld":     "nl",
+	"cze":     "cs",
+	"ces":     "cs",
+	"alb":     "sq",
+	"sqi":     "sq",
+	"rum":     "ro",
+	"ron":     "ro",
+}
+
+// ResolveLanguage finds the language that query refers to. The query can be
+// the API's language id, its Culture code, its (English) name, or any of the
+// common aliases and variants of the code. When a query matches more than one
+// language, the candidates are listed in the error so the user can pick.
+func ResolveLanguage(langs []Language, query string) (*Language, error) {
+	if id, err := strconv.Atoi(query); err == nil {
+		for _, l := range langs {
+			if l.ID == id {
+				return &l, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %d", ErrLanguageNotFound, id)
+	}
+
+	q := strings.ToLower(strings.TrimSpace(query))
+	q = strings.Replace(q, "_", "-", -1)
+	for _, l := range langs {
+		if strings.ToLower(l.ISOName) == q || strings.ToLower(l.EnglishName) == q || strings.ToLower(l.Name) == q {
+			return &l, nil
+		}
+	}
+
+	// keep the tag as typed, since its script or region can tell variants apart
+	queryTag, _ := language.Parse(q)
+	if alias, ok := languageAliases[q]; ok {
+		for _, l := range langs {
+			if strings.ToLower(l.ISOName) == alias {
+				return &l, nil
+			}
+		}
+		q = alias
+	}
+
+	// fall back to comparing the base languages, so pt-BR finds pt and vice versa
+	tag, err := language.Parse(q)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrLanguageNotFound, query)
+	}
+	base, _ := tag.Base()
+	var matches []Language
+	for _, l := range langs {
+		lTag, err := language.Parse(l.ISOName)
+		if err != nil {
+			continue
+		}
+		if lBase, _ := lTag.Base(); lBase == base {
+			matches = append(matches, l)
+		}
+	}
+	if len(matches) > 1 {
+		matches = narrowLanguageMatches(matches, queryTag)
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: '%s'", ErrLanguageNotFound, query)
+	case 1:
+		return &matches[0], nil
+	default:
+		var candidates []string
+		for _, m := range matches {
+			candidates = append(candidates, fmt.Sprintf("%s (%d, %s)", m.ISOName, m.ID, m.EnglishName))
+		}
+		return nil, fmt.Errorf("%w: '%s' could be %s", ErrAmbiguousLanguage, query, strings.Join(candidates, ", "))
+	}
+}
+
+// narrowLanguageMatches keeps the matches whose script and region agree with
+// the ones spelled out in tag (e.g. zh-Hant or pt-BR). If that would leave
+// nothing, the matches are returned unchanged.
+func narrowLanguageMatches(matches []Language, tag language.Tag) []Language {
+	script, scriptConf := tag.Script()
+	region, regionConf := tag.Region()
+	var narrowed []Language
+	for _, m := range matches {
+		mTag, _ := language.Parse(m.ISOName)
+		if mScript, _ := mTag.Script(); scriptConf == language.Exact && mScript != script {
+			continue
+		}
+		if mRegion, _ := mTag.Region(); regionConf == language.Exact && mRegion != region {
+			continue
+		}
+		narrowed = append(narrowed, m)
+	}
+	if len(narrowed) == 0 {
+		return matches
+	}
+	return narrowed
+}
@@ -0,0 +1,192 @@
+package bpnet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Middleware wraps the transport of a Client's requests, to add behavior
+// around them
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Retry makes up to attempts tries of a request that fails with a network
+// error, a 5xx status or 429 Too Many Requests. It waits backoff before the
+// first retry, and twice as long as the last time before each one after.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			wait := backoff
+			for attempt := 1; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				if attempt >= attempts || !retryable(resp, err) {
+					return resp, err
+				}
+				if resp != nil {
+					ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+				}
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+				wait *= 2
+			}
+		})
+	}
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// CachedHeader is set on the responses Cache and DiskCache answer with
+// themselves, so the Middleware outside them can tell those from the API's
+const CachedHeader = "X-From-Cache"
+
+// cachedResponse is a successful response kept by Cache
+type cachedResponse struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+// Cache keeps the successful responses to GET requests in memory for ttl,
+// answering the same requests from it meanwhile. The cache belongs to the
+// Middleware, so Clients sharing it share their responses.
+func Cache(ttl time.Duration) Middleware {
+	var mu sync.Mutex
+	cache := make(map[string]cachedResponse)
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet {
+				return next.RoundTrip(req)
+			}
+			key := req.URL.String()
+			mu.Lock()
+			cached, ok := cache[key]
+			mu.Unlock()
+			if ok && time.Since(cached.at) < ttl {
+				header := cached.header.Clone()
+				header.Set(CachedHeader, "1")
+				return &http.Response{
+					Status:        "200 OK",
+					StatusCode:    http.StatusOK,
+					Proto:         "HTTP/1.1",
+					ProtoMajor:    1,
+					ProtoMinor:    1,
+					Header:        header,
+					Body:          ioutil.NopCloser(bytes.NewReader(cached.body)),
+					ContentLength: int64(len(cached.body)),
+					Request:       req,
+				}, nil
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				return resp, err
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			cache[key] = cachedResponse{header: resp.Header.Clone(), body: body, at: time.Now()}
+			mu.Unlock()
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			return resp, nil
+		})
+	}
+}
+
+// RateLimit spaces the requests at least every apart, so a scrape doesn't
+// hammer the API
+func RateLimit(every time.Duration) Middleware {
+	var mu sync.Mutex
+	var next time.Time
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			now := time.Now()
+			wait := next.Sub(now)
+			if wait < 0 {
+				wait = 0
+			}
+			next = now.Add(wait + every)
+			mu.Unlock()
+
+			if wait > 0 {
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+			}
+			return rt.RoundTrip(req)
+		})
+	}
+}
+
+// DiskCache is Cache with the responses kept as files in dir, so they last
+// from one run to the next. A ttl of 0 keeps them until they're deleted.
+func DiskCache(dir string, ttl time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet {
+				return next.RoundTrip(req)
+			}
+			sum := sha256.Sum256([]byte(req.URL.String()))
+			path := filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+			if info, err := os.Stat(path); err == nil && (ttl == 0 || time.Since(info.ModTime()) < ttl) {
+				if body, err := ioutil.ReadFile(path); err == nil {
+					return &http.Response{
+						Status:        "200 OK",
+						StatusCode:    http.StatusOK,
+						Proto:         "HTTP/1.1",
+						ProtoMajor:    1,
+						ProtoMinor:    1,
+						Header:        http.Header{"Content-Type": {"application/json"}, CachedHeader: {"1"}},
+						Body:          ioutil.NopCloser(bytes.NewReader(body)),
+						ContentLength: int64(len(body)),
+						Request:       req,
+					}, nil
+				}
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				return resp, err
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+			if err := ioutil.WriteFile(path, body, 0644); err != nil {
+				return nil, err
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			return resp, nil
+		})
+	}
+}
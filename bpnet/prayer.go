@@ -0,0 +1,50 @@
+package bpnet
+
+import "golang.org/x/text/unicode/norm"
+
+// PrayersResponse is the API's response listing the prayers of a language
+type PrayersResponse struct {
+	ErrorMessage string
+	IsInError    bool
+	Version      int
+	Prayers      []Prayer
+}
+
+// Tag is a tag of a prayer. The first tag of a prayer decides its category.
+type Tag struct {
+	ID   int `json:"Id"`
+	Name string
+	Kind string
+}
+
+// The kinds of tags
+const (
+	TagKindGeneral     string = "GENERAL"
+	TagKindOccassional        = "OCCASSIONAL"
+	TagKindTablets            = "TABLETS"
+	TagKindObligatory         = "OBLIGATORY"
+)
+
+// Prayer is a prayer as the API sends it. Its text is marked up with the
+// paragraph markers the markup package parses.
+type Prayer struct {
+	ID           int `json:"Id"`
+	AuthorID     int `json:"AuthorId"`
+	LanguageID   int `json:"LanguageId"`
+	Text         string
+	FirstTagName string `json:"FirstTagName"`
+	Tags         []Tag
+	Title        string
+}
+
+// Normalize converts the text of the prayer to Unicode NFC. The API mixes
+// precomposed and decomposed characters (sometimes within the same prayer),
+// which breaks string matching, searching and word counts.
+func (p *Prayer) Normalize() {
+	p.Text = norm.NFC.String(p.Text)
+	p.Title = norm.NFC.String(p.Title)
+	p.FirstTagName = norm.NFC.String(p.FirstTagName)
+	for j := range p.Tags {
+		p.Tags[j].Name = norm.NFC.String(p.Tags[j].Name)
+	}
+}
@@ -0,0 +1,86 @@
+package bpnet
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// unknownFields lists the fields of the JSON object(s) in raw that no field
+// of t decodes, as dotted paths from prefix. Like encoding/json, the names
+// are matched case insensitively.
+func unknownFields(raw interface{}, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var unknown []string
+	switch v := raw.(type) {
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return nil
+		}
+		// the elements share their fields, so each one is only reported once
+		seen := make(map[string]bool)
+		for _, elem := range v {
+			for _, f := range unknownFields(elem, t.Elem(), prefix+"[]") {
+				if !seen[f] {
+					seen[f] = true
+					unknown = append(unknown, f)
+				}
+			}
+		}
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		fields := jsonFields(t)
+		for name, value := range v {
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+			field, ok := fields[strings.ToLower(name)]
+			if !ok {
+				unknown = append(unknown, path)
+				continue
+			}
+			unknown = append(unknown, unknownFields(value, field.Type, path)...)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// jsonFields maps the lowercased JSON names of the exported fields of t to
+// the fields
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fields[strings.ToLower(name)] = f
+	}
+	return fields
+}
+
+// checkFields returns an *UnknownFieldsError when the JSON in data has
+// fields that v doesn't decode
+func checkFields(urlStr string, data []byte, v interface{}) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return &DecodeError{URL: urlStr, Err: err}
+	}
+	if fields := unknownFields(raw, reflect.TypeOf(v), ""); len(fields) > 0 {
+		return &UnknownFieldsError{URL: urlStr, Fields: fields}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The layout of the assets directory the Android app is built with. The
+// app opens the database from the databases directory, and reads the index
+// to show what's in it before opening it.
+const (
+	androidDatabase  = "databases/prayers.db"
+	androidIndex     = "prayers-index.json"
+	androidChecksums = "SHA256SUMS"
+)
+
+// androidIndexFile is the layout of prayers-index.json
+type androidIndexFile struct {
+	Database       string            `json:"database"`
+	SchemaVersion  int               `json:"schemaVersion"`
+	ScraperVersion string            `json:"scraperVersion"`
+	ScrapeDate     string            `json:"scrapeDate"`
+	Languages      []androidLanguage `json:"languages"`
+}
+
+type androidLanguage struct {
+	manifestLanguage
+	Categories []androidCategory `json:"categories"`
+}
+
+type androidCategory struct {
+	Name        string `json:"name"`
+	PrayerCount int    `json:"prayerCount"`
+}
+
+// generateAndroidAssets lays merged.db out in dir the way the Android app's
+// build expects its assets: the database, an index of its languages and
+// categories, and the checksums of both in the format sha256sum checks
+func generateAndroidAssets(dir string) {
+	if _, err := os.Stat("merged.db"); err != nil {
+		log.Fatalf("Nothing to generate assets from: %v", err)
+	}
+
+	fmt.Printf("Generating Android assets in %s... ", dir)
+	index := androidIndexFile{Database: androidDatabase, ScraperVersion: version()}
+	var languages []manifestLanguage
+	index.SchemaVersion, languages, index.ScrapeDate = describeMerged("merged.db")
+	categories, err := androidCategories("merged.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, l := range languages {
+		index.Languages = append(index.Languages, androidLanguage{manifestLanguage: l, Categories: categories[l.Language]})
+	}
+
+	dbPath := filepath.Join(dir, filepath.FromSlash(androidDatabase))
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := copyFile("merged.db", dbPath); err != nil {
+		log.Fatal(err)
+	}
+	buf, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, androidIndex), append(buf, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	sums := strings.Builder{}
+	for _, name := range []string{androidDatabase, androidIndex} {
+		file, err := describeFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", file.SHA256, name)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, androidChecksums), []byte(sums.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("DONE!\n")
+}
+
+// androidCategories counts the prayers of each category of each language of
+// a merged database, in the order the app lists the categories
+func androidCategories(path string) (map[string][]androidCategory, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT language, category, count(*) FROM prayers GROUP BY language, category ORDER BY language, category`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[string][]androidCategory)
+	for rows.Next() {
+		var lang string
+		var c androidCategory
+		if err := rows.Scan(&lang, &c.Name, &c.PrayerCount); err != nil {
+			return nil, err
+		}
+		categories[lang] = append(categories[lang], c)
+	}
+	return categories, rows.Err()
+}
+
+// copyFile copies the file at src to dst, replacing dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// appendMerge makes -merge add to the existing merged.db instead of
+// rebuilding it. Every language in the databases being merged replaces any
+// rows the merged database already has for it.
+var appendMerge = false
+
+// appendedOwners checks that merged.db can be appended to, and returns the
+// IDs of the prayers that will stay in it, for checkIDCollisions
+func appendedOwners(ctx context.Context, merged *sql.DB, dbPaths []string) map[int]string {
+	var version int
+	err := merged.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if version != prayerdb.MergedSchemaVersion {
+		log.Fatalf("merged.db has schema version %d, but %d is needed. Rebuild it without -append.", version, prayerdb.MergedSchemaVersion)
+	}
+
+	replaced := make(map[string]bool)
+	for _, dbPath := range dbPaths {
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		langs, err := prayerdb.Languages(ctx, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, lang := range langs {
+			replaced[lang] = true
+		}
+		db.Close()
+	}
+
+	rows, err := merged.QueryContext(ctx, `SELECT id, language FROM prayers`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	owners := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var lang string
+		if err := rows.Scan(&id, &lang); err != nil {
+			log.Fatal(err)
+		}
+		if !replaced[lang] {
+			owners[id] = "merged.db"
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	return owners
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// changelog collects what a scrape changes, when -changelog is on
+var changelog *prayerdb.Changelog
+
+// writeChangelog writes the changes of the scrape as CHANGELOG.md, for the
+// release notes of the apps, and as CHANGELOG.json
+func writeChangelog(c *prayerdb.Changelog) {
+	buf, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("CHANGELOG.json", append(buf, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	var md strings.Builder
+	md.WriteString("# Changelog\n")
+	changed := false
+	for _, lang := range c.Languages {
+		if lang.Empty() {
+			continue
+		}
+		changed = true
+		fmt.Fprintf(&md, "\n## %s\n", lang.Language)
+		changelogSection(&md, "Added", lang.Added)
+		changelogSection(&md, "Changed", lang.Changed)
+		changelogSection(&md, "Removed", lang.Removed)
+	}
+	if !changed {
+		md.WriteString("\nNo prayers were added, changed or removed.\n")
+	}
+	if err := ioutil.WriteFile("CHANGELOG.md", []byte(md.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Wrote CHANGELOG.md and CHANGELOG.json")
+}
+
+// changelogSection lists prayers under a heading, unless there are none
+func changelogSection(md *strings.Builder, heading string, prayers []prayerdb.ChangedPrayer) {
+	if len(prayers) == 0 {
+		return
+	}
+	fmt.Fprintf(md, "\n### %s\n\n", heading)
+	for _, p := range prayers {
+		fmt.Fprintf(md, "- %s: %s (%d)\n", p.Category, p.OpeningWords, p.ID)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/server"
+)
+
+// artifactPatterns match the artifacts the commands write to the current
+// directory: the databases, release packages and deltas
+var artifactPatterns = []string{"*.db", "bpnet-prayers-*.zip", "bpnet-prayers-*.delta.json.gz"}
+
+// checksumExt is added to the name of an artifact for its checksum, in the
+// format of sha256sum, so sha256sum -c checks it
+const checksumExt = server.ChecksumExt
+
+// manifestPath is where writeChecksums lists the artifacts
+const manifestPath = "manifest.json"
+
+// writeChecksums writes a checksum next to every artifact in the current
+// directory, and lists them all with their sizes and checksums in
+// manifest.json, along with the languages of merged.db when there's one.
+// The scrape, merge and package commands call it when they're done.
+func writeChecksums() error {
+	var paths []string
+	for _, pattern := range artifactPatterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	m := manifest{ScraperVersion: version(), ScrapeDate: strings.SplitN(dbOptions.ScrapeTime, "T", 2)[0], Languages: []manifestLanguage{}, Files: []manifestFile{}}
+	if _, err := os.Stat("merged.db"); err == nil {
+		// an encrypted merged.db can't be described without its key, so its
+		// manifest goes without the languages
+		if version, languages, date, err := readMerged("merged.db"); err == nil {
+			m.SchemaVersion, m.Languages, m.ScrapeDate = version, languages, date
+		}
+	}
+	for _, path := range paths {
+		file, err := describeFile(path)
+		if err != nil {
+			return err
+		}
+		sum := file.SHA256 + "  " + path + "\n"
+		if err := ioutil.WriteFile(path+checksumExt, []byte(sum), 0644); err != nil {
+			return err
+		}
+		m.Files = append(m.Files, file)
+	}
+
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(manifestPath, append(buf, '\n'), 0644); err != nil {
+		return err
+	}
+	return signFile(manifestPath)
+}
+
+// checksumArtifacts is writeChecksums for the commands
+func checksumArtifacts() {
+	if err := writeChecksums(); err != nil {
+		log.Fatalf("Unable to write the checksums: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// coveragePath is where -coverage writes its report
+const coveragePath = "coverage.md"
+
+// generateCoverage writes a matrix of how many prayers each language of
+// merged.db has in each English category, next to the English counts, and
+// lists the categories each language is missing altogether, to prioritize
+// translation outreach
+func generateCoverage(ctx context.Context, counterparts map[string]map[int]int) {
+	if _, err := os.Stat("merged.db"); err != nil {
+		log.Fatalf("Nothing to report on: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "merged.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	coverage, err := prayerdb.CategoryCoverage(ctx, db, counterparts)
+	if err != nil {
+		log.Fatalf("Unable to compute the category coverage: %v", err)
+	}
+	if len(coverage.Categories) == 0 {
+		log.Fatal("merged.db has no English prayers to compare with")
+	}
+
+	var md strings.Builder
+	md.WriteString("# Category coverage\n\n")
+	md.WriteString("Prayers of each language in the categories of the English prayers. Categories a language has no prayers in are in bold.\n\n")
+	md.WriteString("| Category | en |")
+	for _, lc := range coverage.Languages {
+		fmt.Fprintf(&md, " %s |", lc.Language)
+	}
+	md.WriteString("\n|---|---:|")
+	md.WriteString(strings.Repeat("---:|", len(coverage.Languages)))
+	md.WriteString("\n")
+	for _, c := range coverage.Categories {
+		fmt.Fprintf(&md, "| %s | %d |", markdownCell(c), coverage.English.Counts[c])
+		for _, lc := range coverage.Languages {
+			if n := lc.Counts[c]; n > 0 {
+				fmt.Fprintf(&md, " %d |", n)
+			} else {
+				md.WriteString(" **0** |")
+			}
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString("\n## Missing categories\n\n")
+	complete := true
+	for _, lc := range coverage.Languages {
+		missing := lc.Missing(coverage.Categories)
+		if len(missing) == 0 {
+			continue
+		}
+		complete = false
+		fmt.Fprintf(&md, "- %s (%d of %d): %s\n", lc.Language, len(missing), len(coverage.Categories), strings.Join(missing, ", "))
+	}
+	if complete {
+		md.WriteString("Every language has prayers in every English category.\n")
+	}
+
+	md.WriteString("\n## Unmatched categories\n\n")
+	md.WriteString("Categories that couldn't be matched with an English one; naming counterparts in the config matches them.\n\n")
+	matched := true
+	for _, lc := range coverage.Languages {
+		var unmatched []string
+		for c := range lc.Unmatched {
+			unmatched = append(unmatched, c)
+		}
+		sort.Strings(unmatched)
+		for _, c := range unmatched {
+			matched = false
+			fmt.Fprintf(&md, "- %s: %s (%d)\n", lc.Language, markdownCell(c), lc.Unmatched[c])
+		}
+	}
+	if matched {
+		md.WriteString("None.\n")
+	}
+
+	if err := ioutil.WriteFile(coveragePath, []byte(md.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %s\n", coveragePath)
+}
+
+// markdownCell escapes the pipes that would end a cell of a table
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// writeDelta writes the rows that changed from the merged database at
+// fromPath to merged.db as a gzipped JSON delta, named after the dates of
+// the scrapes in the two, for the app to update its copy of the older one
+// with. It returns the delta's name.
+func writeDelta(ctx context.Context, fromPath string) string {
+	for _, path := range []string{fromPath, "merged.db"} {
+		if _, err := os.Stat(path); err != nil {
+			log.Fatalf("Nothing to compare: %v", err)
+		}
+	}
+	_, _, fromDate := describeMerged(fromPath)
+	_, _, toDate := describeMerged("merged.db")
+
+	from, err := sql.Open("sqlite3", fromPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer from.Close()
+	to, err := sql.Open("sqlite3", "merged.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer to.Close()
+
+	delta, err := prayerdb.DiffMerged(ctx, from, to)
+	if err != nil {
+		log.Fatalf("Unable to compare %s with merged.db: %v", fromPath, err)
+	}
+
+	name := fmt.Sprintf("bpnet-prayers-%s-to-%s.delta.json.gz", fromDate, toDate)
+	out, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+	zw := gzip.NewWriter(out)
+	if err := json.NewEncoder(zw).Encode(delta); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+	signArtifact(name)
+	checksumArtifacts()
+
+	if delta.Empty() {
+		fmt.Println("No rows changed")
+	}
+	for _, t := range delta.Tables {
+		fmt.Printf("%s: %d added, %d changed, %d removed\n", t.Table, len(t.Added), len(t.Changed), len(t.Removed))
+	}
+	return name
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"log/slog"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// duplicateMode is one of the prayerdb.Duplicates constants, deciding what
+// -merge does with prayers whose text duplicates another one of the same
+// language
+var duplicateMode = prayerdb.DuplicatesReport
+
+// checkIDCollisions makes sure no two of the databases to merge share a
+// prayer ID, since the ID is the primary key of the merged database. Every
+// collision is reported along with the files it's in before giving up.
+// owners holds the IDs already taken by rows that are staying, if any.
+func checkIDCollisions(dbPaths []string, owners map[int]string) {
+	if owners == nil {
+		owners = make(map[int]string)
+	}
+	collisions := 0
+	for _, dbPath := range dbPaths {
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rows, err := db.Query(`SELECT id FROM prayers WHERE deleted=0`)
+		if err != nil {
+			log.Fatalf("Unable to read the prayer IDs of %s: %v", dbPath, err)
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				log.Fatal(err)
+			}
+			if owner, ok := owners[id]; ok {
+				slog.Error("prayer ID collision", "phase", "merge", "prayer", id, "db", owner, "otherDB", dbPath)
+				collisions++
+				continue
+			}
+			owners[id] = dbPath
+		}
+		if err := rows.Err(); err != nil {
+			log.Fatal(err)
+		}
+		rows.Close()
+		db.Close()
+	}
+
+	if collisions > 0 {
+		log.Fatalf("%d prayer IDs collide between the databases to merge", collisions)
+	}
+}
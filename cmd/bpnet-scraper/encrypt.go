@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// encryptOutput makes -merge encrypt merged.db with SQLCipher, using the
+// key in the dbKeyEnv environment variable. It needs a build with
+// -tags sqlcipher.
+var encryptOutput = false
+
+// dbKeyEnv names the environment variable holding the encryption key, so
+// the key never shows up in the command line or shell history
+const dbKeyEnv = "BPNET_DB_KEY"
+
+// encryptionKey returns the key to encrypt with, failing if there's none
+func encryptionKey() string {
+	key := os.Getenv(dbKeyEnv)
+	if key == "" {
+		log.Fatalf("Encrypting needs a key in $%s", dbKeyEnv)
+	}
+	return key
+}
+
+// checkEncryption fails early, before any work is done, if -encrypt can't
+// work: without a key, or without SQLCipher linked in
+func checkEncryption() {
+	encryptionKey()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	var cipherVersion string
+	err = db.QueryRow(`PRAGMA cipher_version`).Scan(&cipherVersion)
+	if err != nil || cipherVersion == "" {
+		log.Fatal("This build doesn't have SQLCipher; build with -tags sqlcipher to encrypt")
+	}
+}
+
+// encryptDB replaces the plain database at path with a SQLCipher encrypted
+// copy, exported through an attached encrypted database
+func encryptDB(path string) {
+	key := encryptionKey()
+	encryptedPath := path + ".encrypted"
+	os.Remove(encryptedPath)
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	quotedKey := strings.Replace(key, "'", "''", -1)
+	statements := []string{
+		fmt.Sprintf(`ATTACH DATABASE '%s' AS encrypted KEY '%s'`, strings.Replace(encryptedPath, "'", "''", -1), quotedKey),
+		`SELECT sqlcipher_export('encrypted')`,
+		`DETACH DATABASE encrypted`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			os.Remove(encryptedPath)
+			log.Fatalf("Unable to encrypt %s: %v", path, err)
+		}
+	}
+	db.Close()
+
+	if err := os.Rename(encryptedPath, path); err != nil {
+		log.Fatal(err)
+	}
+}
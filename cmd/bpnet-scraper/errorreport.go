@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/scraper"
+)
+
+// errorReportURL gets the errors the scraper logs, so failures of unattended
+// runs are noticed. errorReportFormat is sentry, for a Sentry DSN, or json
+// to post them as they are; when it's empty, it's picked by the URL.
+var (
+	errorReportURL    = ""
+	errorReportFormat = ""
+)
+
+// maxErrorReports is how many errors a run reports at most, so a run that
+// fails the same way over and over doesn't flood the reports
+const maxErrorReports = 20
+
+// errorReport is an error the scraper logged, with where it happened. It's
+// the payload of the json format.
+type errorReport struct {
+	Message        string `json:"message"`
+	Error          string `json:"error,omitempty"`
+	Phase          string `json:"phase,omitempty"`
+	Language       string `json:"language,omitempty"`
+	Prayer         int    `json:"prayer,omitempty"`
+	Source         string `json:"source,omitempty"`
+	Time           string `json:"time"`
+	Host           string `json:"host,omitempty"`
+	ScraperVersion string `json:"scraperVersion"`
+}
+
+// errorSink sends error reports somewhere
+type errorSink interface {
+	send(ctx context.Context, report errorReport) error
+}
+
+// newErrorSink returns the sink of errorReportURL in errorReportFormat
+func newErrorSink() (errorSink, error) {
+	u, err := url.Parse(errorReportURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("errors can only be reported to an http or https URL, not '%s'", errorReportURL)
+	}
+	if errorReportFormat == "" {
+		errorReportFormat = "json"
+		// a DSN has the project's key as the user, and its ID as the last
+		// element of the path
+		project := u.Path[strings.LastIndex(u.Path, "/")+1:]
+		if _, err := strconv.Atoi(project); err == nil && u.User != nil {
+			errorReportFormat = "sentry"
+		}
+	}
+	switch errorReportFormat {
+	case "json":
+		return jsonErrorSink{url: errorReportURL}, nil
+	case "sentry":
+		return newSentrySink(u)
+	}
+	return nil, fmt.Errorf("unknown error report format '%s'", errorReportFormat)
+}
+
+// jsonErrorSink posts the reports as JSON
+type jsonErrorSink struct {
+	url string
+}
+
+func (s jsonErrorSink) send(ctx context.Context, report errorReport) error {
+	return postJSON(ctx, s.url, nil, report)
+}
+
+// sentrySink sends the reports as events to a Sentry project
+type sentrySink struct {
+	storeURL string
+	auth     string
+}
+
+// newSentrySink reads a Sentry DSN, https://<key>@<host>/<project>
+func newSentrySink(dsn *url.URL) (sentrySink, error) {
+	if dsn.User == nil || dsn.User.Username() == "" {
+		return sentrySink{}, errors.New("the Sentry DSN has no key")
+	}
+	i := strings.LastIndex(dsn.Path, "/")
+	project := dsn.Path[i+1:]
+	if project == "" {
+		return sentrySink{}, errors.New("the Sentry DSN has no project")
+	}
+	store := url.URL{Scheme: dsn.Scheme, Host: dsn.Host, Path: dsn.Path[:i] + "/api/" + project + "/store/"}
+	auth := "Sentry sentry_version=7, sentry_client=bpnet-scraper/" + version() + ", sentry_key=" + dsn.User.Username()
+	if secret, ok := dsn.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	return sentrySink{storeURL: store.String(), auth: auth}, nil
+}
+
+func (s sentrySink) send(ctx context.Context, report errorReport) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	tags := map[string]string{}
+	if report.Phase != "" {
+		tags["phase"] = report.Phase
+	}
+	if report.Language != "" {
+		tags["language"] = report.Language
+	}
+	extra := map[string]interface{}{}
+	if report.Prayer != 0 {
+		extra["prayer"] = report.Prayer
+	}
+	if report.Source != "" {
+		extra["source"] = report.Source
+	}
+	message := report.Message
+	if report.Error != "" {
+		message += ": " + report.Error
+	}
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   report.Time,
+		"level":       "error",
+		"platform":    "go",
+		"logger":      "bpnet-scraper",
+		"release":     report.ScraperVersion,
+		"server_name": report.Host,
+		"message":     message,
+		"tags":        tags,
+		"extra":       extra,
+	}
+	return postJSON(ctx, s.storeURL, map[string]string{"X-Sentry-Auth": s.auth}, event)
+}
+
+// postJSON posts v as JSON to urlStr, failing unless it's accepted
+func postJSON(ctx context.Context, urlStr string, header map[string]string, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// errorReporter passes log records on to its handler, reporting the errors
+// to its sink. They're reported before Handle returns, since log.Fatal exits
+// right after.
+type errorReporter struct {
+	slog.Handler
+	sink  errorSink
+	attrs []slog.Attr
+}
+
+// errorReports counts the errors reported, up to maxErrorReports
+var errorReports struct {
+	sync.Mutex
+	sent int
+}
+
+func (e errorReporter) Handle(ctx context.Context, r slog.Record) error {
+	err := e.Handler.Handle(ctx, r)
+	if r.Level < slog.LevelError {
+		return err
+	}
+	errorReports.Lock()
+	if errorReports.sent == maxErrorReports {
+		errorReports.Unlock()
+		return err
+	}
+	errorReports.sent++
+	errorReports.Unlock()
+
+	report := errorReport{Message: r.Message, Time: r.Time.UTC().Format(time.RFC3339), ScraperVersion: version()}
+	report.Host, _ = os.Hostname()
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		report.Source = frame.File + ":" + strconv.Itoa(frame.Line)
+	}
+	eachAttr(e.attrs, r, func(a slog.Attr) {
+		switch a.Key {
+		case "error":
+			report.Error = a.Value.String()
+		case "phase":
+			report.Phase = a.Value.String()
+		case "language":
+			report.Language = a.Value.String()
+		case "prayer":
+			if a.Value.Kind() == slog.KindInt64 {
+				report.Prayer = int(a.Value.Int64())
+			}
+		}
+	})
+
+	sendCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if sendErr := e.sink.send(sendCtx, report); sendErr != nil {
+		// to stderr rather than the log, so it isn't reported in turn
+		fmt.Fprintf(os.Stderr, "unable to report the error: %v\n", sendErr)
+	}
+	return err
+}
+
+func (e errorReporter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return errorReporter{Handler: e.Handler.WithAttrs(attrs), sink: e.sink, attrs: append(e.attrs[:len(e.attrs):len(e.attrs)], attrs...)}
+}
+
+func (e errorReporter) WithGroup(name string) slog.Handler {
+	return errorReporter{Handler: e.Handler.WithGroup(name), sink: e.sink, attrs: e.attrs}
+}
+
+// scrapeFailed logs the error a scrape failed with, along with the phase,
+// language and prayer it failed at, and exits
+func scrapeFailed(err error) {
+	args := []interface{}{"error", err}
+	var failure *scraper.Failure
+	if errors.As(err, &failure) {
+		args = append(args, "phase", failure.Phase, "language", failure.Language)
+		if failure.Prayer != 0 {
+			args = append(args, "prayer", failure.Prayer)
+		}
+	}
+	slog.Error("the scrape failed", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"os"
+
+	"arashpayan.com/bpnet-scraper/export"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+	"arashpayan.com/bpnet-scraper/prayerpb"
+	"arashpayan.com/bpnet-scraper/scraper"
+)
+
+// The formats -export can write: the prepackaged databases of the app's
+// platforms, a property list for older iOS tooling, JSON, JSON Lines and XML
+// files for everyone else, protocol buffers for services with generated
+// types, spreadsheets, SQL dumps, and an Anki deck for memorizing prayers
+const (
+	exportRoom     = "room"
+	exportCoreData = "coredata"
+	exportJSON     = "json"
+	exportXML      = "xml"
+	exportJSONL    = "jsonl"
+	exportAnkiDeck = "anki"
+	exportPlist    = "plist"
+	exportProto    = "protobuf"
+	exportCSV      = "csv"
+	exportTSV      = "tsv"
+	exportSQL      = "sql"
+)
+
+// Settings of the app's own schema that an export has to match, since they
+// can't be derived from merged.db. The Room identity hash and version come
+// from the app's exported Room schema JSON; the Core Data metadata is the
+// plist of a store created by the app, holding its model's version hashes.
+var (
+	roomVersion      = 1
+	roomIdentityHash = ""
+	coreDataMetadata = ""
+)
+
+// csvColumns are the columns of merged.db a CSV or TSV export has, in order.
+// The defaults are the ones a proofreader needs.
+var csvColumns = export.DefaultColumns
+
+// sqlDialect is the dialect of -export sql
+var sqlDialect = export.DialectSQLite
+
+// The fonts -pdf typesets with. Only TrueType fonts are supported, and for
+// Arabic script they need the Arabic presentation forms. Without an italic
+// font, italics are slanted versions of the regular one.
+var (
+	pdfFontPath       = ""
+	pdfItalicFontPath = ""
+)
+
+// latexFont is the font -latex sets the prayers in with XeLaTeX or LuaLaTeX,
+// by the name fontconfig knows it by
+var latexFont string
+
+// exportDB writes the prayers of merged.db to prayers.<format>, with the
+// schema of the XML and protobuf exports next to them
+func exportDB(format string) {
+	var columns []string
+	switch format {
+	case exportRoom, exportJSON, exportXML, exportJSONL, exportAnkiDeck, exportPlist, exportProto:
+	case exportCoreData:
+		if coreDataMetadata == "" {
+			log.Fatal("Exporting for Core Data needs -coredata-metadata")
+		}
+	case exportCSV, exportTSV:
+		var err error
+		if columns, err = export.ParseColumns(csvColumns); err != nil {
+			log.Fatalf("Invalid -columns: %v", err)
+		}
+	case exportSQL:
+		if sqlDialect != export.DialectSQLite && sqlDialect != export.DialectPostgres && sqlDialect != export.DialectMySQL {
+			log.Fatalf("Unknown SQL dialect '%s'", sqlDialect)
+		}
+	default:
+		log.Fatalf("Unknown export format '%s'", format)
+	}
+	if _, err := os.Stat("merged.db"); err != nil {
+		log.Fatalf("Nothing to export: %v", err)
+	}
+
+	switch format {
+	case exportRoom:
+		const path = "prayers.room.db"
+		if roomIdentityHash == "" {
+			slog.Warn("no -room-identity-hash; Room will validate the exported schema column by column", "phase", "export")
+		}
+		os.Remove(path)
+		fmt.Printf("Exporting to %s... ", path)
+		if err := export.Room(path, "merged.db", roomVersion, roomIdentityHash); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print("DONE!\n")
+		return
+	case exportCoreData:
+		const path = "prayers.coredata.sqlite"
+		metadata, err := ioutil.ReadFile(coreDataMetadata)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Remove(path)
+		fmt.Printf("Exporting to %s... ", path)
+		if err := export.CoreData(path, "merged.db", metadata); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print("DONE!\n")
+		return
+	case exportAnkiDeck:
+		writeExport("prayers.apkg", func(w io.Writer) error {
+			return export.Anki("merged.db", w)
+		})
+		return
+	}
+
+	db, err := sql.Open("sqlite3", "merged.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	switch format {
+	case exportJSON:
+		writeExport("prayers.json", func(w io.Writer) error {
+			return export.JSON(db, w)
+		})
+	case exportJSONL:
+		writeExport("prayers.jsonl", func(w io.Writer) error {
+			return export.JSONLines(db, w)
+		})
+	case exportXML:
+		writeExport("prayers.xml", func(w io.Writer) error {
+			if err := export.XML(db, w); err != nil {
+				return err
+			}
+			return ioutil.WriteFile("prayers.xsd", []byte(export.XMLSchema), 0644)
+		})
+	case exportPlist:
+		writeExport("prayers.plist", func(w io.Writer) error {
+			return export.Plist(db, w)
+		})
+	case exportProto:
+		writeExport("prayers.pb", func(w io.Writer) error {
+			if err := export.Protobuf(db, w); err != nil {
+				return err
+			}
+			return ioutil.WriteFile("prayers.proto", []byte(prayerpb.Schema), 0644)
+		})
+	case exportCSV, exportTSV:
+		comma := ','
+		if format == exportTSV {
+			comma = '\t'
+		}
+		writeExport("prayers."+format, func(w io.Writer) error {
+			return export.CSV(db, w, columns, comma)
+		})
+	case exportSQL:
+		writeExport("prayers.sql", func(w io.Writer) error {
+			return export.SQL(db, w, sqlDialect, dbOptions)
+		})
+	}
+}
+
+// writeExport writes the file at path with write, reporting its progress
+func writeExport(path string, write func(w io.Writer) error) {
+	fmt.Printf("Exporting to %s... ", path)
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("DONE!\n")
+}
+
+// openBook opens the database of lang to typeset
+func openBook(s *scraper.Scraper, lang string) (export.Book, func()) {
+	dbPath := lang + ".db"
+	if _, err := os.Stat(dbPath); err != nil {
+		log.Fatalf("Nothing to typeset: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := prayerdb.CheckSchema(context.Background(), dbPath, db); err != nil {
+		log.Fatal(err)
+	}
+	return export.Book{Scraper: s, DB: db, Language: lang, ScraperVersion: version()}, func() { db.Close() }
+}
+
+// generatePDF typesets the prayers of the database of lang as
+// prayers-<lang>.pdf
+func generatePDF(s *scraper.Scraper, lang string) {
+	if pdfFontPath == "" {
+		log.Fatal("Typesetting a PDF needs a TrueType font; pass one with -pdf-font")
+	}
+	book, closeBook := openBook(s, lang)
+	defer closeBook()
+	regular, err := export.LoadFont(pdfFontPath)
+	if err != nil {
+		log.Fatalf("Unable to load the PDF font: %v", err)
+	}
+	var italic *export.Font
+	if pdfItalicFontPath != "" {
+		if italic, err = export.LoadFont(pdfItalicFontPath); err != nil {
+			log.Fatalf("Unable to load the PDF italic font: %v", err)
+		}
+	}
+
+	path := fmt.Sprintf("prayers-%s.pdf", lang)
+	fmt.Printf("Typesetting %s... ", path)
+	var pdf bytes.Buffer
+	if err := book.PDF(&pdf, regular, italic); err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, pdf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("DONE!\n")
+}
+
+// generateLaTeX writes the prayers of the database of lang as a LaTeX book,
+// prayers-<lang>.tex
+func generateLaTeX(s *scraper.Scraper, lang string) {
+	book, closeBook := openBook(s, lang)
+	defer closeBook()
+	var tex bytes.Buffer
+	if err := book.LaTeX(&tex, latexFont); errors.Is(err, export.ErrNoScriptFont) {
+		log.Fatalf("Typesetting '%s' needs a font for its script; pass one with -latex-font", lang)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	path := fmt.Sprintf("prayers-%s.tex", lang)
+	fmt.Printf("Writing %s... ", path)
+	if err := ioutil.WriteFile(path, tex.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("DONE!\n")
+}
+
+// generateSite renders the prayers of merged.db into a static website in dir
+func generateSite(dir string) {
+	if _, err := os.Stat("merged.db"); err != nil {
+		log.Fatalf("Nothing to generate a site from: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "merged.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	fmt.Printf("Generating the site in %s... ", dir)
+	if err := export.Site(db, dir); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("DONE!\n")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dbList collects database paths from a flag that can be repeated, and
+// whose values can be comma separated lists, globs or directories
+type dbList []string
+
+func (l *dbList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *dbList) Set(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		if path != "" {
+			*l = append(*l, path)
+		}
+	}
+	return nil
+}
+
+// sqliteHeader starts every SQLite database file
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// expandDBPaths turns the paths, globs and directories given on the command
+// line into the list of databases to work on. Globs and directories expand
+// in lexical order, and each database is only listed once. Anything that
+// isn't an SQLite database is skipped with a warning, as is the merged
+// database itself, since a directory of language databases usually has an
+// old one lying around.
+func expandDBPaths(args []string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		var matches []string
+		info, err := os.Stat(arg)
+		switch {
+		case err == nil && info.IsDir():
+			entries, err := ioutil.ReadDir(arg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					matches = append(matches, filepath.Join(arg, entry.Name()))
+				}
+			}
+		case err != nil && strings.ContainsAny(arg, "*?["):
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				log.Fatalf("Bad pattern '%s': %v", arg, err)
+			}
+			if len(matches) == 0 {
+				slog.Warn("pattern doesn't match any files", "pattern", arg)
+			}
+		default:
+			matches = []string{arg}
+		}
+
+		for _, path := range matches {
+			path = filepath.Clean(path)
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			if filepath.Base(path) == "merged.db" {
+				slog.Warn("skipping the output of -merge", "db", path)
+				continue
+			}
+			if !isSQLite(path) {
+				slog.Warn("skipping a file that isn't an SQLite database", "db", path)
+				continue
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// isSQLite checks whether the file at path starts with the SQLite header
+func isSQLite(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, sqliteHeader)
+}
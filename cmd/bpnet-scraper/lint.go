@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// lintDB reports the content issues of the prayers in a per-language or
+// merged database, failing when there are any
+func lintDB(ctx context.Context, dbPath string) {
+	if _, err := os.Stat(dbPath); err != nil {
+		log.Fatalf("Nothing to lint: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	issues, err := prayerdb.LintContent(ctx, db)
+	if err != nil {
+		log.Fatalf("Unable to lint %s: %v", dbPath, err)
+	}
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		db.Close()
+		log.Fatalf("%s has %d content issues", dbPath, len(issues))
+	}
+	fmt.Printf("%s has no content issues\n", dbPath)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+)
+
+// logFormat is how log messages are written to stderr: text or json
+var logFormat = "text"
+
+// setUpLogging makes slog's default logger write in logFormat. Whatever
+// still goes through the log package, log.Fatal mainly, is logged as an
+// error. The warnings are kept for the runs table, and the summaries of the
+// webhook and the metrics file, and with errorReportURL the errors are
+// reported.
+func setUpLogging() error {
+	var handler slog.Handler
+	options := &slog.HandlerOptions{AddSource: true}
+	switch logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("unknown log format '%s'", logFormat)
+	}
+	handler = warningRecorder{Handler: handler}
+	if errorReportURL != "" {
+		sink, err := newErrorSink()
+		if err != nil {
+			return err
+		}
+		handler = errorReporter{Handler: handler, sink: sink}
+	}
+	slog.SetDefault(slog.New(handler))
+	log.SetFlags(0)
+	log.SetOutput(slog.NewLogLogger(handler, slog.LevelError).Writer())
+	return nil
+}
+
+// eachAttr calls f with the attributes a handler was given with WithAttrs,
+// then those of the record
+func eachAttr(attrs []slog.Attr, r slog.Record, f func(a slog.Attr)) {
+	for _, a := range attrs {
+		f(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		f(a)
+		return true
+	})
+}
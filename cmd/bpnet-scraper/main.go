@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+	"arashpayan.com/bpnet-scraper/scraper"
+)
+
+// scraperVersion identifies the build of the scraper that produced a
+// database. Release builds set it with -ldflags "-X main.scraperVersion=...".
+var scraperVersion = ""
+
+// version returns scraperVersion, falling back to the module version the
+// binary was built from
+func version() string {
+	if scraperVersion != "" {
+		return scraperVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// dbOptions stamp the databases this run writes with its time and version,
+// and size the transactions that fill them
+var dbOptions = prayerdb.NewOptions()
+
+func main() {
+	langToScrape := flag.String("language", "", "Language to scrape, as an id, ISO code or name")
+	scrapeAllLangs := flag.Bool("all", false, "Scrape every language, recording the ones the API refuses in "+refusedPath)
+	var mergeDBsList, migrateDBsList dbList
+	flag.Var(&mergeDBsList, "merge", "Db files to merge, as comma separated paths, globs or directories (repeatable)")
+	pdfLanguage := flag.String("pdf", "", "Typeset the prayers of a language's database, by ISO code, into a PDF")
+	flag.StringVar(&pdfFontPath, "pdf-font", "", "TrueType font to typeset -pdf with")
+	flag.StringVar(&pdfItalicFontPath, "pdf-italic-font", "", "TrueType font for the italics of -pdf (default: slanted -pdf-font)")
+	latexLanguage := flag.String("latex", "", "Write the prayers of a language's database, by ISO code, as a LaTeX book")
+	flag.StringVar(&latexFont, "latex-font", "", "Font to set -latex in with XeLaTeX or LuaLaTeX (needed for right to left languages)")
+	opds := flag.Bool("opds", false, "Generate an OPDS catalog of the -pdf prayer books in the current directory")
+	androidDir := flag.String("android-assets", "", "Lay out merged.db with an index and checksums in this Android assets directory")
+	siteDir := flag.String("site", "", "Generate a static website of merged.db in this directory")
+	packageMerged := flag.Bool("package", false, "Zip merged.db with a manifest into a release artifact")
+	deltaFrom := flag.String("delta", "", "Write the rows that changed from this earlier merged database to merged.db, for the app to update its copy with")
+	flag.StringVar(&webhookURL, "webhook", "", "Post a summary of the counts and warnings to this URL when a scrape or merge finishes")
+	flag.StringVar(&webhookFormat, "webhook-format", "", "Payload of -webhook: slack, discord or json (default: by the URL's host)")
+	flag.StringVar(&signingKeyPath, "signing-key", "", "Sign the databases, packages, deltas and manifests written with the Ed25519 private key in this PEM file, into <file>.sig")
+	verifyKey := flag.String("verify", "", "Check the files given as arguments, or every file with a .sig, against their signatures with the Ed25519 public key in this PEM file")
+	flag.StringVar(&errorReportURL, "report-errors", "", "Report the errors of a run to this Sentry DSN, or post them as JSON to this URL")
+	flag.StringVar(&errorReportFormat, "report-errors-format", "", "Format of -report-errors: sentry or json (default: sentry for a DSN)")
+	flag.StringVar(&metricsFile, "metrics-file", "", "Write the metrics of a scrape to this file in Prometheus' text format when it finishes")
+	serveAddr := flag.String("serve", "", "Serve the databases and an API over merged.db on this address, like :8080, rescraping every language on -schedule")
+	flag.StringVar(&serveSchedule, "schedule", serveSchedule, "When -serve rescrapes: hourly, daily, weekly, monthly, an interval like 12h, or 5 cron fields")
+	uploadBucket := flag.String("upload", "", "Upload the language databases, merged.db and a manifest to this s3://bucket/prefix or gs://bucket/prefix, with keys in $"+accessKeyEnv+" and $"+secretKeyEnv)
+	flag.StringVar(&uploadEndpoint, "upload-endpoint", "", "Address of an S3 compatible store for -upload, instead of S3 or GCS")
+	flag.StringVar(&uploadRegion, "upload-region", "", "Region of the -upload bucket (default $"+regionEnv+", or us-east-1)")
+	publishRepo := flag.String("publish", "", "Package merged.db and publish it, with the files given as arguments, as a release of this owner/name GitHub repository, with a token in $"+githubTokenEnv)
+	flag.StringVar(&releaseTag, "release-tag", "", "Tag of the -publish release (default prayers-<date of the scrape>)")
+	flag.StringVar(&githubAPI, "github-api", githubAPI, "Address of the GitHub API for -publish, for GitHub Enterprise")
+	exportFormat := flag.String("export", "", "Export merged.db for the app's prepackaged database import (room, coredata or plist), as json, jsonl, xml, protobuf, csv, tsv or sql, or as an anki deck")
+	flag.StringVar(&sqlDialect, "sql-dialect", sqlDialect, "Dialect of -export sql (sqlite, postgres or mysql)")
+	flag.StringVar(&csvColumns, "columns", csvColumns, "Comma separated columns of merged.db to include, for -export csv or tsv")
+	flag.IntVar(&roomVersion, "room-version", roomVersion, "Database version of the app's Room schema, for -export room")
+	flag.StringVar(&roomIdentityHash, "room-identity-hash", "", "Identity hash of the app's Room schema, for -export room")
+	flag.StringVar(&coreDataMetadata, "coredata-metadata", "", "Store metadata plist of the app's Core Data model, for -export coredata")
+	flag.Var(&migrateDBsList, "migrate", "Db files to upgrade to the current schema, as comma separated paths, globs or directories (repeatable)")
+	lintPath := flag.String("lint", "", "Report content issues of the prayers in this per-language or merged database")
+	nearDuplicatesPath := flag.String("near-duplicates", "", "Report the prayers of this per-language or merged database whose texts are nearly the same")
+	flag.Float64Var(&similarityThreshold, "similarity", similarityThreshold, "How alike, from 0 to 1, prayers have to be for -near-duplicates")
+	coverage := flag.Bool("coverage", false, "Report how many prayers each language of merged.db has in each English category, in "+coveragePath)
+	verifySearchPath := flag.String("verify-search", "", "Check the word counts and search text of this per-language or merged database against its prayers")
+	reportPath := flag.String("report", "", "Write statistics of the prayers in this per-language or merged database for reviewers")
+	flag.StringVar(&reportFormat, "report-format", reportFormat, "Format of -report (markdown or html)")
+	runSelfTest := flag.Bool("selftest", false, "Check the markup of a corpus of tricky prayers against their golden files")
+	flag.StringVar(&goldenDir, "golden-dir", "", "Corpus for -selftest and -update-golden (default: the built in one)")
+	updateGoldenFiles := flag.Bool("update-golden", false, "Rewrite the golden files in -golden-dir with the current markup")
+	configPath := flag.String("config", "", "Path to a JSON config file")
+	templatesDir := flag.String("templates", "", "Directory with templates overriding the default markup")
+	markupFormat := flag.String("markup", scraper.MarkupHTML, "Format to mark up prayers in (html or markdown)")
+	typography := flag.Bool("typography", false, "Clean up quotes, ellipses, dashes and spacing before markup")
+	lintMarkers := flag.Bool("lint-markers", false, "Report malformed paragraph markers in the prayers")
+	repairMarkers := flag.Bool("repair-markers", false, "Repair malformed paragraph markers in the prayers (implies -lint-markers)")
+	translationsPath := flag.String("translations", "", "JSON file of category names by language, overriding the built in ones")
+	flag.StringVar(&duplicateMode, "duplicates", prayerdb.DuplicatesReport, "What to do with duplicate prayers when merging (report, skip or link)")
+	flag.StringVar(&sourceKind, "source", sourceKind, "Where -language reads the prayers from (api, json, db or text)")
+	flag.IntVar(&apiRetries, "retries", apiRetries, "Number of times to try a failed request to the API")
+	flag.StringVar(&apiBaseURL, "api", "", "Base URL of the bahaiprayers.net API (default "+bpnet.BaseURL+")")
+	flag.StringVar(&apiCacheDir, "cache-dir", "", "Keep the API's responses in this directory, and reuse them on later runs")
+	flag.DurationVar(&apiRateLimit, "rate-limit", 0, "Minimum time between requests to the API")
+	flag.BoolVar(&apiStrict, "strict-api", false, "Fail when the API responds with fields the scraper doesn't know")
+	flag.StringVar(&sourceDir, "source-dir", sourceDir, "Directory with the JSON files, databases or text files of -source json, db or text")
+	flag.StringVar(&extraSources, "extra-sources", "", "Comma separated sources of the languages -source doesn't have, like text:<dir> or api:<base URL>")
+	postgresDSN := flag.String("postgres", "", "Also store scraped prayers in the Postgres database with this connection string")
+	jsonDir := flag.String("json", "", "Also write scraped prayers as JSON to this directory, for -source json")
+	flag.BoolVar(&encryptOutput, "encrypt", false, "Encrypt merged.db with SQLCipher, using the key in $"+dbKeyEnv)
+	flag.BoolVar(&appendMerge, "append", false, "Merge into the existing merged.db, replacing the languages being merged")
+	flag.IntVar(&mergeReaders, "merge-readers", mergeReaders, "Number of databases -merge reads ahead while it writes merged.db")
+	flag.BoolVar(&fullTextIndex, "fts", fullTextIndex, "Give merged.db an FTS5 full-text index, which needs a build with -tags sqlite_fts5 (-fts=false merges without)")
+	writeChangelogs := flag.Bool("changelog", false, "Write CHANGELOG.md and CHANGELOG.json listing the prayers the scrape added, changed and removed")
+	flag.BoolVar(&updateDB, "update", false, "Update the existing database of the language instead of replacing it")
+	strictAuthors := flag.Bool("strict-authors", false, "Fail when a prayer's author has no name in its language")
+	strictHTML := flag.Bool("strict-html", false, "Fail when the HTML generated for a prayer is invalid")
+	flag.IntVar(&dbOptions.BatchSize, "batch-size", dbOptions.BatchSize, "Number of rows inserted per transaction")
+	openingLength := flag.Int("opening-length", scraper.DefaultOpeningLength, "Maximum length of opening words, in characters")
+	workers := flag.Int("workers", runtime.GOMAXPROCS(0), "Number of prayers to mark up at once")
+	flag.StringVar(&logFormat, "log-format", logFormat, "Format of the log messages on stderr (text or json)")
+	flag.Parse()
+
+	if err := setUpLogging(); err != nil {
+		log.Fatal(err)
+	}
+
+	if duplicateMode != prayerdb.DuplicatesReport && duplicateMode != prayerdb.DuplicatesSkip && duplicateMode != prayerdb.DuplicatesLink {
+		log.Fatalf("Unknown duplicates mode '%s'", duplicateMode)
+	}
+	if dbOptions.BatchSize < 1 {
+		log.Fatalf("Invalid batch size %d", dbOptions.BatchSize)
+	}
+	if webhookURL != "" {
+		if err := checkWebhook(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	dbOptions.ScraperVersion = version()
+	if err := loadSigningKey(); err != nil {
+		log.Fatal(err)
+	}
+
+	if encryptOutput {
+		checkEncryption()
+	}
+	if *writeChangelogs {
+		// the changes are found by comparing with the database before the
+		// SQLite sink replaces it
+		changelog = &prayerdb.Changelog{}
+		sinks = append(sinks, prayerdb.ChangelogSink{Changelog: changelog, Update: updateDB})
+	}
+	sinks = append(sinks, prayerdb.SQLiteSink{Update: updateDB, Options: dbOptions})
+	sinks = append(sinks, runSink{})
+	if signingKey != nil {
+		sinks = append(sinks, signingSink{})
+	}
+	if *postgresDSN != "" {
+		sinks = append(sinks, prayerdb.PostgresSink{DSN: *postgresDSN, Options: dbOptions})
+	}
+	if *jsonDir != "" {
+		sinks = append(sinks, prayerdb.JSONSink{Dir: *jsonDir})
+	}
+	if webhookURL != "" || metricsFile != "" {
+		sinks = append(sinks, summarySink{})
+	}
+
+	opts := []scraper.Option{
+		scraper.WithSinks(sinks...),
+		scraper.WithMiddleware(countRequests),
+		scraper.WithTemplates(*templatesDir),
+		scraper.WithMarkupFormat(*markupFormat),
+		scraper.WithOpeningLength(*openingLength),
+		scraper.WithWorkers(*workers),
+		scraper.WithTypography(*typography),
+		scraper.WithMarkerLint(*lintMarkers, *repairMarkers),
+		scraper.WithStrictHTML(*strictHTML),
+		scraper.WithStrictAuthors(*strictAuthors),
+	}
+	if *configPath != "" {
+		config, err := scraper.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Unable to load config: %v", err)
+		}
+		opts = append(opts, scraper.WithConfig(config))
+	}
+	if *translationsPath != "" {
+		translations, err := loadTranslations(*translationsPath)
+		if err != nil {
+			log.Fatalf("Unable to load translations: %v", err)
+		}
+		opts = append(opts, scraper.WithTranslations(translations))
+	}
+	sourceOpts, err := sourceOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := scraper.New(append(opts, sourceOpts...)...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// an interrupt cancels the scrape or merge in progress, instead of
+	// leaving a half written database behind
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		signal.Stop(interrupts)
+		cancel()
+	}()
+
+	started := time.Now()
+	if *langToScrape != "" {
+		if err := s.Scrape(ctx, *langToScrape); err != nil {
+			scrapeFailed(err)
+		}
+		if changelog != nil {
+			writeChangelog(changelog)
+		}
+		checksumArtifacts()
+		writeScrapeMetrics(started, nil)
+		notifyWebhook(ctx, "scrape", nil, nil)
+	} else if *scrapeAllLangs {
+		refused, failures := scrapeAll(ctx, s)
+		if changelog != nil {
+			writeChangelog(changelog)
+		}
+		checksumArtifacts()
+		writeScrapeMetrics(started, refused)
+		notifyWebhook(ctx, "scrape", nil, refused)
+		failedLanguages(failures)
+	} else if len(mergeDBsList) > 0 {
+		// a shell expanded glob leaves all but its first match as arguments
+		mergeDBs(ctx, expandDBPaths(append(mergeDBsList, flag.Args()...)))
+	} else if len(migrateDBsList) > 0 {
+		migrateDBs(ctx, expandDBPaths(append(migrateDBsList, flag.Args()...)))
+	} else if *nearDuplicatesPath != "" {
+		reportNearDuplicates(ctx, *nearDuplicatesPath)
+	} else if *coverage {
+		generateCoverage(ctx, s.Config().Counterparts())
+	} else if *verifySearchPath != "" {
+		verifySearch(ctx, *verifySearchPath)
+	} else if *reportPath != "" {
+		generateReport(ctx, *reportPath)
+	} else if *runSelfTest {
+		selfTest()
+	} else if *updateGoldenFiles {
+		updateGolden()
+	} else if *lintPath != "" {
+		lintDB(ctx, *lintPath)
+	} else if *exportFormat != "" {
+		exportDB(*exportFormat)
+	} else if *pdfLanguage != "" {
+		generatePDF(s, *pdfLanguage)
+	} else if *latexLanguage != "" {
+		generateLaTeX(s, *latexLanguage)
+	} else if *opds {
+		generateOPDS()
+	} else if *androidDir != "" {
+		generateAndroidAssets(*androidDir)
+	} else if *siteDir != "" {
+		generateSite(*siteDir)
+	} else if *packageMerged {
+		fmt.Printf("Packaged %s\n", packageRelease())
+	} else if *deltaFrom != "" {
+		fmt.Printf("Wrote %s\n", writeDelta(ctx, *deltaFrom))
+	} else if *serveAddr != "" {
+		serve(ctx, *serveAddr)
+	} else if *uploadBucket != "" {
+		uploadArtifacts(ctx, *uploadBucket, flag.Args())
+	} else if *publishRepo != "" {
+		publishRelease(ctx, *publishRepo, flag.Args())
+	} else if *verifyKey != "" {
+		verifySignatures(*verifyKey, flag.Args())
+	} else {
+		log.Fatal("You need to specify a command")
+	}
+}
+
+// mergeReaders is how many of the databases -merge reads ahead of the one
+// it's writing to merged.db
+var mergeReaders = 4
+
+// fullTextIndex makes -merge give merged.db an FTS5 full-text index, for
+// which the scraper has to be built with -tags sqlite_fts5
+var fullTextIndex = true
+
+func mergeDBs(ctx context.Context, dbs []string) {
+	started := time.Now()
+	if len(dbs) == 0 {
+		log.Fatal("No databases to merge")
+	}
+	if fullTextIndex && !prayerdb.FullTextSearch {
+		log.Fatal("This build doesn't have FTS5 for the full-text index; build with -tags sqlite_fts5, or merge with -fts=false")
+	}
+	for _, dbPath := range dbs {
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = prayerdb.CheckSchema(ctx, dbPath, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db.Close()
+	}
+
+	if appendMerge {
+		if _, err := os.Stat("merged.db"); err != nil {
+			log.Fatalf("Nothing to append to: %v", err)
+		}
+	} else {
+		// delete any old mergings
+		os.Remove("merged.db")
+	}
+
+	db, err := sql.Open("sqlite3", "merged.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if appendMerge {
+		checkIDCollisions(dbs, appendedOwners(ctx, db, dbs))
+	} else {
+		checkIDCollisions(dbs, nil)
+	}
+
+	if err = prayerdb.BeginBuild(ctx, db); err != nil {
+		log.Fatal(err)
+	}
+	if !appendMerge {
+		if err = prayerdb.CreateMerged(ctx, db, fullTextIndex); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Print("Merging")
+	skipped, err := prayerdb.MergeAll(ctx, db, dbs, mergeReaders, duplicateMode, appendMerge, func(string) {
+		fmt.Print(".")
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(" DONE!\n")
+
+	var prayerCount int
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM prayers`).Scan(&prayerCount)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = prayerdb.WriteMeta(ctx, db, map[string]string{
+		"mergedAt":       time.Now().UTC().Format(time.RFC3339),
+		"scraperVersion": version(),
+		"apiBaseURL":     bpnet.BaseURL,
+		"prayerCount":    strconv.Itoa(prayerCount),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !appendMerge {
+		fmt.Print("Creating indices... ")
+		if err = prayerdb.IndexMerged(ctx, db); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		fmt.Print("Optimizing... ")
+	}
+	if err = prayerdb.OptimizeFullText(ctx, db); err != nil {
+		log.Fatal(err)
+	}
+	if err = prayerdb.FinishBuild(ctx, db); err != nil {
+		log.Fatal(err)
+	}
+	err = prayerdb.Compact(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("DONE!\n")
+
+	fmt.Print("Verifying... ")
+	verifyMerge(ctx, db, dbs, skipped)
+	fmt.Print("DONE!\n")
+
+	counts, err := prayerdb.LanguageCounts(ctx, db, `SELECT language, count(*) FROM prayers GROUP BY language`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	recordMerge(ctx, db, started, counts)
+	if webhookURL != "" {
+		defer notifyWebhook(ctx, "merge", counts, nil)
+	}
+
+	if encryptOutput {
+		db.Close()
+		fmt.Print("Encrypting... ")
+		encryptDB("merged.db")
+		fmt.Print("DONE!\n")
+	}
+	signArtifact("merged.db")
+	checksumArtifacts()
+}
+
+// migrateDBs upgrades each of the per-language databases to the current
+// schema
+func migrateDBs(ctx context.Context, dbs []string) {
+	for _, dbPath := range dbs {
+		if err := prayerdb.Migrate(ctx, dbPath); err != nil {
+			log.Fatalf("Unable to migrate %s: %v", dbPath, err)
+		}
+	}
+}
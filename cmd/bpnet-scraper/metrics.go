@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/server"
+)
+
+// metricsFile is where a scrape writes its metrics when it finishes, in
+// Prometheus' text format, e.g. for node_exporter's textfile collector. The
+// daemon serves the metrics of its last scrape this way.
+var metricsFile = ""
+
+// apiCounts counts the requests a scrape makes to the API: all of them,
+// those the cache answered, and those that failed even after retrying
+var apiCounts struct {
+	requests, cacheHits, errors atomic.Int64
+}
+
+// countRequests is the outermost middleware of the API client, so it sees
+// every request once, however it's answered
+func countRequests(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		apiCounts.requests.Add(1)
+		switch {
+		case err != nil || resp.StatusCode != http.StatusOK:
+			apiCounts.errors.Add(1)
+		case resp.Header.Get(bpnet.CachedHeader) != "":
+			apiCounts.cacheHits.Add(1)
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// writeScrapeMetrics writes the metrics of the scrape that started at
+// started to metricsFile. It's renamed into place, so a collector never
+// reads half of it.
+func writeScrapeMetrics(started time.Time, refused []string) {
+	if metricsFile == "" {
+		return
+	}
+	var m server.PromWriter
+	m.Family("bpnet_scraper_last_scrape_timestamp_seconds", "gauge", "When the last scrape finished")
+	m.Sample("bpnet_scraper_last_scrape_timestamp_seconds", "", float64(time.Now().Unix()))
+	m.Family("bpnet_scraper_last_scrape_duration_seconds", "gauge", "How long the last scrape took")
+	m.Sample("bpnet_scraper_last_scrape_duration_seconds", "", time.Since(started).Seconds())
+
+	summary.Lock()
+	langs := make([]string, 0, len(summary.counts))
+	for lang := range summary.counts {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	m.Family("bpnet_scraper_last_scrape_prayers", "gauge", "Prayers the last scrape stored, by language")
+	for _, lang := range langs {
+		m.Sample("bpnet_scraper_last_scrape_prayers", server.PromLabels("language", lang), float64(summary.counts[lang]))
+	}
+	m.Family("bpnet_scraper_last_scrape_warnings", "gauge", "Warnings the last scrape logged")
+	m.Sample("bpnet_scraper_last_scrape_warnings", "", float64(len(summary.warnings)))
+	summary.Unlock()
+
+	requests, hits := apiCounts.requests.Load(), apiCounts.cacheHits.Load()
+	m.Family("bpnet_scraper_last_scrape_api_requests", "gauge", "Requests the last scrape made to the API, including those the cache answered")
+	m.Sample("bpnet_scraper_last_scrape_api_requests", "", float64(requests))
+	m.Family("bpnet_scraper_last_scrape_api_errors", "gauge", "Requests of the last scrape that failed even after retrying")
+	m.Sample("bpnet_scraper_last_scrape_api_errors", "", float64(apiCounts.errors.Load()))
+	m.Family("bpnet_scraper_last_scrape_api_cache_hits", "gauge", "Requests of the last scrape the cache answered")
+	m.Sample("bpnet_scraper_last_scrape_api_cache_hits", "", float64(hits))
+	m.Family("bpnet_scraper_last_scrape_api_cache_hit_ratio", "gauge", "Share of the requests of the last scrape the cache answered")
+	ratio := 0.0
+	if requests > 0 {
+		ratio = float64(hits) / float64(requests)
+	}
+	m.Sample("bpnet_scraper_last_scrape_api_cache_hit_ratio", "", ratio)
+	m.Family("bpnet_scraper_last_scrape_refused_languages", "gauge", "Languages the API refused in the last scrape")
+	m.Sample("bpnet_scraper_last_scrape_refused_languages", "", float64(len(refused)))
+
+	tmp := filepath.Join(filepath.Dir(metricsFile), "."+filepath.Base(metricsFile)+".tmp")
+	if err := ioutil.WriteFile(tmp, []byte(m.String()), 0644); err != nil {
+		slog.Error("unable to write the metrics", "path", metricsFile, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, metricsFile); err != nil {
+		slog.Error("unable to write the metrics", "path", metricsFile, "error", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/export"
+)
+
+// The OPDS catalog of the prayer books typeset with -pdf. It's an Atom feed
+// with an acquisition entry per book, so e-reader apps can browse and
+// download them, and feed readers can follow new editions. Links are
+// relative, so the catalog has to be served from the directory the books are
+// in.
+const (
+	opdsCatalog     = "catalog.xml"
+	opdsCatalogType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	opdsAcquisition = "http://opds-spec.org/acquisition"
+)
+
+type opdsFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	DC      string      `xml:"xmlns:dc,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  opdsAuthor  `xml:"author"`
+	Links   []opdsLink  `xml:"link"`
+	Entries []opdsEntry `xml:"entry"`
+}
+
+type opdsAuthor struct {
+	Name string `xml:"name"`
+}
+
+type opdsLink struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type opdsEntry struct {
+	ID       string     `xml:"id"`
+	Title    string     `xml:"title"`
+	Updated  string     `xml:"updated"`
+	Language string     `xml:"dc:language"`
+	Summary  string     `xml:"summary,omitempty"`
+	Links    []opdsLink `xml:"link"`
+}
+
+// generateOPDS writes catalog.xml, listing the prayers-<lang>.pdf books in
+// the current directory. The prayer counts come from merged.db, when there
+// is one.
+func generateOPDS() {
+	books, err := filepath.Glob("prayers-*.pdf")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(books) == 0 {
+		log.Fatal("No prayer books to catalog; typeset some with -pdf")
+	}
+	sort.Strings(books)
+
+	counts := make(map[string]int)
+	if _, err := os.Stat("merged.db"); err == nil {
+		_, languages, _ := describeMerged("merged.db")
+		for _, l := range languages {
+			counts[l.Language] = l.PrayerCount
+		}
+	}
+
+	fmt.Printf("Generating %s... ", opdsCatalog)
+	feed := opdsFeed{
+		DC:     "http://purl.org/dc/terms/",
+		ID:     "urn:bpnet-scraper:catalog",
+		Title:  "Bahá’í Prayers",
+		Author: opdsAuthor{Name: "bpnet-scraper " + version()},
+		Links: []opdsLink{
+			{Rel: "self", Href: opdsCatalog, Type: opdsCatalogType},
+			{Rel: "start", Href: opdsCatalog, Type: opdsCatalogType},
+		},
+	}
+	var newest time.Time
+	for _, book := range books {
+		info, err := os.Stat(book)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		lang := strings.TrimSuffix(strings.TrimPrefix(book, "prayers-"), ".pdf")
+		entry := opdsEntry{
+			ID:       "urn:bpnet-scraper:prayers:" + lang,
+			Title:    export.LanguageName(lang),
+			Updated:  info.ModTime().UTC().Format(time.RFC3339),
+			Language: lang,
+			Links:    []opdsLink{{Rel: opdsAcquisition, Href: book, Type: "application/pdf", Length: info.Size()}},
+		}
+		if count := counts[lang]; count > 0 {
+			entry.Summary = fmt.Sprintf("%d prayers", count)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	feed.Updated = newest.UTC().Format(time.RFC3339)
+
+	buf, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	buf = append([]byte(xml.Header), append(buf, '\n')...)
+	if err := ioutil.WriteFile(opdsCatalog, buf, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("DONE!\n")
+}
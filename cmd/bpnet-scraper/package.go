@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// manifest describes the contents of a release artifact
+type manifest struct {
+	ScrapeDate     string             `json:"scrapeDate"`
+	SchemaVersion  int                `json:"schemaVersion"`
+	ScraperVersion string             `json:"scraperVersion"`
+	Languages      []manifestLanguage `json:"languages"`
+	Files          []manifestFile     `json:"files"`
+}
+
+type manifestLanguage struct {
+	Language    string `json:"language"`
+	PrayerCount int    `json:"prayerCount"`
+	ScrapedAt   string `json:"scrapedAt,omitempty"`
+}
+
+type manifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// packageRelease zips merged.db with a manifest into a release artifact
+// named after the date of the most recent scrape in it, and returns the
+// artifact's name
+func packageRelease() string {
+	if _, err := os.Stat("merged.db"); err != nil {
+		log.Fatalf("Nothing to package: %v", err)
+	}
+
+	m := manifest{ScraperVersion: version()}
+	m.SchemaVersion, m.Languages, m.ScrapeDate = describeMerged("merged.db")
+
+	file, err := describeFile("merged.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	m.Files = append(m.Files, file)
+
+	name := fmt.Sprintf("bpnet-prayers-%s.zip", m.ScrapeDate)
+	out, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	now := time.Now()
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		log.Fatal(err)
+	}
+
+	w, err = zw.CreateHeader(&zip.FileHeader{Name: "merged.db", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := os.Open("merged.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := io.Copy(w, db); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+	signArtifact(name)
+	checksumArtifacts()
+	return name
+}
+
+// describeMerged reads the schema version, languages and scrape date of a
+// merged database from its meta table
+func describeMerged(path string) (schemaVersion int, languages []manifestLanguage, scrapeDate string) {
+	schemaVersion, languages, scrapeDate, err := readMerged(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return schemaVersion, languages, scrapeDate
+}
+
+// readMerged is describeMerged, returning the errors
+func readMerged(path string) (schemaVersion int, languages []manifestLanguage, scrapeDate string, err error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	defer db.Close()
+
+	err = db.QueryRow(`PRAGMA user_version`).Scan(&schemaVersion)
+	if err != nil {
+		return 0, nil, "", fmt.Errorf("unable to read %s; is it encrypted? %v", path, err)
+	}
+	meta, err := prayerdb.ReadMeta(context.Background(), db)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	counts, err := prayerdb.LanguageCounts(context.Background(), db, `SELECT language, count(*) FROM prayers GROUP BY language`)
+	if err != nil {
+		return 0, nil, "", err
+	}
+
+	for lang, count := range counts {
+		scrapedAt := meta[lang+".scrapedAt"]
+		languages = append(languages, manifestLanguage{Language: lang, PrayerCount: count, ScrapedAt: scrapedAt})
+	}
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Language < languages[j].Language })
+
+	// the date of the newest scrape, falling back to the merge for
+	// databases migrated from before scrapes were recorded
+	newest := ""
+	for _, l := range languages {
+		if l.ScrapedAt > newest {
+			newest = l.ScrapedAt
+		}
+	}
+	if newest == "" {
+		newest = meta["mergedAt"]
+	}
+	if newest == "" {
+		newest = dbOptions.ScrapeTime
+	}
+
+	return schemaVersion, languages, strings.SplitN(newest, "T", 2)[0], nil
+}
+
+// describeFile returns the size and checksum of a file for the manifest
+func describeFile(path string) (manifestFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	return manifestFile{Name: path, Size: size, SHA256: fmt.Sprintf("%x", h.Sum(nil))}, nil
+}
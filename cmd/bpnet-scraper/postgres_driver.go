@@ -0,0 +1,9 @@
+//go:build postgres
+// +build postgres
+
+package main
+
+import (
+	// registers the "postgres" driver for the Postgres sink
+	_ "github.com/lib/pq"
+)
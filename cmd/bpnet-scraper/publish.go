@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/upload"
+)
+
+// releaseTag and githubAPI override the tag -publish creates, prayers-<date>
+// of the scrape by default, and the API it creates it with, for GitHub
+// Enterprise
+var (
+	releaseTag = ""
+	githubAPI  = upload.GitHubAPI
+)
+
+// githubTokenEnv is the environment variable holding the token -publish
+// creates the release with
+const githubTokenEnv = "GITHUB_TOKEN"
+
+// publishRelease packages merged.db and publishes it as a release of the
+// GitHub repository named owner/name, with the changelog of the scrape as
+// its notes. The files given on the command line, like prayer books, are
+// attached to the release too, and the signatures of all of them. The
+// release is a draft until they're all uploaded, and is deleted if one of
+// them can't be, so a release never goes out without its files.
+func publishRelease(ctx context.Context, repoName string, args []string) {
+	repo, err := upload.ParseRepository(repoName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo.APIURL = githubAPI
+	repo.Token = os.Getenv(githubTokenEnv)
+	if repo.Token == "" {
+		log.Fatalf("Publishing needs a token in $%s", githubTokenEnv)
+	}
+	// check and sign the extra files first, so a typo doesn't leave a
+	// release without them
+	for _, path := range args {
+		if _, err := os.Stat(path); err != nil {
+			log.Fatal(err)
+		}
+		signArtifact(path)
+	}
+
+	artifact := packageRelease()
+	_, languages, scrapeDate := describeMerged("merged.db")
+	tag := releaseTag
+	if tag == "" {
+		tag = "prayers-" + scrapeDate
+	}
+
+	release, err := repo.CreateRelease(ctx, upload.Release{
+		Tag:   tag,
+		Name:  "Prayers of " + scrapeDate,
+		Notes: releaseNotes(scrapeDate, languages),
+		Draft: true,
+	})
+	if err != nil {
+		log.Fatalf("Unable to create the release %s: %v", tag, err)
+	}
+
+	var assets []string
+	for _, path := range append([]string{artifact}, args...) {
+		assets = append(assets, path)
+		if signature := signatureOf(path); signature != "" {
+			assets = append(assets, signature)
+		}
+	}
+	for _, path := range assets {
+		name := filepath.Base(path)
+		fmt.Printf("Uploading %s... ", name)
+		if err := repo.UploadAsset(ctx, release, name, path); err != nil {
+			discardRelease(repo, release)
+			log.Fatalf("Unable to upload %s: %v", path, err)
+		}
+		fmt.Print("DONE!\n")
+	}
+	if err := repo.PublishRelease(ctx, release); err != nil {
+		discardRelease(repo, release)
+		log.Fatalf("Unable to publish the release %s: %v", tag, err)
+	}
+	fmt.Printf("Published %s\n", release.HTMLURL)
+}
+
+// discardRelease deletes the draft of a release that couldn't be finished.
+// It goes ahead when the run is being cancelled, which may be why the
+// release couldn't be finished.
+func discardRelease(repo *upload.Repository, release *upload.PublishedRelease) {
+	if err := repo.DeleteRelease(context.Background(), release); err != nil {
+		slog.Error("unable to delete the draft release", "phase", "publish", "release", release.ID, "error", err)
+	}
+}
+
+// releaseNotes lists the prayers of each language, followed by what the
+// scrape changed from CHANGELOG.md
+func releaseNotes(scrapeDate string, languages []manifestLanguage) string {
+	var notes strings.Builder
+	total := 0
+	for _, l := range languages {
+		total += l.PrayerCount
+	}
+	fmt.Fprintf(&notes, "The prayers of %d languages, scraped on %s: %d prayers in all.\n\n", len(languages), scrapeDate, total)
+	notes.WriteString("| Language | Prayers |\n| --- | ---: |\n")
+	for _, l := range languages {
+		fmt.Fprintf(&notes, "| %s | %d |\n", l.Language, l.PrayerCount)
+	}
+
+	buf, err := ioutil.ReadFile("CHANGELOG.md")
+	if err != nil {
+		notes.WriteString("\nThere's no changelog of this scrape; scrape with -changelog to list the prayers it added, changed and removed.\n")
+		return notes.String()
+	}
+	// the release has a title of its own
+	changes := strings.TrimPrefix(string(buf), "# Changelog\n")
+	notes.WriteString("\n" + strings.TrimLeft(changes, "\n"))
+	return notes.String()
+}
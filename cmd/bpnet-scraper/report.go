@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"os"
+	"strings"
+	texttemplate "text/template"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// reportFormat is what -report renders the statistics as: markdown or html
+var reportFormat = "markdown"
+
+// reportFuncs are the helpers of the report templates
+var reportFuncs = map[string]interface{}{
+	"bar":         reportBar,
+	"percent":     reportPercent,
+	"lengthRange": reportRange,
+}
+
+// reportMarkdown lays out report.md
+const reportMarkdown = `# Corpus statistics
+{{range .}}
+## {{.Language}}
+
+{{.Prayers}} prayers, {{.Words}} words.
+
+### Length in words
+
+| Words | Prayers | |
+|---|---:|---|
+{{- $total := .Prayers}}
+{{range .Lengths}}| {{lengthRange .}} | {{.Prayers}} | {{bar .Prayers $total}} |
+{{end}}
+### Authors
+
+| Author | Prayers | |
+|---|---:|---:|
+{{range .Authors}}| {{.Name}} | {{.Count}} | {{percent .Count $total}} |
+{{end}}
+### Categories
+
+| Category | Prayers |
+|---|---:|
+{{range .Categories}}| {{.Name}} | {{.Count}} |
+{{end}}
+### Scripts
+
+| Script | Letters |
+|---|---:|
+{{range .Scripts}}| {{.Name}} | {{.Count}} |
+{{end}}{{end}}`
+
+// reportHTML lays out report.html
+const reportHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Corpus statistics</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { padding: 0.2em 0.6em; border-bottom: 1px solid #ddd; text-align: start; }
+td.n { text-align: end; }
+.bar { font-family: monospace; color: #369; }
+</style>
+</head>
+<body>
+<h1>Corpus statistics</h1>
+{{range .}}{{$total := .Prayers}}
+<h2>{{.Language}}</h2>
+<p>{{.Prayers}} prayers, {{.Words}} words.</p>
+<h3>Length in words</h3>
+<table>{{range .Lengths}}<tr><td>{{lengthRange .}}</td><td class="n">{{.Prayers}}</td><td class="bar">{{bar .Prayers $total}}</td></tr>{{end}}</table>
+<h3>Authors</h3>
+<table>{{range .Authors}}<tr><td>{{.Name}}</td><td class="n">{{.Count}}</td><td class="n">{{percent .Count $total}}</td></tr>{{end}}</table>
+<h3>Categories</h3>
+<table>{{range .Categories}}<tr><td>{{.Name}}</td><td class="n">{{.Count}}</td></tr>{{end}}</table>
+<h3>Scripts</h3>
+<table>{{range .Scripts}}<tr><td>{{.Name}}</td><td class="n">{{.Count}}</td></tr>{{end}}</table>
+{{end}}
+</body>
+</html>
+`
+
+// generateReport writes statistics of every language of a per-language or
+// merged database for reviewers: a histogram of prayer lengths, and the
+// prayers by author and category, and letters by script
+func generateReport(ctx context.Context, dbPath string) {
+	if _, err := os.Stat(dbPath); err != nil {
+		log.Fatalf("Nothing to report on: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	stats, err := prayerdb.CorpusStats(ctx, db)
+	if err != nil {
+		log.Fatalf("Unable to compute the statistics of %s: %v", dbPath, err)
+	}
+
+	var path string
+	var execute func(w io.Writer) error
+	switch reportFormat {
+	case "markdown":
+		path = "report.md"
+		tmpl := texttemplate.Must(texttemplate.New("report").Funcs(reportFuncs).Parse(reportMarkdown))
+		execute = func(w io.Writer) error { return tmpl.Execute(w, stats) }
+	case "html":
+		path = "report.html"
+		tmpl := htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(reportHTML))
+		execute = func(w io.Writer) error { return tmpl.Execute(w, stats) }
+	default:
+		log.Fatalf("Unknown report format '%s'", reportFormat)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := execute(f); err != nil {
+		f.Close()
+		log.Fatalf("Unable to write %s: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %s\n", path)
+}
+
+// reportBar draws count of total as a bar of up to 30 blocks
+func reportBar(count, total int) string {
+	if total == 0 {
+		return ""
+	}
+	return strings.Repeat("█", (count*30+total-1)/total)
+}
+
+// reportPercent formats count as a percentage of total
+func reportPercent(count, total int) string {
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f%%", float64(count)*100/float64(total))
+}
+
+// reportRange labels a bucket of the length histogram
+func reportRange(b prayerdb.LengthBucket) string {
+	if b.Max == 0 {
+		return fmt.Sprintf("%d+", b.Min)
+	}
+	return fmt.Sprintf("%d–%d", b.Min, b.Max)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// runSink records the scrape of each language in the runs table of its
+// database, once the SQLite sink has written it
+type runSink struct{}
+
+func (runSink) Name() string {
+	return "run"
+}
+
+func (runSink) Store(ctx context.Context, s prayerdb.Scrape, lang bpnet.Language) error {
+	db, err := sql.Open("sqlite3", lang.ISOName+".db")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return prayerdb.RecordRun(ctx, db, prayerdb.Run{
+		Command:        "scrape",
+		StartedAt:      s.Started,
+		Duration:       time.Since(s.Started),
+		Languages:      []string{lang.ISOName},
+		Warnings:       languageWarnings(lang.ISOName),
+		ScraperVersion: version(),
+	})
+}
+
+// languageWarnings counts the warnings logged so far about a language
+func languageWarnings(lang string) int {
+	summary.Lock()
+	defer summary.Unlock()
+	count := 0
+	for _, w := range summary.warnings {
+		if w.Language == lang {
+			count++
+		}
+	}
+	return count
+}
+
+// recordMerge records the merge that started at started in the runs table
+// of merged.db, with the languages it has
+func recordMerge(ctx context.Context, db *sql.DB, started time.Time, counts map[string]int) {
+	langs := make([]string, 0, len(counts))
+	for lang := range counts {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	summary.Lock()
+	warnings := len(summary.warnings)
+	summary.Unlock()
+
+	err := prayerdb.RecordRun(ctx, db, prayerdb.Run{
+		Command:        "merge",
+		StartedAt:      started,
+		Duration:       time.Since(started),
+		Languages:      langs,
+		Warnings:       warnings,
+		ScraperVersion: version(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/scraper"
+)
+
+// refusedPath is where -all records the languages the API refused
+const refusedPath = "refused.json"
+
+// failedPath is where -all records the languages it couldn't scrape, which
+// the daemon merges without and reports
+const failedPath = "failed.json"
+
+// refusedLanguage is an entry of refused.json
+type refusedLanguage struct {
+	ID           int    `json:"id"`
+	Language     string `json:"language"`
+	EnglishName  string `json:"englishName"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// scrapeAll scrapes every language of the source, recording the ones the
+// API refused in refused.json, so they can be followed up on upstream. The
+// file is removed when the API refused none. It returns the ISO names of the
+// refused languages, and the languages that failed otherwise.
+func scrapeAll(ctx context.Context, s *scraper.Scraper) ([]string, []*scraper.Failure) {
+	refusals, failures, err := s.ScrapeAll(ctx)
+	if err != nil {
+		scrapeFailed(err)
+	}
+	if len(refusals) == 0 {
+		os.Remove(refusedPath)
+		return nil, failures
+	}
+
+	refused := make([]refusedLanguage, 0, len(refusals))
+	var names []string
+	for _, r := range refusals {
+		names = append(names, r.Language.ISOName)
+		refused = append(refused, refusedLanguage{
+			ID:           r.Language.ID,
+			Language:     r.Language.ISOName,
+			EnglishName:  r.Language.EnglishName,
+			ErrorMessage: r.Err.ErrorMessage,
+		})
+	}
+	buf, err := json.MarshalIndent(refused, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(refusedPath, append(buf, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("The API refused %d languages; see %s\n", len(refused), refusedPath)
+	return names, failures
+}
+
+// failedLanguage is an entry of failed.json
+type failedLanguage struct {
+	Language string `json:"language"`
+	Phase    string `json:"phase"`
+	Error    string `json:"error"`
+}
+
+// failedLanguages records the languages -all couldn't scrape in
+// failed.json, and exits with them once the rest have been seen to. The
+// file is removed when none failed.
+func failedLanguages(failures []*scraper.Failure) {
+	if len(failures) == 0 {
+		os.Remove(failedPath)
+		return
+	}
+	failed := make([]failedLanguage, 0, len(failures))
+	var names []string
+	for _, f := range failures {
+		names = append(names, f.Language)
+		failed = append(failed, failedLanguage{Language: f.Language, Phase: f.Phase, Error: f.Err.Error()})
+	}
+	buf, err := json.MarshalIndent(failed, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(failedPath, append(buf, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Fatalf("Unable to scrape %d languages: %s", len(failures), strings.Join(names, ", "))
+}
+
+// readFailedLanguages reads the ISO names of the languages in failed.json
+func readFailedLanguages() ([]string, error) {
+	buf, err := ioutil.ReadFile(failedPath)
+	if err != nil {
+		return nil, err
+	}
+	var failed []failedLanguage
+	if err := json.Unmarshal(buf, &failed); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range failed {
+		names = append(names, f.Language)
+	}
+	return names, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"arashpayan.com/bpnet-scraper/scraper"
+)
+
+// goldenDir is a corpus on disk for -selftest to use instead of the built
+// in one, and the one -update-golden rewrites
+var goldenDir = ""
+
+// selfTest marks up the golden corpus with the default settings, so the
+// flags of this run don't change the outcome, and fails on any prayer that
+// doesn't match its golden file
+func selfTest() {
+	s, err := scraper.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	corpus := scraper.GoldenCorpus
+	if goldenDir != "" {
+		corpus = os.DirFS(goldenDir)
+	}
+	mismatches, err := s.SelfTest(corpus)
+	if err != nil {
+		log.Fatalf("Unable to run the self test: %v", err)
+	}
+	for _, m := range mismatches {
+		fmt.Printf("--- %s\n", m.Name)
+		fmt.Print(m.Diff())
+	}
+	if len(mismatches) > 0 {
+		log.Fatalf("%d prayers of the corpus don't match their golden files", len(mismatches))
+	}
+	fmt.Println("The markup matches the golden files")
+}
+
+// updateGolden rewrites the golden files of the corpus in goldenDir with
+// what the markup currently renders, after a deliberate change to it
+func updateGolden() {
+	if goldenDir == "" {
+		log.Fatal("Updating the golden files needs the corpus they're in; pass it with -golden-dir")
+	}
+	s, err := scraper.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cases, err := scraper.LoadGoldenCases(os.DirFS(goldenDir))
+	if err != nil {
+		log.Fatal(err)
+	}
+	updated := 0
+	for _, c := range cases {
+		got, err := s.RenderGolden(c)
+		if err != nil {
+			log.Fatalf("%s: %v", c.Name, err)
+		}
+		if got == c.Golden {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(goldenDir, c.Name+".html"), []byte(got), 0644); err != nil {
+			log.Fatal(err)
+		}
+		updated++
+	}
+	fmt.Printf("Updated %d of %d golden files\n", updated, len(cases))
+}
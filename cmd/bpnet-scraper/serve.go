@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/server"
+)
+
+// serveSchedule is when -serve rescrapes every language and rebuilds
+// merged.db
+var serveSchedule = "weekly"
+
+// daemonFlags are the flags of the daemon itself, which its scrapes and
+// merges don't get. -cache-dir is left out too, since its responses never
+// expire and every rescrape would get those of the first.
+var daemonFlags = map[string]bool{"serve": true, "schedule": true, "metrics-file": true, "cache-dir": true}
+
+// scrapeMetricsFile is where the daemon's scrapes write their metrics, for
+// /metrics to serve along with the daemon's own
+const scrapeMetricsFile = ".scrape.prom"
+
+// mergeFlags are the flags the daemon's merges get, since they run in a
+// directory of their own where the paths of the other flags don't resolve
+var mergeFlags = map[string]bool{"duplicates": true, "encrypt": true, "fts": true, "log-format": true, "merge-readers": true, "report-errors": true, "report-errors-format": true, "signing-key": true, "webhook": true, "webhook-format": true}
+
+// serve runs the daemon: it rescrapes every language into the current
+// directory on serveSchedule, rebuilding merged.db atomically, and serves
+// the databases, and the prayers of merged.db through the API, on addr until
+// ctx is done
+func serve(ctx context.Context, addr string) {
+	sched, err := server.ParseSchedule(serveSchedule)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// args are the flags the daemon was started with, for its scrapes
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		if daemonFlags[f.Name] {
+			return
+		}
+		value := f.Value.String()
+		if f.Name == "signing-key" {
+			// the merges run in a directory of their own
+			if abs, err := filepath.Abs(value); err == nil {
+				value = abs
+			}
+		}
+		args = append(args, "-"+f.Name+"="+value)
+	})
+	s := &server.Server{
+		Schedule: sched,
+		Rebuild: func(ctx context.Context) ([]string, error) {
+			return rebuild(ctx, args)
+		},
+		ScrapeMetrics: scrapeMetricsFile,
+	}
+
+	slog.Info("serving", "phase", "serve", "addr", addr, "schedule", serveSchedule)
+	if err := s.ListenAndServe(ctx, addr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// rebuild scrapes every language, then merges them into a merged.db in a
+// directory of its own, which replaces the served one only once it's
+// complete. Both run as child processes, so a failure ends them rather than
+// the daemon. It returns the languages the scrape failed on. The merge goes ahead without them, using their databases of
+// the last scrape that got them, so one failing language doesn't hold back
+// the rest.
+func rebuild(ctx context.Context, daemonArgs []string) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	// a failed.json left behind would pass for this scrape's
+	os.Remove(failedPath)
+	args := append(daemonArgs[:len(daemonArgs):len(daemonArgs)], "-metrics-file="+filepath.Join(dir, scrapeMetricsFile), "-all")
+	scrape := exec.CommandContext(ctx, exe, args...)
+	scrape.Stdout, scrape.Stderr = os.Stdout, os.Stderr
+	var failed []string
+	if err := scrape.Run(); err != nil {
+		// the scrape exits with an error after recording the languages it
+		// failed on, having scraped the others
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || ctx.Err() != nil {
+			return nil, fmt.Errorf("unable to scrape: %w", err)
+		}
+		if failed, err = readFailedLanguages(); err != nil || len(failed) == 0 {
+			return nil, fmt.Errorf("unable to scrape: %w", exitErr)
+		}
+	}
+
+	// the staging directory is next to merged.db, so it can be renamed into
+	// place
+	staging, err := os.MkdirTemp(dir, ".merge-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+	args = nil
+	for _, arg := range daemonArgs {
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "-"), "=")
+		if mergeFlags[name] {
+			args = append(args, arg)
+		}
+	}
+	merge := exec.CommandContext(ctx, exe, append(args, "-merge", dir)...)
+	merge.Dir = staging
+	merge.Stdout, merge.Stderr = os.Stdout, os.Stderr
+	if err := merge.Run(); err != nil {
+		return nil, fmt.Errorf("unable to merge: %w", err)
+	}
+	if err := os.Rename(filepath.Join(staging, "merged.db"), filepath.Join(dir, "merged.db")); err != nil {
+		return nil, err
+	}
+	if signature := signatureOf(filepath.Join(staging, "merged.db")); signature != "" {
+		if err := os.Rename(signature, filepath.Join(dir, "merged.db"+signatureExt)); err != nil {
+			return nil, err
+		}
+	}
+	// the checksums and manifest of the scrape don't have the new merged.db
+	return failed, writeChecksums()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+	"arashpayan.com/bpnet-scraper/server"
+)
+
+// signingKeyPath is a PEM file with the Ed25519 private key the artifacts
+// are signed with, as openssl genpkey -algorithm ed25519 writes it. Without
+// one, nothing is signed.
+var signingKeyPath = ""
+
+// signingKey is the key read from signingKeyPath
+var signingKey ed25519.PrivateKey
+
+// signatureExt is added to the name of an artifact for its signature, which
+// is the base64 encoded Ed25519 signature of the whole file
+const signatureExt = server.SignatureExt
+
+// loadSigningKey reads the key at signingKeyPath, if there is one
+func loadSigningKey() error {
+	if signingKeyPath == "" {
+		return nil
+	}
+	block, err := readPEM(signingKeyPath, "PRIVATE KEY")
+	if err != nil {
+		return err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("unable to read the signing key: %v", err)
+	}
+	var ok bool
+	if signingKey, ok = key.(ed25519.PrivateKey); !ok {
+		return errors.New("the signing key isn't an Ed25519 key")
+	}
+	return nil
+}
+
+// readPublicKey reads an Ed25519 public key from a PEM file, as openssl pkey
+// -pubout writes it
+func readPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the public key: %v", err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("the public key isn't an Ed25519 key")
+	}
+	return public, nil
+}
+
+// readPEM reads the block of a PEM file, which has to be of kind
+func readPEM(path, kind string) (*pem.Block, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, fmt.Errorf("%s isn't a PEM file", path)
+	}
+	if block.Type != kind {
+		return nil, fmt.Errorf("%s holds a %s, not a %s", path, strings.ToLower(block.Type), strings.ToLower(kind))
+	}
+	return block, nil
+}
+
+// signFile writes the signature of the file at path next to it, when there's
+// a signing key
+func signFile(path string) error {
+	if signingKey == nil {
+		return nil
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, buf))
+	return ioutil.WriteFile(path+signatureExt, []byte(signature+"\n"), 0644)
+}
+
+// signArtifact is signFile for the commands, which can't go on without the
+// signature
+func signArtifact(path string) {
+	if err := signFile(path); err != nil {
+		log.Fatalf("Unable to sign %s: %v", path, err)
+	}
+}
+
+// signingSink signs the database of each language the SQLite sink stored
+type signingSink struct{}
+
+func (signingSink) Name() string {
+	return "signature"
+}
+
+func (signingSink) Store(ctx context.Context, s prayerdb.Scrape, lang bpnet.Language) error {
+	return signFile(lang.ISOName + ".db")
+}
+
+// verifySignatures checks the files at paths against their signatures with
+// the public key at publicKeyPath. Without paths, it checks every file in
+// the current directory that has a signature.
+func verifySignatures(publicKeyPath string, paths []string) {
+	public, err := readPublicKey(publicKeyPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(paths) == 0 {
+		signatures, err := filepath.Glob("*" + signatureExt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, signature := range signatures {
+			paths = append(paths, strings.TrimSuffix(signature, signatureExt))
+		}
+	}
+	if len(paths) == 0 {
+		log.Fatal("Nothing to verify")
+	}
+
+	failed := 0
+	for _, path := range paths {
+		if err := verifyFile(public, path); err != nil {
+			slog.Error("bad signature", "phase", "verify", "path", path, "error", err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: OK\n", path)
+	}
+	if failed > 0 {
+		log.Fatalf("%d of %d files failed verification", failed, len(paths))
+	}
+}
+
+// verifyFile checks a file against its signature
+func verifyFile(public ed25519.PublicKey, path string) error {
+	encoded, err := ioutil.ReadFile(path + signatureExt)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("unable to read the signature: %v", err)
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(public, buf, signature) {
+		return errors.New("the file doesn't match its signature")
+	}
+	return nil
+}
+
+// signatureOf returns the path of the signature of the file at path, or ""
+// if it hasn't got one
+func signatureOf(path string) string {
+	if _, err := os.Stat(path + signatureExt); err != nil {
+		return ""
+	}
+	return path + signatureExt
+}
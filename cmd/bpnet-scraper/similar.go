@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// similarityThreshold is how alike, from 0 to 1, -near-duplicates requires
+// prayers to be to report them together
+var similarityThreshold = 0.9
+
+// reportNearDuplicates lists the clusters of nearly identical prayers in a
+// per-language or merged database, for curators to fix upstream or exclude
+// a copy of
+func reportNearDuplicates(ctx context.Context, dbPath string) {
+	if similarityThreshold <= 0 || similarityThreshold > 1 {
+		log.Fatalf("Invalid similarity threshold %v", similarityThreshold)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		log.Fatalf("Nothing to report on: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	clusters, err := prayerdb.NearDuplicates(ctx, db, similarityThreshold)
+	if err != nil {
+		log.Fatalf("Unable to find near duplicates in %s: %v", dbPath, err)
+	}
+	for _, c := range clusters {
+		fmt.Printf("%s, %d prayers:\n", c.Language, len(c.Prayers))
+		for _, p := range c.Prayers {
+			fmt.Printf("  %6d  %3.0f%%  %s: %s\n", p.ID, p.Similarity*100, p.Category, p.OpeningWords)
+		}
+	}
+	fmt.Printf("%d clusters of near duplicates in %s\n", len(clusters), dbPath)
+}
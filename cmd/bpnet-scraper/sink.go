@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+	"arashpayan.com/bpnet-scraper/scraper"
+)
+
+// updateDB makes a scrape update the existing database of the language,
+// instead of replacing it
+var updateDB = false
+
+// apiRetries is how many times a failed request to the API is tried
+var apiRetries = 3
+
+// apiBaseURL, apiCacheDir, apiRateLimit and apiStrict configure the API
+// client of a scrape: where the API is, where its responses are kept between
+// runs, how far apart requests to it are spaced and whether responses with
+// unknown fields fail
+var (
+	apiBaseURL   = ""
+	apiCacheDir  = ""
+	apiRateLimit time.Duration
+	apiStrict    = false
+)
+
+// sourceKind names where a scrape reads the prayers from: the API, the JSON
+// files or per-language databases of an earlier scrape in sourceDir, or the
+// text files of TextSource in it
+var (
+	sourceKind = "api"
+	sourceDir  = "."
+)
+
+// extraSources lists the sources of the languages sourceKind doesn't have,
+// comma separated, each a kind and where it is: text:<dir>, json:<dir>,
+// db:<dir> or api:<base URL> for an alternative API
+var extraSources = ""
+
+// sourceOptions returns the options of the scraper for the source
+// sourceKind names, and the extraSources
+func sourceOptions() ([]scraper.Option, error) {
+	var opts []scraper.Option
+	switch sourceKind {
+	case "api":
+		opts = []scraper.Option{
+			scraper.WithBaseURL(apiBaseURL),
+			scraper.WithCacheDir(apiCacheDir),
+			scraper.WithRateLimit(apiRateLimit),
+			scraper.WithRetries(apiRetries),
+			scraper.WithStrictDecoding(apiStrict),
+		}
+	case "json", "db", "text":
+		source, err := parseSource(sourceKind, sourceDir)
+		if err != nil {
+			return nil, err
+		}
+		opts = []scraper.Option{scraper.WithSource(source)}
+	default:
+		return nil, fmt.Errorf("unknown source '%s'", sourceKind)
+	}
+
+	for _, spec := range strings.Split(extraSources, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		kind, location, ok := strings.Cut(spec, ":")
+		if !ok || location == "" {
+			return nil, fmt.Errorf("the extra source '%s' doesn't say where it is", spec)
+		}
+		source, err := parseSource(kind, location)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, scraper.WithExtraSources(source))
+	}
+	return opts, nil
+}
+
+// parseSource returns the source of a kind at location, a directory or the
+// base URL of an API
+func parseSource(kind, location string) (prayerdb.PrayerSource, error) {
+	switch kind {
+	case "api":
+		return prayerdb.APISource{Client: &bpnet.Client{BaseURL: location}}, nil
+	case "json":
+		return prayerdb.JSONSource{Dir: location}, nil
+	case "db":
+		return prayerdb.DBSource{Dir: location}, nil
+	case "text":
+		return prayerdb.TextSource{Dir: location}, nil
+	}
+	return nil, fmt.Errorf("unknown source '%s'", kind)
+}
+
+// loadTranslations reads category names, keyed like prayerdb.Translations,
+// from the JSON file at path. The languages and categories it names replace
+// those of prayerdb.DefaultTranslations.
+func loadTranslations(path string) (prayerdb.Translations, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var overrides prayerdb.Translations
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	translations := prayerdb.Translations{}
+	for lang, names := range prayerdb.DefaultTranslations {
+		translations[lang] = map[string]string{}
+		for category, name := range names {
+			translations[lang][category] = name
+		}
+	}
+	for lang, names := range overrides {
+		if translations[lang] == nil {
+			translations[lang] = map[string]string{}
+		}
+		for category, name := range names {
+			translations[lang][category] = name
+		}
+	}
+	return translations, nil
+}
+
+// sinks are where a scrape stores what it scrapes
+var sinks []prayerdb.PrayerSink
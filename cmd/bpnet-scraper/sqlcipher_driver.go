@@ -0,0 +1,10 @@
+//go:build sqlcipher
+// +build sqlcipher
+
+package main
+
+import (
+	// registers a "sqlite3" driver built on SQLCipher instead of plain
+	// SQLite, for -encrypt. It reads unencrypted databases just the same.
+	_ "github.com/mutecomm/go-sqlcipher/v4"
+)
@@ -0,0 +1,9 @@
+//go:build !sqlcipher
+// +build !sqlcipher
+
+package main
+
+import (
+	// registers the "sqlite3" driver
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"arashpayan.com/bpnet-scraper/upload"
+)
+
+// uploadEndpoint and uploadRegion override where -upload's bucket is, for
+// S3 compatible stores and buckets outside us-east-1
+var (
+	uploadEndpoint = ""
+	uploadRegion   = ""
+)
+
+// The environment variables holding the keys -upload signs with. For GCS
+// they're the HMAC keys of a service account.
+const (
+	accessKeyEnv    = "AWS_ACCESS_KEY_ID"
+	secretKeyEnv    = "AWS_SECRET_ACCESS_KEY"
+	sessionTokenEnv = "AWS_SESSION_TOKEN"
+	regionEnv       = "AWS_REGION"
+)
+
+// uploadArtifacts pushes the per-language databases, merged.db and a
+// manifest listing them with their checksums to the bucket at bucketURL.
+// The databases are the ones given on the command line, or those in the
+// current directory. The manifest goes last, so a consumer that finds it
+// finds every file it lists.
+func uploadArtifacts(ctx context.Context, bucketURL string, args []string) {
+	bucket, err := upload.ParseBucket(bucketURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bucket.AccessKey, bucket.SecretKey = os.Getenv(accessKeyEnv), os.Getenv(secretKeyEnv)
+	bucket.SessionToken = os.Getenv(sessionTokenEnv)
+	if bucket.AccessKey == "" || bucket.SecretKey == "" {
+		log.Fatalf("Uploading needs keys in $%s and $%s", accessKeyEnv, secretKeyEnv)
+	}
+	// GCS takes the region of the bucket from the bucket itself
+	if region := os.Getenv(regionEnv); region != "" && bucket.Endpoint != upload.GCSEndpoint {
+		bucket.Region = region
+	}
+	if uploadEndpoint != "" {
+		bucket.Endpoint = uploadEndpoint
+	}
+	if uploadRegion != "" {
+		bucket.Region = uploadRegion
+	}
+
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	paths := expandDBPaths(args)
+	m := manifest{ScraperVersion: version()}
+	if _, err := os.Stat("merged.db"); err == nil {
+		paths = append(paths, "merged.db")
+		m.SchemaVersion, m.Languages, m.ScrapeDate = describeMerged("merged.db")
+	}
+	if len(paths) == 0 {
+		log.Fatal("Nothing to upload")
+	}
+
+	for _, path := range paths {
+		file, err := describeFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		file.Name = filepath.Base(path)
+		fmt.Printf("Uploading %s... ", file.Name)
+		sum, err := bucket.PutFile(ctx, file.Name, path)
+		if err != nil {
+			log.Fatalf("Unable to upload %s: %v", path, err)
+		}
+		if sum != file.SHA256 {
+			log.Fatalf("%s changed while it was uploaded", path)
+		}
+		m.Files = append(m.Files, file)
+		fmt.Print("DONE!\n")
+		uploadSignature(ctx, bucket, file.Name, path)
+	}
+
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmp, err := ioutil.TempFile("", "manifest-*.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(buf, '\n')); err != nil {
+		log.Fatal(err)
+	}
+	if err := tmp.Close(); err != nil {
+		log.Fatal(err)
+	}
+	// the signature goes first, so a consumer that finds the manifest can
+	// check it
+	uploadSignature(ctx, bucket, "manifest.json", tmp.Name())
+	defer os.Remove(tmp.Name() + signatureExt)
+	fmt.Print("Uploading manifest.json... ")
+	if _, err := bucket.PutFile(ctx, "manifest.json", tmp.Name()); err != nil {
+		log.Fatalf("Unable to upload the manifest: %v", err)
+	}
+	fmt.Print("DONE!\n")
+	fmt.Printf("Uploaded %d files to %s\n", len(m.Files)+1, bucketURL)
+}
+
+// uploadSignature signs the file at path, when there's a signing key, and
+// uploads its signature as name.sig if it has one
+func uploadSignature(ctx context.Context, bucket *upload.Bucket, name, path string) {
+	signArtifact(path)
+	signature := signatureOf(path)
+	if signature == "" {
+		return
+	}
+	fmt.Printf("Uploading %s... ", name+signatureExt)
+	if _, err := bucket.PutFile(ctx, name+signatureExt, signature); err != nil {
+		log.Fatalf("Unable to upload the signature of %s: %v", path, err)
+	}
+	fmt.Print("DONE!\n")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"log/slog"
+	"sort"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// verifyMerge checks the merged database once it's done: SQLite's own
+// integrity check, a row count for every merged language matching its
+// input, the indices, NULLs in NOT NULL columns, and prayers without any
+// words. skipped holds the duplicates left out of each language. Any
+// problem fails the merge.
+func verifyMerge(ctx context.Context, db *sql.DB, dbPaths []string, skipped map[string]int) {
+	var problems []string
+
+	rows, err := db.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			log.Fatal(err)
+		}
+		if result != "ok" {
+			problems = append(problems, "integrity check: "+result)
+		}
+	}
+	rows.Close()
+
+	expected := make(map[string]int)
+	for _, dbPath := range dbPaths {
+		langDB, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		counts, err := prayerdb.LanguageCounts(ctx, langDB, `SELECT language, count(*) FROM prayers WHERE deleted=0 GROUP BY language`)
+		if err != nil {
+			log.Fatal(err)
+		}
+		langDB.Close()
+		for lang, count := range counts {
+			expected[lang] += count
+		}
+	}
+	merged, err := prayerdb.LanguageCounts(ctx, db, `SELECT language, count(*) FROM prayers GROUP BY language`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for lang, count := range expected {
+		if merged[lang] != count-skipped[lang] {
+			problems = append(problems, fmt.Sprintf("%s has %d prayers, but its inputs have %d", lang, merged[lang], count-skipped[lang]))
+		}
+	}
+
+	for _, index := range prayerdb.MergedIndices {
+		var exists int
+		err := db.QueryRowContext(ctx, `SELECT count(*) FROM sqlite_master WHERE type='index' AND name=?`, index).Scan(&exists)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if exists == 0 {
+			problems = append(problems, "missing index "+index)
+		}
+	}
+
+	var wordless int
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM prayers WHERE wordCount=0`).Scan(&wordless)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if wordless > 0 {
+		problems = append(problems, fmt.Sprintf("%d prayers have no words", wordless))
+	}
+
+	for _, table := range []string{"prayers", "tags", "prayer_tags", "authors", "removed_prayers", "runs", "categories", "meta"} {
+		columns, err := notNullColumns(ctx, db, table)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, column := range columns {
+			var nulls int
+			err := db.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(*) FROM %s WHERE %s IS NULL`, table, column)).Scan(&nulls)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if nulls > 0 {
+				problems = append(problems, fmt.Sprintf("%d NULLs in %s.%s", nulls, table, column))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		for _, p := range problems {
+			slog.Error("verification problem", "phase", "verify", "problem", p)
+		}
+		log.Fatal("merged.db failed verification")
+	}
+}
+
+// notNullColumns lists the columns of a table declared NOT NULL
+func notNullColumns(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		if notNull == 1 {
+			columns = append(columns, name)
+		}
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// verifySearch reports the prayers of a per-language or merged database
+// whose wordCount or searchText don't match what their prayerText derives,
+// failing when there are any, so a database built with broken HTML
+// stripping doesn't ship
+func verifySearch(ctx context.Context, dbPath string) {
+	if _, err := os.Stat(dbPath); err != nil {
+		log.Fatalf("Nothing to verify: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	mismatches, err := prayerdb.VerifySearch(ctx, db)
+	if err != nil {
+		log.Fatalf("Unable to verify the search fields of %s: %v", dbPath, err)
+	}
+	for _, m := range mismatches {
+		fmt.Printf("%s prayer %d: %s\n  stored:  %s\n  derived: %s\n", m.Language, m.PrayerID, m.Column, ellipsize(m.Stored, 80), ellipsize(m.Derived, 80))
+	}
+	if len(mismatches) > 0 {
+		db.Close()
+		log.Fatalf("%s has %d stale search fields; migrate or scrape it again", dbPath, len(mismatches))
+	}
+	fmt.Printf("The search fields of %s match its prayers\n", dbPath)
+}
+
+// ellipsize cuts s to at most n runes, for reports
+func ellipsize(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// webhookURL gets a summary of every scrape or merge that finishes, and
+// webhookFormat is the shape of it: slack, discord or json. When the format
+// is empty, it's picked by the host of the URL.
+var (
+	webhookURL    = ""
+	webhookFormat = ""
+)
+
+// webhookWarnings is how many warnings the slack and discord messages list;
+// the json payload has all of them
+const webhookWarnings = 10
+
+// runSummary is the payload of the json webhook
+type runSummary struct {
+	Command        string            `json:"command"`
+	FinishedAt     string            `json:"finishedAt"`
+	ScraperVersion string            `json:"scraperVersion"`
+	Languages      []languageSummary `json:"languages"`
+	Refused        []string          `json:"refused,omitempty"`
+	Warnings       []runWarning      `json:"warnings"`
+}
+
+type languageSummary struct {
+	Language string `json:"language"`
+	Prayers  int    `json:"prayers"`
+}
+
+// runWarning is a warning logged during the run
+type runWarning struct {
+	Language string `json:"language,omitempty"`
+	Prayer   int    `json:"prayer,omitempty"`
+	Message  string `json:"message"`
+}
+
+// summary collects what the webhook, the metrics file and the runs table
+// report while the scraper runs
+var summary = struct {
+	sync.Mutex
+	counts   map[string]int
+	warnings []runWarning
+}{counts: make(map[string]int)}
+
+// summarySink counts the prayers stored for each language. It comes after
+// the other sinks, so only languages that were stored everywhere count.
+type summarySink struct{}
+
+func (summarySink) Name() string {
+	return "summary"
+}
+
+func (summarySink) Store(ctx context.Context, s prayerdb.Scrape, lang bpnet.Language) error {
+	summary.Lock()
+	defer summary.Unlock()
+	summary.counts[lang.ISOName] = len(s.Prayers)
+	return nil
+}
+
+// warningRecorder passes log records on to its handler, keeping the
+// warnings for the summary
+type warningRecorder struct {
+	slog.Handler
+	attrs []slog.Attr
+}
+
+func (w warningRecorder) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level == slog.LevelWarn {
+		warning := runWarning{Message: r.Message}
+		eachAttr(w.attrs, r, func(a slog.Attr) {
+			switch a.Key {
+			case "language":
+				warning.Language = a.Value.String()
+			case "prayer":
+				if a.Value.Kind() == slog.KindInt64 {
+					warning.Prayer = int(a.Value.Int64())
+				}
+			}
+		})
+		summary.Lock()
+		summary.warnings = append(summary.warnings, warning)
+		summary.Unlock()
+	}
+	return w.Handler.Handle(ctx, r)
+}
+
+func (w warningRecorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return warningRecorder{Handler: w.Handler.WithAttrs(attrs), attrs: append(w.attrs[:len(w.attrs):len(w.attrs)], attrs...)}
+}
+
+func (w warningRecorder) WithGroup(name string) slog.Handler {
+	return warningRecorder{Handler: w.Handler.WithGroup(name), attrs: w.attrs}
+}
+
+// checkWebhook fails early on a webhook that can't be notified
+func checkWebhook() error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("the webhook needs an http or https URL, not '%s'", webhookURL)
+	}
+	if webhookFormat == "" {
+		switch {
+		case u.Host == "hooks.slack.com":
+			webhookFormat = "slack"
+		case u.Host == "discord.com" || u.Host == "discordapp.com":
+			webhookFormat = "discord"
+		default:
+			webhookFormat = "json"
+		}
+	}
+	switch webhookFormat {
+	case "slack", "discord", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown webhook format '%s'", webhookFormat)
+}
+
+// notifyWebhook posts the summary of the command that just finished to the
+// webhook. languages are the prayer counts to report, or nil for the ones
+// the summarySink counted. A webhook that fails is logged, since the work
+// itself is done.
+func notifyWebhook(ctx context.Context, command string, languages map[string]int, refused []string) {
+	if webhookURL == "" {
+		return
+	}
+	summary.Lock()
+	if languages == nil {
+		languages = summary.counts
+	}
+	s := runSummary{
+		Command:        command,
+		FinishedAt:     time.Now().UTC().Format(time.RFC3339),
+		ScraperVersion: version(),
+		Refused:        refused,
+		Warnings:       append([]runWarning{}, summary.warnings...),
+	}
+	for lang, count := range languages {
+		s.Languages = append(s.Languages, languageSummary{Language: lang, Prayers: count})
+	}
+	summary.Unlock()
+	sort.Slice(s.Languages, func(i, j int) bool { return s.Languages[i].Language < s.Languages[j].Language })
+
+	var payload interface{} = s
+	switch webhookFormat {
+	case "slack":
+		payload = map[string]string{"text": s.text()}
+	case "discord":
+		// discord cuts messages off at 2000 characters
+		text := s.text()
+		if len(text) > 2000 {
+			text = strings.ToValidUTF8(text[:1990], "") + "\n…"
+		}
+		payload = map[string]string{"content": text}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := postJSON(ctx, webhookURL, nil, payload); err != nil {
+		slog.Error("unable to notify the webhook", "error", err)
+	}
+}
+
+// text is the summary as a chat message
+func (s runSummary) text() string {
+	var b strings.Builder
+	total := 0
+	for _, l := range s.Languages {
+		total += l.Prayers
+	}
+	fmt.Fprintf(&b, "bpnet-scraper %s finished: %d prayers in %d languages\n", s.Command, total, len(s.Languages))
+	for _, l := range s.Languages {
+		fmt.Fprintf(&b, "• %s: %d\n", l.Language, l.Prayers)
+	}
+	if len(s.Refused) > 0 {
+		fmt.Fprintf(&b, "Refused by the API: %s\n", strings.Join(s.Refused, ", "))
+	}
+	if len(s.Warnings) > 0 {
+		fmt.Fprintf(&b, "%d warnings:\n", len(s.Warnings))
+		for i, w := range s.Warnings {
+			if i == webhookWarnings {
+				fmt.Fprintf(&b, "…and %d more\n", len(s.Warnings)-i)
+				break
+			}
+			b.WriteString("• ")
+			if w.Language != "" {
+				b.WriteString(w.Language + ": ")
+			}
+			b.WriteString(w.Message)
+			if w.Prayer != 0 {
+				fmt.Fprintf(&b, " (prayer %d)", w.Prayer)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
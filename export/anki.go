@@ -0,0 +1,220 @@
+package export
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The IDs of the note type and deck of the Anki export. They're fixed, so
+// importing a newer export updates the notes of the previous one instead of
+// adding a second deck.
+const (
+	ankiModelID = 1602201600000
+	ankiDeckID  = 1602201600001
+	// ankiNoteIDBase is added to the ID of a prayer to get the ID of its note
+	ankiNoteIDBase = 1602201700000
+)
+
+// createAnkiSQL creates the tables of an Anki collection, schema version 11.
+// Anki still imports this version of the schema from .apkg files.
+const createAnkiSQL = `
+CREATE TABLE col (id INTEGER PRIMARY KEY, crt INTEGER NOT NULL, mod INTEGER NOT NULL, scm INTEGER NOT NULL, ver INTEGER NOT NULL, dty INTEGER NOT NULL, usn INTEGER NOT NULL, ls INTEGER NOT NULL, conf TEXT NOT NULL, models TEXT NOT NULL, decks TEXT NOT NULL, dconf TEXT NOT NULL, tags TEXT NOT NULL);
+CREATE TABLE notes (id INTEGER PRIMARY KEY, guid TEXT NOT NULL, mid INTEGER NOT NULL, mod INTEGER NOT NULL, usn INTEGER NOT NULL, tags TEXT NOT NULL, flds TEXT NOT NULL, sfld INTEGER NOT NULL, csum INTEGER NOT NULL, flags INTEGER NOT NULL, data TEXT NOT NULL);
+CREATE TABLE cards (id INTEGER PRIMARY KEY, nid INTEGER NOT NULL, did INTEGER NOT NULL, ord INTEGER NOT NULL, mod INTEGER NOT NULL, usn INTEGER NOT NULL, type INTEGER NOT NULL, queue INTEGER NOT NULL, due INTEGER NOT NULL, ivl INTEGER NOT NULL, factor INTEGER NOT NULL, reps INTEGER NOT NULL, lapses INTEGER NOT NULL, left INTEGER NOT NULL, odue INTEGER NOT NULL, odid INTEGER NOT NULL, flags INTEGER NOT NULL, data TEXT NOT NULL);
+CREATE TABLE revlog (id INTEGER PRIMARY KEY, cid INTEGER NOT NULL, usn INTEGER NOT NULL, ease INTEGER NOT NULL, ivl INTEGER NOT NULL, lastIvl INTEGER NOT NULL, factor INTEGER NOT NULL, time INTEGER NOT NULL, type INTEGER NOT NULL);
+CREATE TABLE graves (usn INTEGER NOT NULL, oid INTEGER NOT NULL, type INTEGER NOT NULL);
+CREATE INDEX ix_notes_usn ON notes (usn);
+CREATE INDEX ix_cards_usn ON cards (usn);
+CREATE INDEX ix_revlog_usn ON revlog (usn);
+CREATE INDEX ix_cards_nid ON cards (nid);
+CREATE INDEX ix_cards_sched ON cards (did, queue, due);
+CREATE INDEX ix_revlog_cid ON revlog (cid);
+CREATE INDEX ix_notes_csum ON notes (csum);
+`
+
+// ankiFields are the fields of a note, in order. The card asks for the
+// prayer from its opening words.
+var ankiFields = []string{"Opening", "Prayer", "Citation", "Author"}
+
+const (
+	ankiFront = `{{Opening}}`
+	ankiBack  = `{{FrontSide}}<hr id="answer">{{Prayer}}{{#Citation}}<p class="citation">{{Citation}}</p>{{/Citation}}{{#Author}}<p class="author">{{Author}}</p>{{/Author}}`
+	ankiCSS   = `.card { font-family: Georgia, serif; font-size: 20px; text-align: start; color: black; background-color: white; }
+.citation, .author { font-style: italic; text-align: end; }`
+)
+
+// Anki writes the prayers of the merged database at mergedPath to w as
+// prayers.apkg, a deck Anki can import with a note per prayer. Notes are
+// tagged with the language and category of their prayer.
+func Anki(mergedPath string, w io.Writer) error {
+	dir, err := ioutil.TempDir("", "bpnet-anki")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	collection := filepath.Join(dir, "collection.anki2")
+
+	db, err := sql.Open("sqlite3", collection)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`ATTACH DATABASE ? AS merged`, mergedPath)
+	if err != nil {
+		return err
+	}
+	if err := createAnkiCollection(db); err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	entry, err := zw.Create("collection.anki2")
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(collection)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(entry, f); err != nil {
+		return err
+	}
+	// the export has no media, but Anki expects the map of media files
+	entry, err = zw.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := entry.Write([]byte("{}")); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// createAnkiCollection fills an empty Anki collection with a note and a new
+// card for every prayer of the attached merged database
+func createAnkiCollection(db *sql.DB) error {
+	if _, err := db.Exec(createAnkiSQL); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	models, decks, dconf, conf, err := ankiCollectionConfig(now.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`, now.Unix(), now.UnixNano()/int64(time.Millisecond), now.UnixNano()/int64(time.Millisecond), conf, models, decks, dconf)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, language, category, openingWords, prayerText, citation, author FROM merged.prayers ORDER BY language, category, sortKey, id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	due := 0
+	for rows.Next() {
+		var id int64
+		var lang, category, openingWords, prayerText, citation, author string
+		if err := rows.Scan(&id, &lang, &category, &openingWords, &prayerText, &citation, &author); err != nil {
+			return err
+		}
+		due++
+		tags := fmt.Sprintf(" language::%s category::%s ", ankiTag(lang), ankiTag(category))
+		fields := strings.Join([]string{openingWords, prayerText, citation, author}, "\x1f")
+		_, err := tx.Exec(`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`, ankiNoteIDBase+id, fmt.Sprintf("bpnet-%d", id), ankiModelID, now.Unix(), tags, fields, openingWords, ankiChecksum(openingWords))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`, ankiNoteIDBase+id, ankiNoteIDBase+id, ankiDeckID, now.Unix(), due)
+		if err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ankiTag turns a language or category into an Anki tag, which can't have
+// spaces
+func ankiTag(s string) string {
+	return strings.Join(strings.Fields(s), "_")
+}
+
+// ankiChecksum is the checksum Anki finds duplicate notes by: the first 8
+// hex digits of the SHA-1 of the sort field
+func ankiChecksum(sortField string) uint32 {
+	sum := sha1.Sum([]byte(sortField))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// ankiCollectionConfig returns the JSON of the note types, decks, deck
+// options and settings of the collection
+func ankiCollectionConfig(mod int64) (models, decks, dconf, conf string, err error) {
+	var flds []map[string]interface{}
+	for i, name := range ankiFields {
+		flds = append(flds, map[string]interface{}{"name": name, "ord": i, "sticky": false, "rtl": false, "font": "Georgia", "size": 20, "media": []string{}})
+	}
+	model := map[string]interface{}{
+		"id": ankiModelID, "name": "Prayer", "type": 0, "mod": mod, "usn": -1, "sortf": 0, "did": ankiDeckID,
+		"tmpls": []map[string]interface{}{{"name": "Opening words", "ord": 0, "qfmt": ankiFront, "afmt": ankiBack, "did": nil, "bqfmt": "", "bafmt": ""}},
+		"flds":  flds, "css": ankiCSS, "latexPre": "", "latexPost": "", "tags": []string{}, "vers": []string{},
+		"req": []interface{}{[]interface{}{0, "all", []int{0}}},
+	}
+	deck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "mod": mod, "usn": -1, "desc": "", "dyn": 0, "conf": 1, "collapsed": false,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+			"extendNew": 10, "extendRev": 50,
+		}
+	}
+	options := map[string]interface{}{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new":   map[string]interface{}{"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500, "order": 1, "perDay": 20, "bury": true, "separate": true},
+		"rev":   map[string]interface{}{"perDay": 100, "ease4": 1.3, "fuzz": 0.05, "maxIvl": 36500, "bury": true, "minSpace": 1},
+		"lapse": map[string]interface{}{"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0},
+	}
+	settings := map[string]interface{}{
+		"activeDecks": []int64{ankiDeckID}, "curDeck": ankiDeckID, "curModel": ankiModelID, "nextPos": 1,
+		"newSpread": 0, "collapseTime": 1200, "timeLim": 0, "estTimes": true, "dueCounts": true, "sortType": "noteFld", "sortBackwards": false,
+	}
+
+	encoded := make([]string, 4)
+	for i, v := range []interface{}{
+		map[string]interface{}{fmt.Sprint(ankiModelID): model},
+		map[string]interface{}{"1": deck(1, "Default"), fmt.Sprint(ankiDeckID): deck(ankiDeckID, "Prayers")},
+		map[string]interface{}{"1": options},
+		settings,
+	} {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		encoded[i] = string(buf)
+	}
+	return encoded[0], encoded[1], encoded[2], encoded[3], nil
+}
@@ -0,0 +1,91 @@
+package export
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultColumns are the columns of merged.db a CSV or TSV export has by
+// default, in order: the ones a proofreader needs
+const DefaultColumns = "id,language,category,openingWords,author,citation,plainText"
+
+// CSV writes the prayers of a merged database to w as a spreadsheet, one
+// row per prayer under a header row naming the columns. comma separates the
+// fields, ',' for CSV or '\t' for TSV.
+func CSV(db *sql.DB, w io.Writer, columns []string, comma rune) error {
+	// spreadsheets only read the file as UTF-8 when it starts with a BOM
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return writeSpreadsheet(db, cw, columns)
+}
+
+// ParseColumns checks a comma separated list of columns against the columns
+// of merged.db, for CSV. sortKey is binary, so it can't be exported.
+func ParseColumns(list string) ([]string, error) {
+	var columns []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, c := range exportColumns {
+			if c.name == name && c.sqlType != "BLOB" {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column '%s'", name)
+		}
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no columns to export")
+	}
+	return columns, nil
+}
+
+// writeSpreadsheet writes the header row and the prayers of db, ordered the
+// way the app lists them. The csv package quotes every field that needs it.
+func writeSpreadsheet(db *sql.DB, w *csv.Writer, columns []string) error {
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT %s FROM prayers ORDER BY language, category, sortKey, id`, strings.Join(columns, ", ")))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			record[i] = v.String
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
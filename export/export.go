@@ -0,0 +1,190 @@
+// Package export writes the prayers of a merged database in the formats
+// other programs read: the prepackaged databases of the app's platforms,
+// JSON, JSON Lines, XML, protocol buffers, CSV, property lists, SQL dumps
+// and Anki decks, as well as a static website. It also typesets the prayers
+// of a per-language database as a PDF or a LaTeX book. The databases are
+// opened with the "sqlite3" database/sql driver, which the program has to
+// register:
+//
+//	db, err := sql.Open("sqlite3", "merged.db")
+//	...
+//	err = export.JSON(db, w)
+package export
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// exportColumn is a column of the merged prayers table, with its SQLite type
+// affinity
+type exportColumn struct {
+	name    string
+	sqlType string
+}
+
+var exportColumns = []exportColumn{
+	{"id", "INTEGER"},
+	{"category", "TEXT"},
+	{"prayerText", "TEXT"},
+	{"openingWords", "TEXT"},
+	{"citation", "TEXT"},
+	{"author", "TEXT"},
+	{"authorId", "INTEGER"},
+	{"language", "TEXT"},
+	{"wordCount", "INTEGER"},
+	{"searchText", "TEXT"},
+	{"sortKey", "BLOB"},
+	{"plainText", "TEXT"},
+	{"footnotes", "TEXT"},
+	{"rawText", "TEXT"},
+	{"hasInstructions", "INTEGER"},
+	{"duplicateOf", "INTEGER"},
+	{"contentHash", "TEXT"},
+	{"createdAt", "TEXT"},
+	{"updatedAt", "TEXT"},
+	{"sortOrder", "INTEGER"},
+	{"source", "TEXT"},
+}
+
+// Room writes the prayers of the merged database at mergedPath to a new
+// database at path, in the schema Room on Android imports prepackaged data
+// from. version is the database version of the app's Room schema, and
+// identityHash its identity hash, both from the app's exported schema JSON.
+// Without the identity hash, Room validates the schema column by column.
+func Room(path, mergedPath string, version int, identityHash string) error {
+	return writeDatabase(path, mergedPath, func(db *sql.DB) error {
+		return createRoomExport(db, version, identityHash)
+	})
+}
+
+// CoreData writes the prayers of the merged database at mergedPath to a new
+// database at path, in the schema of a Core Data store of the app.
+// metadata is the plist of a store created by the app, holding its model's
+// version hashes.
+func CoreData(path, mergedPath string, metadata []byte) error {
+	return writeDatabase(path, mergedPath, func(db *sql.DB) error {
+		return createCoreDataExport(db, metadata)
+	})
+}
+
+// writeDatabase creates the database at path with create, which reads the
+// merged database attached as merged, and compacts it for shipping
+func writeDatabase(path, mergedPath string, create func(db *sql.DB) error) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(fmt.Sprintf(`PRAGMA page_size = %d`, prayerdb.MobilePageSize))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`ATTACH DATABASE ? AS merged`, mergedPath)
+	if err != nil {
+		return err
+	}
+	if err := create(db); err != nil {
+		return err
+	}
+	_, err = db.Exec(`DETACH DATABASE merged`)
+	if err != nil {
+		return err
+	}
+	if err := prayerdb.Compact(context.Background(), db); err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// createRoomExport lays the prayers out the way Room creates an entity's
+// table, with the android_metadata table Android's SQLiteOpenHelper expects,
+// and the room_master_table Room checks the schema's identity hash against.
+// Room compares user_version with the database version of the app.
+func createRoomExport(db *sql.DB, version int, identityHash string) error {
+	var columns, names []string
+	for _, c := range exportColumns {
+		columns = append(columns, fmt.Sprintf("`%s` %s NOT NULL", c.name, c.sqlType))
+		names = append(names, c.name)
+	}
+
+	statements := []string{
+		`CREATE TABLE android_metadata (locale TEXT)`,
+		`INSERT INTO android_metadata VALUES ('en_US')`,
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS `prayers` (%s, PRIMARY KEY(`id`))", strings.Join(columns, ", ")),
+		"CREATE INDEX IF NOT EXISTS `index_prayers_language` ON `prayers` (`language`)",
+		"CREATE INDEX IF NOT EXISTS `index_prayers_category_language` ON `prayers` (`category`, `language`)",
+		"CREATE INDEX IF NOT EXISTS `index_prayers_language_sortKey` ON `prayers` (`language`, `sortKey`)",
+		fmt.Sprintf(`INSERT INTO prayers (%s) SELECT %s FROM merged.prayers`, strings.Join(names, ", "), strings.Join(names, ", ")),
+		fmt.Sprintf(`PRAGMA user_version = %d`, version),
+	}
+	if identityHash != "" {
+		statements = append(statements,
+			`CREATE TABLE IF NOT EXISTS room_master_table (id INTEGER PRIMARY KEY,identity_hash TEXT)`,
+			fmt.Sprintf(`INSERT OR REPLACE INTO room_master_table (id,identity_hash) VALUES(42, '%s')`, strings.Replace(identityHash, "'", "''", -1)),
+		)
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createCoreDataExport lays the prayers out the way Core Data's SQLite store
+// keeps a Prayer entity: a ZPRAYER table with the Z_PK, Z_ENT and Z_OPT
+// bookkeeping columns, Z_PRIMARYKEY to hand out new primary keys, and
+// Z_METADATA with the app's store metadata, so the model's version hashes
+// match and Core Data opens the store without migrating it.
+func createCoreDataExport(db *sql.DB, metadata []byte) error {
+	columns := []string{"Z_PK INTEGER PRIMARY KEY", "Z_ENT INTEGER", "Z_OPT INTEGER"}
+	var names, zNames []string
+	for _, c := range exportColumns {
+		zName := "Z" + strings.ToUpper(c.name)
+		columns = append(columns, zName+" "+c.sqlType)
+		names = append(names, c.name)
+		zNames = append(zNames, zName)
+	}
+
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE ZPRAYER (%s)`, strings.Join(columns, ", ")),
+		`CREATE INDEX ZPRAYER_ZLANGUAGE_INDEX ON ZPRAYER (ZLANGUAGE)`,
+		`CREATE INDEX ZPRAYER_ZCATEGORY_ZLANGUAGE_INDEX ON ZPRAYER (ZCATEGORY, ZLANGUAGE)`,
+		fmt.Sprintf(`INSERT INTO ZPRAYER (Z_PK, Z_ENT, Z_OPT, %s) SELECT row_number() OVER (ORDER BY id), 1, 1, %s FROM merged.prayers`, strings.Join(zNames, ", "), strings.Join(names, ", ")),
+		`CREATE TABLE Z_PRIMARYKEY (Z_ENT INTEGER PRIMARY KEY, Z_NAME VARCHAR, Z_SUPER INTEGER, Z_MAX INTEGER)`,
+		`INSERT INTO Z_PRIMARYKEY (Z_ENT, Z_NAME, Z_SUPER, Z_MAX) SELECT 1, 'Prayer', 0, count(*) FROM ZPRAYER`,
+		`CREATE TABLE Z_METADATA (Z_VERSION INTEGER PRIMARY KEY, Z_UUID VARCHAR(255), Z_PLIST BLOB)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	uuid, err := storeUUID()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO Z_METADATA (Z_VERSION, Z_UUID, Z_PLIST) VALUES (1, ?, ?)`, uuid, metadata)
+	return err
+}
+
+// storeUUID makes the random UUID Core Data identifies a store by
+func storeUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])), nil
+}
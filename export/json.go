@@ -0,0 +1,134 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"arashpayan.com/bpnet-scraper/markup"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// jsonExportVersion is bumped whenever a field of the JSON export is renamed
+// or removed. New fields may be added without bumping it.
+const jsonExportVersion = 1
+
+// jsonExport is the layout of prayers.json: every prayer of merged.db,
+// grouped by language and then by category. Languages are ordered by code,
+// categories by name, and prayers the way the app lists them, so exports of
+// the same data are byte for byte identical.
+type jsonExport struct {
+	Version        int            `json:"version"`
+	ScraperVersion string         `json:"scraperVersion"`
+	MergedAt       string         `json:"mergedAt,omitempty"`
+	Languages      []jsonLanguage `json:"languages"`
+}
+
+type jsonLanguage struct {
+	Language   string         `json:"language"`
+	ScrapedAt  string         `json:"scrapedAt,omitempty"`
+	Categories []jsonCategory `json:"categories"`
+}
+
+type jsonCategory struct {
+	Name    string   `json:"name"`
+	Prayers []Prayer `json:"prayers"`
+}
+
+// Prayer is a prayer of the JSON export. PrayerText is the HTML of the
+// prayer and PlainText its text without markup. DuplicateOf is the ID of
+// the prayer it's a copy of, if merged with -duplicates link.
+type Prayer struct {
+	ID              int               `json:"id"`
+	OpeningWords    string            `json:"openingWords"`
+	PrayerText      string            `json:"prayerText"`
+	PlainText       string            `json:"plainText"`
+	Citation        string            `json:"citation"`
+	Author          string            `json:"author"`
+	AuthorID        int               `json:"authorId"`
+	WordCount       int               `json:"wordCount"`
+	HasInstructions bool              `json:"hasInstructions"`
+	Footnotes       []markup.Footnote `json:"footnotes"`
+	DuplicateOf     int               `json:"duplicateOf,omitempty"`
+	ContentHash     string            `json:"contentHash"`
+	CreatedAt       string            `json:"createdAt"`
+	UpdatedAt       string            `json:"updatedAt"`
+	Source          string            `json:"source"`
+}
+
+// JSON writes the prayers of a merged database to w as prayers.json, for
+// consumers that would rather not read SQLite
+func JSON(db *sql.DB, w io.Writer) error {
+	export, err := readJSONExport(db)
+	if err != nil {
+		return err
+	}
+	// the prayers are HTML, so escaping it would only make it unreadable
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+// readJSONExport gathers the prayers of a merged database into the layout
+// of the JSON export
+func readJSONExport(db *sql.DB) (*jsonExport, error) {
+	meta, err := prayerdb.ReadMeta(context.Background(), db)
+	if err != nil {
+		return nil, err
+	}
+	export := &jsonExport{
+		Version:        jsonExportVersion,
+		ScraperVersion: meta["scraperVersion"],
+		MergedAt:       meta["mergedAt"],
+		Languages:      []jsonLanguage{},
+	}
+
+	rows, err := db.Query(jsonPrayersSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lang, category, p, err := scanJSONPrayer(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if n := len(export.Languages); n == 0 || export.Languages[n-1].Language != lang {
+			export.Languages = append(export.Languages, jsonLanguage{Language: lang, ScrapedAt: meta[lang+".scrapedAt"]})
+		}
+		l := &export.Languages[len(export.Languages)-1]
+		if n := len(l.Categories); n == 0 || l.Categories[n-1].Name != category {
+			l.Categories = append(l.Categories, jsonCategory{Name: category})
+		}
+		c := &l.Categories[len(l.Categories)-1]
+		c.Prayers = append(c.Prayers, p)
+	}
+	return export, rows.Err()
+}
+
+// jsonPrayersSQL selects what scanJSONPrayer reads, in the order of the
+// exports
+const jsonPrayersSQL = `SELECT language, category, id, openingWords, prayerText, plainText, citation, author, authorId, wordCount, hasInstructions, footnotes, duplicateOf, contentHash, createdAt, updatedAt, source FROM prayers ORDER BY language, category, sortKey, id`
+
+// scanJSONPrayer reads a row of jsonPrayersSQL, returning the language and
+// category of the prayer along with it
+func scanJSONPrayer(rows *sql.Rows) (string, string, Prayer, error) {
+	var lang, category, footnotes string
+	var p Prayer
+	err := rows.Scan(&lang, &category, &p.ID, &p.OpeningWords, &p.PrayerText, &p.PlainText, &p.Citation, &p.Author, &p.AuthorID, &p.WordCount, &p.HasInstructions, &footnotes, &p.DuplicateOf, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.Source)
+	if err != nil {
+		return "", "", p, err
+	}
+	p.Footnotes = []markup.Footnote{}
+	if footnotes != "" {
+		if err := json.Unmarshal([]byte(footnotes), &p.Footnotes); err != nil {
+			return "", "", p, fmt.Errorf("footnotes of prayer %d: %v", p.ID, err)
+		}
+	}
+	return lang, category, p, nil
+}
@@ -0,0 +1,40 @@
+package export
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+)
+
+// jsonLine is a line of prayers.jsonl: a prayer of the JSON export, along
+// with the language and category it's grouped under there
+type jsonLine struct {
+	Language string `json:"language"`
+	Category string `json:"category"`
+	Prayer
+}
+
+// JSONLines writes the prayers of a merged database to w as prayers.jsonl,
+// one JSON object per line. Prayers are written as they're read, so the
+// corpus never has to fit in memory. The encoder ends each object with a
+// newline, and escapes the newlines within the text.
+func JSONLines(db *sql.DB, w io.Writer) error {
+	rows, err := db.Query(jsonPrayersSQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for rows.Next() {
+		lang, category, p, err := scanJSONPrayer(rows)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(jsonLine{Language: lang, Category: category, Prayer: p}); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
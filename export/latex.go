@@ -0,0 +1,261 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/markup"
+	"arashpayan.com/bpnet-scraper/scraper"
+)
+
+// ErrNoScriptFont is returned by Book.LaTeX for a language whose script
+// needs a font, when it isn't given one
+var ErrNoScriptFont = errors.New("needs a font for its script")
+
+// latexLanguage holds the names babel and polyglossia know a language by.
+// Languages with no babel name are only set up for XeLaTeX and LuaLaTeX, and
+// right to left languages also need the polyglossia command naming the font
+// of their script.
+type latexLanguage struct {
+	babel, polyglossia, scriptFont string
+}
+
+var latexLanguages = map[string]latexLanguage{
+	"ar": {"", "arabic", "arabicfont"},
+	"bg": {"bulgarian", "bulgarian", ""},
+	"cs": {"czech", "czech", ""},
+	"da": {"danish", "danish", ""},
+	"de": {"ngerman", "german", ""},
+	"el": {"greek", "greek", ""},
+	"en": {"english", "english", ""},
+	"eo": {"esperanto", "esperanto", ""},
+	"es": {"spanish", "spanish", ""},
+	"et": {"estonian", "estonian", ""},
+	"fa": {"", "persian", "persianfont"},
+	"fi": {"finnish", "finnish", ""},
+	"fr": {"french", "french", ""},
+	"he": {"", "hebrew", "hebrewfont"},
+	"hr": {"croatian", "croatian", ""},
+	"hu": {"magyar", "hungarian", ""},
+	"is": {"icelandic", "icelandic", ""},
+	"it": {"italian", "italian", ""},
+	"lt": {"lithuanian", "lithuanian", ""},
+	"lv": {"latvian", "latvian", ""},
+	"nl": {"dutch", "dutch", ""},
+	"no": {"norsk", "norwegian", ""},
+	"pl": {"polish", "polish", ""},
+	"pt": {"portuguese", "portuguese", ""},
+	"ro": {"romanian", "romanian", ""},
+	"ru": {"russian", "russian", ""},
+	"sk": {"slovak", "slovak", ""},
+	"sl": {"slovene", "slovenian", ""},
+	"sq": {"albanian", "albanian", ""},
+	"sr": {"serbian", "serbian", ""},
+	"sv": {"swedish", "swedish", ""},
+	"tr": {"turkish", "turkish", ""},
+	"uk": {"ukrainian", "ukrainian", ""},
+	"ur": {"", "urdu", "urdufont"},
+	"vi": {"vietnamese", "vietnamese", ""},
+}
+
+// latexEscaper escapes the characters TeX treats specially
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"$", `\$`,
+	"&", `\&`,
+	"#", `\#`,
+	"%", `\%`,
+	"_", `\_`,
+	"^", `\textasciicircum{}`,
+	"~", `\textasciitilde{}`,
+)
+
+// LaTeX writes the book to w as LaTeX. Like the PDF, it sets the prayers
+// from the blocks they're parsed into, so footnotes become real footnotes
+// and refrains, instructions and citations are set apart the way the app
+// sets them apart. font is what XeLaTeX or LuaLaTeX set the prayers in, by
+// the name fontconfig knows it by; right to left languages need one for
+// their script.
+func (b Book) LaTeX(w io.Writer, font string) error {
+	settings, ok := latexLanguages[b.Language]
+	if !ok {
+		logger().Warn("no babel or polyglossia settings; hyphenating as English", "phase", "latex", "language", b.Language)
+		settings = latexLanguages["en"]
+	}
+	if settings.scriptFont != "" && font == "" {
+		return fmt.Errorf("typesetting '%s' %w", b.Language, ErrNoScriptFont)
+	}
+	bp, err := b.read()
+	if err != nil {
+		return err
+	}
+
+	tex := strings.Builder{}
+	latexPreamble(&tex, bp.lang, settings, font, b.ScraperVersion)
+	category := ""
+	for i := range bp.prayers {
+		if i == 0 || bp.categories[i] != category {
+			category = bp.categories[i]
+			fmt.Fprintf(&tex, "\n\\chapter{%s}\n", latexEscaper.Replace(category))
+		} else {
+			tex.WriteString("\n\\bigskip\n")
+		}
+		latexPrayer(&tex, b.Scraper, bp.parse(i), bp.lang)
+	}
+	tex.WriteString("\n\\end{document}\n")
+	_, err = io.WriteString(w, tex.String())
+	return err
+}
+
+// latexPreamble writes the preamble and title page. Left to right languages
+// with a babel name compile with pdfLaTeX as well as XeLaTeX and LuaLaTeX;
+// the rest need XeLaTeX.
+func latexPreamble(tex *strings.Builder, l bpnet.Language, settings latexLanguage, font, version string) {
+	tex.WriteString("% Generated by bpnet-scraper " + version + "\n")
+	tex.WriteString("\\documentclass[a5paper,11pt]{book}\n")
+	tex.WriteString("\\usepackage{iftex}\n")
+	polyglossia := func() {
+		tex.WriteString("\\usepackage{fontspec}\n")
+		if font != "" {
+			fmt.Fprintf(tex, "\\setmainfont{%s}\n", font)
+		}
+		tex.WriteString("\\usepackage{polyglossia}\n")
+		fmt.Fprintf(tex, "\\setmainlanguage{%s}\n", settings.polyglossia)
+		if settings.scriptFont != "" {
+			fmt.Fprintf(tex, "\\newfontfamily\\%s[Script=%s]{%s}\n", settings.scriptFont, latexScript(l.ISOName), font)
+		}
+	}
+	if settings.babel == "" {
+		tex.WriteString("\\RequireXeTeX\n")
+		polyglossia()
+	} else {
+		tex.WriteString("\\ifPDFTeX\n")
+		tex.WriteString("\\usepackage[utf8]{inputenc}\n")
+		tex.WriteString("\\usepackage[T1]{fontenc}\n")
+		fmt.Fprintf(tex, "\\usepackage[%s]{babel}\n", settings.babel)
+		tex.WriteString("\\else\n")
+		polyglossia()
+		tex.WriteString("\\fi\n")
+	}
+	tex.WriteString("\\usepackage{xcolor}\n")
+	tex.WriteString("\\usepackage{lettrine}\n")
+	fmt.Fprintf(tex, "\\title{%s}\n", latexEscaper.Replace(LanguageName(l.ISOName)))
+	tex.WriteString("\\author{}\n\\date{}\n")
+	tex.WriteString("\n\\begin{document}\n\\maketitle\n\\tableofcontents\n")
+}
+
+// latexScript names the script of a right to left language for fontspec
+func latexScript(lang string) string {
+	if lang == "he" {
+		return "Hebrew"
+	}
+	return "Arabic"
+}
+
+// latexPrayer writes a parsed prayer
+func latexPrayer(tex *strings.Builder, s *scraper.Scraper, doc markup.Document, l bpnet.Language) {
+	notes := make(map[string]string)
+	for _, b := range doc.Blocks {
+		if fns, ok := b.(markup.Footnotes); ok {
+			for _, fn := range fns.Notes {
+				notes[strconv.Itoa(fn.Number)] = fn.Text
+			}
+		}
+	}
+	inline := func(s string) string {
+		return latexInline(s, notes)
+	}
+
+	for _, b := range doc.Blocks {
+		switch b := b.(type) {
+		case markup.Title:
+			fmt.Fprintf(tex, "\\section*{%s}\n", inline(b.Text))
+		case markup.OpeningParagraph:
+			tex.WriteString("\\noindent ")
+			text := b.Text
+			if first, size := utf8.DecodeRuneInString(text); s.Versal(l, first) {
+				fmt.Fprintf(tex, "\\lettrine{%s}{}", latexEscaper.Replace(string(first)))
+				text = text[size:]
+			}
+			tex.WriteString(inline(text) + "\n\n")
+		case markup.BodyParagraph:
+			if b.Refrain {
+				fmt.Fprintf(tex, "\\begin{quote}\\itshape\n%s\n\\end{quote}\n", inline(b.Text))
+			} else {
+				tex.WriteString(inline(b.Text) + "\n\n")
+			}
+		case markup.Comment:
+			text := inline(b.Text)
+			if b.Caps {
+				text = "\\MakeUppercase{" + text + "}"
+			}
+			fmt.Fprintf(tex, "\\begin{center}\\small\\itshape\n%s\n\\end{center}\n", text)
+		case markup.Instruction:
+			fmt.Fprintf(tex, "{\\small\\itshape\\color{gray}%s\\par}\n", inline(b.Text))
+		case markup.Blockquote:
+			tex.WriteString("\\begin{quote}\n")
+			for i, p := range b.Paragraphs {
+				if i > 0 {
+					tex.WriteString("\n")
+				}
+				tex.WriteString(inline(p) + "\n")
+			}
+			tex.WriteString("\\end{quote}\n")
+		case markup.CenteredLine:
+			fmt.Fprintf(tex, "\\begin{center}\n%s\n\\end{center}\n", inline(b.Text))
+		case markup.Citation:
+			fmt.Fprintf(tex, "\\begin{flushright}\\small\\itshape\n%s\n\\end{flushright}\n", inline(b.Text))
+		}
+		// Footnotes are set as \footnote where they're referenced
+	}
+}
+
+// latexInline escapes s for TeX, converting the inline HTML tags it may
+// contain. Superscripts are footnote references, so they're replaced with the
+// footnote they refer to.
+func latexInline(s string, notes map[string]string) string {
+	tex := strings.Builder{}
+	last := 0
+	inSup := false
+	// unbalanced tags mustn't unbalance the braces
+	emph := 0
+	for _, loc := range markup.InlineTags.FindAllStringIndex(s, -1) {
+		text := s[last:loc[0]]
+		if inSup {
+			if note, ok := notes[strings.TrimSpace(text)]; ok {
+				tex.WriteString("\\footnote{" + latexInline(note, nil) + "}")
+			} else {
+				tex.WriteString("\\textsuperscript{" + latexEscaper.Replace(text) + "}")
+			}
+		} else {
+			tex.WriteString(latexEscaper.Replace(text))
+		}
+		switch tag := strings.ToLower(s[loc[0]:loc[1]]); {
+		case strings.HasPrefix(tag, "<br"):
+			tex.WriteString("\\\\\n")
+		case tag == "<sup>":
+			inSup = true
+		case tag == "</sup>":
+			inSup = false
+		case strings.HasPrefix(tag, "</"):
+			if emph > 0 {
+				tex.WriteString("}")
+				emph--
+			}
+		default:
+			tex.WriteString("\\emph{")
+			emph++
+		}
+		last = loc[1]
+	}
+	tex.WriteString(latexEscaper.Replace(s[last:]))
+	tex.WriteString(strings.Repeat("}", emph))
+	return tex.String()
+}
@@ -0,0 +1,14 @@
+package export
+
+import "log/slog"
+
+// Logger gets the warnings of the exports, with the phase they're about as
+// an attribute. When nil, slog.Default() is used.
+var Logger *slog.Logger
+
+func logger() *slog.Logger {
+	if Logger != nil {
+		return Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,576 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/markup"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+	"arashpayan.com/bpnet-scraper/scraper"
+)
+
+// Page geometry of the PDF, in points: A5, with room for a page number
+const (
+	pdfPageWidth  = 419.53
+	pdfPageHeight = 595.28
+	pdfMarginX    = 54
+	pdfMarginTop  = 60
+	pdfMarginEnd  = 66
+	pdfBodySize   = 11
+	pdfLeading    = 1.4
+)
+
+// pdfStyle is how a piece of text is set
+type pdfStyle struct {
+	font  *Font
+	size  float64
+	slant bool
+	bold  bool
+	gray  bool
+	rise  float64
+}
+
+// pdfBox is a piece of a word set in a single style, with its glyphs in the
+// order they're drawn
+type pdfBox struct {
+	glyphs []uint16
+	width  float64
+	style  pdfStyle
+}
+
+// pdfWord is a word as it's drawn. A nil word forces a line break.
+type pdfWord []pdfBox
+
+func (w pdfWord) width() float64 {
+	total := 0.0
+	for _, b := range w {
+		total += b.width
+	}
+	return total
+}
+
+// pdfAlign is where the lines of a paragraph go between its margins. Start
+// and end follow the direction of the language.
+type pdfAlign int
+
+const (
+	pdfAlignStart pdfAlign = iota
+	pdfAlignCenter
+	pdfAlignEnd
+)
+
+// pdfTypesetter lays out the prayers of a language onto pages
+type pdfTypesetter struct {
+	scraper         *scraper.Scraper
+	lang            bpnet.Language
+	regular, italic *Font
+	pages           []*bytes.Buffer
+	y               float64
+	// version names the build of the scraper as the producer of the PDF
+	version string
+}
+
+// Book is the prayers of a per-language database, to typeset as a PDF or a
+// LaTeX book with a chapter per category. The prayers are parsed again from
+// the text the API sent, with the markup settings of Scraper, so they're set
+// from the same blocks as the HTML, rather than from the HTML itself.
+type Book struct {
+	Scraper *scraper.Scraper
+	DB      *sql.DB
+	// Language is the ISO code of the language of the database
+	Language string
+	// ScraperVersion names the build that typeset the book, in the book
+	ScraperVersion string
+}
+
+// bookPrayers are the prayers of a Book, in the order they're typeset in
+type bookPrayers struct {
+	lang bpnet.Language
+	meta map[string]string
+	// prayers are prepared for markup, and categories and instructions are
+	// of the prayer at the same index
+	prayers      []prayerdb.Prayer
+	categories   []string
+	instructions []bool
+}
+
+// read reads the prayers of the book and prepares their text for markup
+func (b Book) read() (*bookPrayers, error) {
+	meta, err := prayerdb.ReadMeta(context.Background(), b.DB)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := strconv.Atoi(meta["languageID"])
+	bp := &bookPrayers{lang: bpnet.Language{ID: id, ISOName: b.Language, LeftToRight: !bpnet.RightToLeft(b.Language)}, meta: meta}
+
+	pr, categories, instructions, err := readPDFPrayers(b.DB)
+	if err != nil {
+		return nil, err
+	}
+	if len(pr.Prayers) == 0 {
+		return nil, fmt.Errorf("the database of '%s' has no prayers", b.Language)
+	}
+	b.Scraper.PrepareText(pr, bp.lang)
+	bp.prayers, bp.categories, bp.instructions = pr.Prayers, categories, instructions
+	return bp, nil
+}
+
+// parse parses the i'th prayer into blocks
+func (bp *bookPrayers) parse(i int) markup.Document {
+	doc := markup.Parse(bp.prayers[i].Text)
+	if bp.instructions[i] {
+		doc.MarkInstructions()
+	}
+	return doc
+}
+
+// PDF typesets the book as an A5 PDF, written to w. Only TrueType fonts are
+// supported, and for Arabic script they need the Arabic presentation forms.
+// Without an italic font, italics are slanted versions of the regular one.
+func (b Book) PDF(w io.Writer, regular, italic *Font) error {
+	bp, err := b.read()
+	if err != nil {
+		return err
+	}
+	ts := &pdfTypesetter{scraper: b.Scraper, lang: bp.lang, regular: regular, italic: italic, version: b.ScraperVersion}
+	if ts.italic == nil {
+		ts.italic = ts.regular
+	}
+
+	category := ""
+	for i := range bp.prayers {
+		if i == 0 || bp.categories[i] != category {
+			category = bp.categories[i]
+			ts.newPage()
+			ts.heading(category, 18)
+		}
+		ts.prayer(bp.parse(i))
+	}
+	_, err = w.Write(ts.write(bp.meta))
+	return err
+}
+
+// readPDFPrayers reads the text of the prayers of a per-language database as
+// the API sent it, along with the category of each and whether it has
+// instructions, in the order they're typeset in
+func readPDFPrayers(db *sql.DB) (*prayerdb.Scrape, []string, []bool, error) {
+	rows, err := db.Query(`SELECT id, category, rawText, hasInstructions FROM prayers WHERE deleted=0 ORDER BY category, sortKey, id`)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+
+	pr := &prayerdb.Scrape{}
+	var categories []string
+	var instructions []bool
+	for rows.Next() {
+		var p prayerdb.Prayer
+		var category string
+		var hasInstructions bool
+		if err := rows.Scan(&p.ID, &category, &p.Text, &hasInstructions); err != nil {
+			return nil, nil, nil, err
+		}
+		pr.Prayers = append(pr.Prayers, p)
+		categories = append(categories, category)
+		instructions = append(instructions, hasInstructions)
+	}
+	return pr, categories, instructions, rows.Err()
+}
+
+// prayer typesets a parsed prayer, keeping its first lines on one page
+func (ts *pdfTypesetter) prayer(doc markup.Document) {
+	body := pdfStyle{font: ts.regular, size: pdfBodySize}
+	small := pdfStyle{font: ts.italic, size: pdfBodySize - 2, slant: ts.italic == ts.regular}
+	if ts.y-4*pdfBodySize*pdfLeading < pdfMarginEnd {
+		ts.newPage()
+	} else {
+		ts.y -= 2 * pdfBodySize
+	}
+
+	for _, b := range doc.Blocks {
+		switch b := b.(type) {
+		case markup.Title:
+			ts.heading(b.Text, 13)
+		case markup.OpeningParagraph:
+			words := ts.words(b.Text, body)
+			if first, size := utf8.DecodeRuneInString(b.Text); ts.scraper.Versal(ts.lang, first) {
+				// the versal is set larger than the rest of the prayer
+				versal := body
+				versal.size *= 1.8
+				words = ts.words(b.Text[size:], body)
+				box := ts.box([]rune{first}, versal)
+				if len(words) == 0 || strings.HasPrefix(b.Text[size:], " ") {
+					words = append([]pdfWord{{box}}, words...)
+				} else {
+					words[0] = append(pdfWord{box}, words[0]...)
+				}
+			}
+			ts.paragraph(words, pdfAlignStart, 0)
+		case markup.BodyParagraph:
+			if b.Refrain {
+				ts.paragraph(ts.words(b.Text, ts.italicStyle(body)), pdfAlignStart, 18)
+			} else {
+				ts.paragraph(ts.words(b.Text, body), pdfAlignStart, 0)
+			}
+		case markup.Comment:
+			text := b.Text
+			if b.Caps {
+				text = strings.ToUpper(text)
+			}
+			ts.paragraph(ts.words(text, small), pdfAlignCenter, 0)
+		case markup.Instruction:
+			gray := small
+			gray.gray = true
+			ts.paragraph(ts.words(b.Text, gray), pdfAlignStart, 0)
+		case markup.Blockquote:
+			for _, p := range b.Paragraphs {
+				ts.paragraph(ts.words(p, body), pdfAlignStart, 24)
+			}
+		case markup.CenteredLine:
+			ts.paragraph(ts.words(b.Text, body), pdfAlignCenter, 0)
+		case markup.Citation:
+			ts.paragraph(ts.words(b.Text, small), pdfAlignEnd, 0)
+		case markup.Footnotes:
+			for _, fn := range b.Notes {
+				ts.paragraph(ts.words(fmt.Sprintf("%d. %s", fn.Number, fn.Text), small), pdfAlignStart, 0)
+			}
+		}
+	}
+}
+
+func (ts *pdfTypesetter) italicStyle(s pdfStyle) pdfStyle {
+	s.font = ts.italic
+	s.slant = ts.italic == ts.regular
+	return s
+}
+
+// heading typesets a centered heading in bold
+func (ts *pdfTypesetter) heading(text string, size float64) {
+	style := pdfStyle{font: ts.regular, size: size, bold: true}
+	ts.y -= size / 2
+	ts.paragraph(ts.words(text, style), pdfAlignCenter, 0)
+	ts.y -= size / 2
+}
+
+// words breaks marked up text into the words it's drawn as. Italic and
+// superscript tags change the style of the text they surround, and line
+// breaks become nil words.
+func (ts *pdfTypesetter) words(text string, style pdfStyle) []pdfWord {
+	var words []pdfWord
+	var word pdfWord
+	italic, sup := false, false
+	current := func() pdfStyle {
+		s := style
+		if italic {
+			s = ts.italicStyle(s)
+		}
+		if sup {
+			s.size *= 0.65
+			s.rise = style.size * 0.35
+		}
+		return s
+	}
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, word)
+			word = nil
+		}
+	}
+	addText := func(s string) {
+		var runes []rune
+		for _, r := range s {
+			if r == ' ' || r == '\t' {
+				if len(runes) > 0 {
+					word = append(word, ts.box(runes, current()))
+					runes = nil
+				}
+				flush()
+				continue
+			}
+			runes = append(runes, r)
+		}
+		if len(runes) > 0 {
+			word = append(word, ts.box(runes, current()))
+		}
+	}
+
+	last := 0
+	for _, loc := range markup.InlineTags.FindAllStringIndex(text, -1) {
+		addText(text[last:loc[0]])
+		last = loc[1]
+		tag := strings.ToLower(text[loc[0]:loc[1]])
+		switch {
+		case strings.HasPrefix(tag, "<br"):
+			flush()
+			words = append(words, nil)
+		case tag == "<sup>":
+			sup = true
+		case tag == "</sup>":
+			sup = false
+		default:
+			italic = !strings.HasPrefix(tag, "</")
+		}
+	}
+	addText(text[last:])
+	flush()
+
+	if !ts.lang.LeftToRight {
+		for _, w := range words {
+			for i, j := 0, len(w)-1; i < j; i, j = i+1, j-1 {
+				w[i], w[j] = w[j], w[i]
+			}
+		}
+	}
+	return words
+}
+
+// box shapes the runes of a piece of a word into glyphs
+func (ts *pdfTypesetter) box(runes []rune, style pdfStyle) pdfBox {
+	shaped := shapeArabic(runes, style.font.has)
+	if !ts.lang.LeftToRight {
+		shaped = visualOrder(shaped)
+	}
+	b := pdfBox{style: style}
+	for _, sr := range shaped {
+		g := style.font.glyph(sr.r, sr.text...)
+		b.glyphs = append(b.glyphs, g)
+		b.width += style.font.advance(g, style.size)
+	}
+	return b
+}
+
+// paragraph breaks words into lines that fit between the margins, less
+// indent, and draws them
+func (ts *pdfTypesetter) paragraph(words []pdfWord, align pdfAlign, indent float64) {
+	width := pdfPageWidth - 2*pdfMarginX - indent
+	var line []pdfWord
+	lineWidth := 0.0
+	for _, w := range words {
+		if w == nil {
+			ts.line(line, align, indent)
+			line, lineWidth = nil, 0
+			continue
+		}
+		space := ts.space(w)
+		if len(line) > 0 && lineWidth+space+w.width() > width {
+			ts.line(line, align, indent)
+			line, lineWidth = nil, 0
+		}
+		if len(line) > 0 {
+			lineWidth += space
+		}
+		line = append(line, w)
+		lineWidth += w.width()
+	}
+	if len(line) > 0 {
+		ts.line(line, align, indent)
+	}
+	ts.y -= pdfBodySize * 0.5
+}
+
+// space returns the width of the space before a word
+func (ts *pdfTypesetter) space(w pdfWord) float64 {
+	style := w[0].style
+	return style.font.advance(style.font.glyph(' '), style.size)
+}
+
+// line draws a line of words, starting a new page when it doesn't fit on
+// the current one
+func (ts *pdfTypesetter) line(words []pdfWord, align pdfAlign, indent float64) {
+	size := pdfBodySize * 0.8
+	width := 0.0
+	for i, w := range words {
+		for _, b := range w {
+			if b.style.size > size {
+				size = b.style.size
+			}
+		}
+		if i > 0 {
+			width += ts.space(w)
+		}
+		width += w.width()
+	}
+	height := size * pdfLeading
+	if len(words) > 0 && words[0][0].style.size > pdfBodySize*1.5 {
+		// a versal only needs the room above the line
+		height = pdfBodySize*pdfLeading + size - pdfBodySize
+	}
+	if ts.y-height < pdfMarginEnd {
+		ts.newPage()
+	}
+	ts.y -= height
+
+	if !ts.lang.LeftToRight {
+		reversed := make([]pdfWord, len(words))
+		for i, w := range words {
+			reversed[len(words)-1-i] = w
+		}
+		words = reversed
+		switch align {
+		case pdfAlignStart:
+			align = pdfAlignEnd
+		case pdfAlignEnd:
+			align = pdfAlignStart
+		}
+	}
+
+	x := float64(pdfMarginX)
+	switch align {
+	case pdfAlignStart:
+		if ts.lang.LeftToRight {
+			x += indent
+		}
+	case pdfAlignCenter:
+		x = (pdfPageWidth - width) / 2
+	case pdfAlignEnd:
+		x = pdfPageWidth - pdfMarginX - width
+		if !ts.lang.LeftToRight {
+			x -= indent
+		}
+	}
+
+	page := ts.pages[len(ts.pages)-1]
+	for i, w := range words {
+		if i > 0 {
+			x += ts.space(w)
+		}
+		for _, b := range w {
+			ts.draw(page, b, x, ts.y)
+			x += b.width
+		}
+	}
+}
+
+// draw writes the operators drawing a box with its baseline at x, y
+func (ts *pdfTypesetter) draw(page *bytes.Buffer, b pdfBox, x, y float64) {
+	font := "F1"
+	if b.style.font != ts.regular {
+		font = "F2"
+	}
+	skew := 0.0
+	if b.style.slant {
+		skew = 0.2
+	}
+	page.WriteString("BT\n")
+	if b.style.gray {
+		page.WriteString("0.4 g\n")
+	}
+	if b.style.bold {
+		fmt.Fprintf(page, "2 Tr %.2f w\n", b.style.size/30)
+	}
+	fmt.Fprintf(page, "/%s %.2f Tf\n1 0 %.2f 1 %.2f %.2f Tm\n", font, b.style.size, skew, x, y+b.style.rise)
+	page.WriteString("<")
+	for _, g := range b.glyphs {
+		fmt.Fprintf(page, "%04X", g)
+	}
+	page.WriteString("> Tj\nET\n")
+}
+
+// newPage starts a page, numbered at its foot
+func (ts *pdfTypesetter) newPage() {
+	page := &bytes.Buffer{}
+	ts.pages = append(ts.pages, page)
+	ts.y = pdfPageHeight - pdfMarginTop
+
+	number := ts.box([]rune(strconv.Itoa(len(ts.pages))), pdfStyle{font: ts.regular, size: 9})
+	ts.draw(page, number, (pdfPageWidth-number.width)/2, pdfMarginEnd/2)
+}
+
+// write assembles the pages and fonts into a PDF file
+func (ts *pdfTypesetter) write(meta map[string]string) []byte {
+	w := &pdfWriter{}
+	catalog, pagesRef := w.reserve(), w.reserve()
+
+	fonts := fmt.Sprintf("/F1 %d 0 R", w.font(ts.regular))
+	if ts.italic != ts.regular {
+		fonts += fmt.Sprintf(" /F2 %d 0 R", w.font(ts.italic))
+	}
+
+	var kids []string
+	for _, page := range ts.pages {
+		content := w.stream(page.Bytes(), "")
+		ref := w.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s >> >> /Contents %d 0 R >>", pagesRef, pdfPageWidth, pdfPageHeight, fonts, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", ref))
+	}
+	w.set(pagesRef, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	w.set(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /Lang %s >>", pagesRef, pdfString(ts.lang.ISOName)))
+	info := w.add(fmt.Sprintf("<< /Title %s /Producer %s >>", pdfString("Prayers ("+ts.lang.ISOName+")"), pdfString("bpnet-scraper "+ts.version)))
+	return w.bytes(catalog, info)
+}
+
+// pdfWriter collects the objects of a PDF file
+type pdfWriter struct {
+	objects []string
+}
+
+// reserve allocates an object number for an object that's set later
+func (w *pdfWriter) reserve() int {
+	w.objects = append(w.objects, "")
+	return len(w.objects)
+}
+
+func (w *pdfWriter) set(ref int, object string) {
+	w.objects[ref-1] = object
+}
+
+func (w *pdfWriter) add(object string) int {
+	ref := w.reserve()
+	w.set(ref, object)
+	return ref
+}
+
+// stream adds a stream object, compressed, with extra entries in its
+// dictionary
+func (w *pdfWriter) stream(data []byte, dict string) int {
+	compressed := bytes.Buffer{}
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+	return w.add(fmt.Sprintf("<< /Length %d /Filter /FlateDecode%s >>\nstream\n%s\nendstream", compressed.Len(), dict, compressed.String()))
+}
+
+// font embeds a TrueType font, with the widths and Unicode mappings of the
+// glyphs that were used
+func (w *pdfWriter) font(f *Font) int {
+	file := w.stream(f.data, fmt.Sprintf(" /Length1 %d", len(f.data)))
+	flags := 32 // nonsymbolic
+	if f.italicAngle != 0 {
+		flags |= 64
+	}
+	descriptor := w.add(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%.0f %.0f %.0f %.0f] /ItalicAngle %.2f /Ascent %.0f /Descent %.0f /CapHeight %.0f /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, flags, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]), f.italicAngle, f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), file))
+	cidFont := w.add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W %s >>", f.name, descriptor, f.widths()))
+	toUnicode := w.stream([]byte(f.toUnicode()), "")
+	return w.add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", f.name, cidFont, toUnicode))
+}
+
+// bytes writes out the file, with its cross-reference table
+func (w *pdfWriter) bytes(catalog, info int) []byte {
+	out := bytes.Buffer{}
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(w.objects))
+	for i, object := range w.objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(w.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.objects)+1, catalog, info, xref)
+	return out.Bytes()
+}
+
+// pdfString encodes s as a PDF text string, in UTF-16BE
+func pdfString(s string) string {
+	return "<FEFF" + utf16Hex([]rune(s)) + ">"
+}
@@ -0,0 +1,315 @@
+package export
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Font is a TrueType font embedded in a PDF. It's embedded whole, as a
+// CIDFont whose CIDs are its glyph IDs, so text is written as glyph IDs.
+// It records the glyphs a PDF uses, so a Font is only for one PDF.
+type Font struct {
+	name        string
+	data        []byte
+	unitsPerEm  float64
+	ascent      float64
+	descent     float64
+	capHeight   float64
+	bbox        [4]float64
+	italicAngle float64
+	// advances holds the advance width of each glyph, in font units
+	advances []uint16
+	// glyphs maps runes to glyph IDs
+	glyphs map[rune]uint16
+	// used records the glyphs written with the font, and the runes they
+	// stand for, for the widths and ToUnicode map of the PDF
+	used map[uint16][]rune
+}
+
+// LoadFont reads the tables of a TrueType font the PDF needs: its metrics,
+// and the Unicode cmap
+func LoadFont(path string) (*Font, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 {
+		return nil, fmt.Errorf("%s isn't a TrueType font", path)
+	}
+	if tag := string(data[:4]); tag != "\x00\x01\x00\x00" && tag != "true" {
+		return nil, fmt.Errorf("%s isn't a TrueType font", path)
+	}
+
+	tables := make(map[string][]byte)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, fmt.Errorf("%s is truncated", path)
+		}
+		offset := binary.BigEndian.Uint32(data[rec+8:])
+		length := binary.BigEndian.Uint32(data[rec+12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("%s is truncated", path)
+		}
+		tables[string(data[rec:rec+4])] = data[offset : offset+length]
+	}
+	for _, name := range []string{"head", "hhea", "hmtx", "maxp", "cmap"} {
+		if _, ok := tables[name]; !ok {
+			return nil, fmt.Errorf("%s has no %s table", path, name)
+		}
+	}
+
+	f := &Font{data: data, used: make(map[uint16][]rune)}
+	head, hhea := tables["head"], tables["hhea"]
+	if len(head) < 54 || len(hhea) < 36 || len(tables["maxp"]) < 6 {
+		return nil, fmt.Errorf("%s is malformed", path)
+	}
+	f.unitsPerEm = float64(binary.BigEndian.Uint16(head[18:]))
+	for i := range f.bbox {
+		f.bbox[i] = float64(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+	f.ascent = float64(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = float64(int16(binary.BigEndian.Uint16(hhea[6:])))
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = float64(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	if post := tables["post"]; len(post) >= 8 {
+		f.italicAngle = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+	}
+
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := tables["hmtx"]
+	if numHMetrics == 0 || len(hmtx) < 4*numHMetrics {
+		return nil, fmt.Errorf("%s has a malformed hmtx table", path)
+	}
+	f.advances = make([]uint16, numGlyphs)
+	for i := range f.advances {
+		if i < numHMetrics {
+			f.advances[i] = binary.BigEndian.Uint16(hmtx[4*i:])
+		} else {
+			f.advances[i] = f.advances[numHMetrics-1]
+		}
+	}
+
+	f.glyphs, err = parseCmap(tables["cmap"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	f.name = fontName(tables["name"], path)
+	return f, nil
+}
+
+// parseCmap reads the Unicode subtable of a cmap table, preferring the full
+// repertoire format 12 over the BMP only format 4
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, fmt.Errorf("malformed cmap table")
+	}
+	var format4, format12 []byte
+	n := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < n && 4+8*i+8 <= len(cmap); i++ {
+		rec := cmap[4+8*i:]
+		platform, encoding := binary.BigEndian.Uint16(rec), binary.BigEndian.Uint16(rec[2:])
+		offset := binary.BigEndian.Uint32(rec[4:])
+		if int(offset)+4 > len(cmap) {
+			continue
+		}
+		unicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		if !unicode {
+			continue
+		}
+		sub := cmap[offset:]
+		switch binary.BigEndian.Uint16(sub) {
+		case 4:
+			format4 = sub
+		case 12:
+			format12 = sub
+		}
+	}
+
+	glyphs := make(map[rune]uint16)
+	switch {
+	case len(format12) >= 16:
+		groups := int(binary.BigEndian.Uint32(format12[12:]))
+		for i := 0; i < groups && 16+12*i+12 <= len(format12); i++ {
+			g := format12[16+12*i:]
+			start, end, glyph := binary.BigEndian.Uint32(g), binary.BigEndian.Uint32(g[4:]), binary.BigEndian.Uint32(g[8:])
+			for r := start; r <= end && r-start < 0x10000; r++ {
+				glyphs[rune(r)] = uint16(glyph + r - start)
+			}
+		}
+	case len(format4) >= 14:
+		segs := int(binary.BigEndian.Uint16(format4[6:])) / 2
+		if len(format4) < 16+8*segs {
+			return nil, fmt.Errorf("malformed cmap subtable")
+		}
+		ends, starts := format4[14:], format4[16+2*segs:]
+		deltas, rangeOffsets := format4[16+4*segs:], format4[16+6*segs:]
+		for s := 0; s < segs; s++ {
+			end, start := binary.BigEndian.Uint16(ends[2*s:]), binary.BigEndian.Uint16(starts[2*s:])
+			delta, rangeOffset := binary.BigEndian.Uint16(deltas[2*s:]), int(binary.BigEndian.Uint16(rangeOffsets[2*s:]))
+			for c := uint32(start); c <= uint32(end) && c != 0xFFFF; c++ {
+				glyph := uint16(0)
+				if rangeOffset == 0 {
+					glyph = uint16(c) + delta
+				} else {
+					at := 16 + 6*segs + 2*s + rangeOffset + 2*int(c-uint32(start))
+					if at+2 > len(format4) {
+						continue
+					}
+					if glyph = binary.BigEndian.Uint16(format4[at:]); glyph != 0 {
+						glyph += delta
+					}
+				}
+				if glyph != 0 {
+					glyphs[rune(c)] = glyph
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("no Unicode cmap")
+	}
+	return glyphs, nil
+}
+
+// fontName returns the PostScript name of a font from its name table,
+// falling back to the name of its file
+func fontName(name []byte, path string) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r > ' ' && r < 0x7f && !strings.ContainsRune("[](){}<>/%#", r) {
+				return r
+			}
+			return -1
+		}, s)
+	}
+	if len(name) >= 6 {
+		count := int(binary.BigEndian.Uint16(name[2:]))
+		storage := int(binary.BigEndian.Uint16(name[4:]))
+		for i := 0; i < count && 6+12*i+12 <= len(name); i++ {
+			rec := name[6+12*i:]
+			if binary.BigEndian.Uint16(rec[6:]) != 6 {
+				continue
+			}
+			length, offset := int(binary.BigEndian.Uint16(rec[8:])), int(binary.BigEndian.Uint16(rec[10:]))
+			if storage+offset+length > len(name) {
+				continue
+			}
+			raw := name[storage+offset : storage+offset+length]
+			if binary.BigEndian.Uint16(rec) == 3 {
+				// UTF-16BE; PostScript names are ASCII
+				ascii := make([]byte, 0, length/2)
+				for j := 1; j < len(raw); j += 2 {
+					ascii = append(ascii, raw[j])
+				}
+				raw = ascii
+			}
+			if n := clean(string(raw)); n != "" {
+				return n
+			}
+		}
+	}
+	base := path[strings.LastIndex(path, "/")+1:]
+	return clean(strings.TrimSuffix(base, ".ttf"))
+}
+
+// glyph returns the glyph of r, noting that it's used for text, which is r
+// itself unless r is a presentation form. Runes the font has no glyph for
+// come out as its .notdef glyph.
+func (f *Font) glyph(r rune, text ...rune) uint16 {
+	g := f.glyphs[r]
+	if len(text) == 0 {
+		text = []rune{r}
+	}
+	if _, ok := f.used[g]; !ok {
+		f.used[g] = text
+	}
+	return g
+}
+
+// has reports whether the font has a glyph for r
+func (f *Font) has(r rune) bool {
+	_, ok := f.glyphs[r]
+	return ok
+}
+
+// advance returns the advance width of glyph g at size points
+func (f *Font) advance(g uint16, size float64) float64 {
+	if int(g) >= len(f.advances) {
+		return 0
+	}
+	return float64(f.advances[g]) * size / f.unitsPerEm
+}
+
+// scale converts font units to the thousandths of an em PDF measures fonts in
+func (f *Font) scale(v float64) float64 {
+	return v * 1000 / f.unitsPerEm
+}
+
+// widths returns the W array of the CIDFont, for the glyphs that were used
+func (f *Font) widths() string {
+	var glyphs []int
+	for g := range f.used {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+	w := strings.Builder{}
+	w.WriteString("[")
+	for _, g := range glyphs {
+		fmt.Fprintf(&w, " %d [%.0f]", g, f.scale(float64(f.advances[g])))
+	}
+	w.WriteString(" ]")
+	return w.String()
+}
+
+// toUnicode returns the ToUnicode CMap of the font, so text copied out of
+// the PDF or searched for in it maps back to the runes it was written from
+func (f *Font) toUnicode() string {
+	var glyphs []int
+	for g := range f.used {
+		if g != 0 {
+			glyphs = append(glyphs, int(g))
+		}
+	}
+	sort.Ints(glyphs)
+
+	cmap := strings.Builder{}
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	cmap.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	cmap.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	cmap.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for len(glyphs) > 0 {
+		n := len(glyphs)
+		if n > 100 {
+			n = 100
+		}
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", n)
+		for _, g := range glyphs[:n] {
+			fmt.Fprintf(&cmap, "<%04X> <%s>\n", g, utf16Hex(f.used[uint16(g)]))
+		}
+		cmap.WriteString("endbfchar\n")
+		glyphs = glyphs[n:]
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return cmap.String()
+}
+
+// utf16Hex encodes runes as UTF-16BE hex digits
+func utf16Hex(runes []rune) string {
+	hex := strings.Builder{}
+	for _, r := range runes {
+		if r >= 0x10000 {
+			r -= 0x10000
+			fmt.Fprintf(&hex, "%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		} else {
+			fmt.Fprintf(&hex, "%04X", r)
+		}
+	}
+	return hex.String()
+}
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// plistTables are the tables of merged.db the plist export mirrors, with
+// the order of their rows
+var plistTables = []struct {
+	name    string
+	orderBy string
+}{
+	{"prayers", "id"},
+	{"tags", "language, id"},
+	{"prayer_tags", "prayerId, position"},
+	{"authors", "language, id"},
+	{"removed_prayers", "id"},
+}
+
+// Plist writes the contents of a merged database to w as prayers.plist, an
+// XML property list. Its root dictionary has the meta table as a
+// dictionary, and every other table as an array of dictionaries, one per
+// row, keyed by column.
+func Plist(db *sql.DB, out io.Writer) error {
+	w := bufio.NewWriter(out)
+	if err := writePlist(db, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writePlist writes the plist of a merged database
+func writePlist(db *sql.DB, w *bufio.Writer) error {
+	w.WriteString(xml.Header)
+	w.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	w.WriteString("<plist version=\"1.0\">\n<dict>\n")
+
+	meta, err := prayerdb.ReadMeta(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	plistKey(w, 1, "meta")
+	w.WriteString("\t<dict>\n")
+	for _, key := range keys {
+		plistKey(w, 2, key)
+		plistValue(w, 2, meta[key])
+	}
+	w.WriteString("\t</dict>\n")
+
+	for _, table := range plistTables {
+		plistKey(w, 1, table.name)
+		if err := plistTable(db, w, table.name, table.orderBy); err != nil {
+			return err
+		}
+	}
+	w.WriteString("</dict>\n</plist>\n")
+	return nil
+}
+
+// plistTable writes the rows of a table as an array of dictionaries
+func plistTable(db *sql.DB, w *bufio.Writer, table, orderBy string) error {
+	rows, err := db.Query(fmt.Sprintf(`SELECT * FROM %s ORDER BY %s`, table, orderBy))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	w.WriteString("\t<array>\n")
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		w.WriteString("\t\t<dict>\n")
+		for i, column := range columns {
+			// plists have no null, so NULL columns are left out
+			if values[i] == nil {
+				continue
+			}
+			plistKey(w, 3, column)
+			plistValue(w, 3, values[i])
+		}
+		w.WriteString("\t\t</dict>\n")
+	}
+	w.WriteString("\t</array>\n")
+	return rows.Err()
+}
+
+func plistKey(w *bufio.Writer, depth int, key string) {
+	w.WriteString(strings.Repeat("\t", depth) + "<key>")
+	xml.EscapeText(w, []byte(key))
+	w.WriteString("</key>\n")
+}
+
+// plistValue writes a value of a column as the plist type matching its
+// SQLite type
+func plistValue(w *bufio.Writer, depth int, value interface{}) {
+	w.WriteString(strings.Repeat("\t", depth))
+	switch v := value.(type) {
+	case int64:
+		fmt.Fprintf(w, "<integer>%d</integer>\n", v)
+	case float64:
+		fmt.Fprintf(w, "<real>%s</real>\n", strconv.FormatFloat(v, 'g', -1, 64))
+	case []byte:
+		fmt.Fprintf(w, "<data>%s</data>\n", base64.StdEncoding.EncodeToString(v))
+	case string:
+		w.WriteString("<string>")
+		xml.EscapeText(w, []byte(v))
+		w.WriteString("</string>\n")
+	default:
+		w.WriteString("<string>")
+		xml.EscapeText(w, []byte(fmt.Sprint(v)))
+		w.WriteString("</string>\n")
+	}
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"database/sql"
+	"io"
+
+	"arashpayan.com/bpnet-scraper/prayerpb"
+)
+
+// Protobuf writes the prayers of a merged database to w as prayers.pb, a
+// Corpus message of prayerpb.Schema
+func Protobuf(db *sql.DB, w io.Writer) error {
+	corpus, err := encodeCorpus(db)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(corpus)
+	return err
+}
+
+// encodeCorpus encodes the Corpus message of a merged database
+func encodeCorpus(db *sql.DB) (prayerpb.Message, error) {
+	export, err := readJSONExport(db)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := protoTags(db)
+	if err != nil {
+		return nil, err
+	}
+	authors, err := protoAuthors(db)
+	if err != nil {
+		return nil, err
+	}
+	tagIDs, err := prayerTagIDs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var corpus prayerpb.Message
+	corpus.String(1, export.ScraperVersion)
+	corpus.String(2, export.MergedAt)
+	for _, l := range export.Languages {
+		var lang prayerpb.Message
+		lang.String(1, l.Language)
+		lang.String(2, l.ScrapedAt)
+		for _, t := range tags[l.Language] {
+			lang.Embed(3, t)
+		}
+		for _, a := range authors[l.Language] {
+			lang.Embed(4, a)
+		}
+		for _, c := range l.Categories {
+			for _, p := range c.Prayers {
+				lang.Embed(5, ProtoPrayer(c.Name, p, tagIDs[p.ID]))
+			}
+		}
+		corpus.Embed(3, lang)
+	}
+	return corpus, nil
+}
+
+// ProtoPrayer encodes the Prayer message of a prayer of category, with the
+// IDs of its tags in order
+func ProtoPrayer(category string, p Prayer, tagIDs []int) prayerpb.Message {
+	var prayer prayerpb.Message
+	prayer.Int32(1, p.ID)
+	prayer.String(2, category)
+	prayer.String(3, p.OpeningWords)
+	prayer.String(4, p.PrayerText)
+	prayer.String(5, p.PlainText)
+	prayer.String(6, p.Citation)
+	prayer.String(7, p.Author)
+	prayer.Int32(8, p.AuthorID)
+	prayer.Int32(9, p.WordCount)
+	prayer.Bool(10, p.HasInstructions)
+	for _, fn := range p.Footnotes {
+		var note prayerpb.Message
+		note.Int32(1, fn.Number)
+		note.String(2, fn.Text)
+		prayer.Embed(11, note)
+	}
+	prayer.Int32(12, p.DuplicateOf)
+	prayer.String(13, p.ContentHash)
+	prayer.String(14, p.CreatedAt)
+	prayer.String(15, p.UpdatedAt)
+	prayer.Packed(16, tagIDs)
+	return prayer
+}
+
+// protoTags encodes the Tag messages of each language
+func protoTags(db *sql.DB) (map[string][]prayerpb.Message, error) {
+	rows, err := db.Query(`SELECT language, id, name, kind FROM tags ORDER BY language, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]prayerpb.Message)
+	for rows.Next() {
+		var lang, name, kind string
+		var id int
+		if err := rows.Scan(&lang, &id, &name, &kind); err != nil {
+			return nil, err
+		}
+		var tag prayerpb.Message
+		tag.Int32(1, id)
+		tag.String(2, name)
+		tag.String(3, kind)
+		tags[lang] = append(tags[lang], tag)
+	}
+	return tags, rows.Err()
+}
+
+// protoAuthors encodes the Author messages of each language
+func protoAuthors(db *sql.DB) (map[string][]prayerpb.Message, error) {
+	rows, err := db.Query(`SELECT language, id, name, localizedName FROM authors ORDER BY language, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := make(map[string][]prayerpb.Message)
+	for rows.Next() {
+		var lang, name, localizedName string
+		var id int
+		if err := rows.Scan(&lang, &id, &name, &localizedName); err != nil {
+			return nil, err
+		}
+		var author prayerpb.Message
+		author.Int32(1, id)
+		author.String(2, name)
+		author.String(3, localizedName)
+		authors[lang] = append(authors[lang], author)
+	}
+	return authors, rows.Err()
+}
+
+// prayerTagIDs returns the IDs of the tags of each prayer, in order
+func prayerTagIDs(db *sql.DB) (map[int][]int, error) {
+	rows, err := db.Query(`SELECT prayerId, tagId FROM prayer_tags ORDER BY prayerId, position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int][]int)
+	for rows.Next() {
+		var prayerID, tagID int
+		if err := rows.Scan(&prayerID, &tagID); err != nil {
+			return nil, err
+		}
+		ids[prayerID] = append(ids[prayerID], tagID)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,200 @@
+package export
+
+import "unicode"
+
+// arabicForms are the presentation forms of an Arabic letter. Letters that
+// only join the letter before them have no initial or medial form, and
+// letters that don't join at all only have an isolated one.
+type arabicForms struct {
+	isolated, final, initial, medial rune
+}
+
+// arabicLetters maps the letters used by Arabic and Persian to their
+// presentation forms, which fonts without shaping tables can still draw
+var arabicLetters = map[rune]arabicForms{
+	0x0621: {0xFE80, 0, 0, 0},
+	0x0622: {0xFE81, 0xFE82, 0, 0},
+	0x0623: {0xFE83, 0xFE84, 0, 0},
+	0x0624: {0xFE85, 0xFE86, 0, 0},
+	0x0625: {0xFE87, 0xFE88, 0, 0},
+	0x0626: {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C},
+	0x0627: {0xFE8D, 0xFE8E, 0, 0},
+	0x0628: {0xFE8F, 0xFE90, 0xFE91, 0xFE92},
+	0x0629: {0xFE93, 0xFE94, 0, 0},
+	0x062A: {0xFE95, 0xFE96, 0xFE97, 0xFE98},
+	0x062B: {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C},
+	0x062C: {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0},
+	0x062D: {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4},
+	0x062E: {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8},
+	0x062F: {0xFEA9, 0xFEAA, 0, 0},
+	0x0630: {0xFEAB, 0xFEAC, 0, 0},
+	0x0631: {0xFEAD, 0xFEAE, 0, 0},
+	0x0632: {0xFEAF, 0xFEB0, 0, 0},
+	0x0633: {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4},
+	0x0634: {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8},
+	0x0635: {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC},
+	0x0636: {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0},
+	0x0637: {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4},
+	0x0638: {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8},
+	0x0639: {0xFEC9, 0xFECA, 0xFECB, 0xFECC},
+	0x063A: {0xFECD, 0xFECE, 0xFECF, 0xFED0},
+	0x0641: {0xFED1, 0xFED2, 0xFED3, 0xFED4},
+	0x0642: {0xFED5, 0xFED6, 0xFED7, 0xFED8},
+	0x0643: {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC},
+	0x0644: {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0},
+	0x0645: {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4},
+	0x0646: {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8},
+	0x0647: {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC},
+	0x0648: {0xFEED, 0xFEEE, 0, 0},
+	0x0649: {0xFEEF, 0xFEF0, 0, 0},
+	0x064A: {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4},
+	0x067E: {0xFB56, 0xFB57, 0xFB58, 0xFB59},
+	0x0686: {0xFB7A, 0xFB7B, 0xFB7C, 0xFB7D},
+	0x0698: {0xFB8A, 0xFB8B, 0, 0},
+	0x06A9: {0xFB8E, 0xFB8F, 0xFB90, 0xFB91},
+	0x06AF: {0xFB92, 0xFB93, 0xFB94, 0xFB95},
+	0x06CC: {0xFBFC, 0xFBFD, 0xFBFE, 0xFBFF},
+}
+
+// lamAlef maps the alefs that form a ligature after lam to the isolated form
+// of the ligature. The final form follows it.
+var lamAlef = map[rune]rune{
+	0x0622: 0xFEF5,
+	0x0623: 0xFEF7,
+	0x0625: 0xFEF9,
+	0x0627: 0xFEFB,
+}
+
+const (
+	arabicLam   = 0x0644
+	tatweel     = 0x0640
+	zeroWidthNJ = 0x200C
+)
+
+// shapedRune is a rune to draw, along with the text it stands for
+type shapedRune struct {
+	r    rune
+	text []rune
+}
+
+// joinsBefore reports whether r can connect to the letter before it
+func joinsBefore(r rune) bool {
+	return r == tatweel || arabicLetters[r].final != 0
+}
+
+// joinsAfter reports whether r can connect to the letter after it
+func joinsAfter(r rune) bool {
+	return r == tatweel || arabicLetters[r].initial != 0
+}
+
+// transparent reports whether r is a mark that joining looks through
+func transparent(r rune) bool {
+	return unicode.Is(unicode.Mn, r)
+}
+
+// shapeArabic replaces the Arabic letters of s with the presentation form
+// for their position in the word, the way a shaping engine would, using the
+// glyphs font has. Everything else passes through as it is.
+func shapeArabic(s []rune, has func(rune) bool) []shapedRune {
+	neighbor := func(i, step int) rune {
+		for i += step; i >= 0 && i < len(s); i += step {
+			if !transparent(s[i]) {
+				return s[i]
+			}
+		}
+		return 0
+	}
+
+	var shaped []shapedRune
+	for i := 0; i < len(s); i++ {
+		r := s[i]
+		if r == zeroWidthNJ {
+			continue
+		}
+		forms, ok := arabicLetters[r]
+		if !ok {
+			shaped = append(shaped, shapedRune{r, []rune{r}})
+			continue
+		}
+		prev := joinsAfter(neighbor(i, -1))
+
+		if r == arabicLam && i+1 < len(s) {
+			if ligature, ok := lamAlef[s[i+1]]; ok {
+				if prev {
+					ligature++
+				}
+				if has(ligature) {
+					shaped = append(shaped, shapedRune{ligature, []rune{r, s[i+1]}})
+					i++
+					continue
+				}
+			}
+		}
+
+		next := joinsBefore(neighbor(i, 1))
+		form := forms.isolated
+		switch {
+		case prev && next && forms.medial != 0:
+			form = forms.medial
+		case prev && forms.final != 0:
+			form = forms.final
+		case next && forms.initial != 0:
+			form = forms.initial
+		}
+		if !has(form) {
+			form = r
+		}
+		shaped = append(shaped, shapedRune{form, []rune{r}})
+	}
+	return shaped
+}
+
+// mirrored pairs the brackets that are drawn flipped in right to left text
+var mirrored = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '«': '»', '»': '«', '<': '>', '>': '<'}
+
+// leftToRightRun reports whether r is written left to right even within
+// right to left text, like digits and Latin letters
+func leftToRightRun(r rune) bool {
+	return unicode.IsDigit(r) || (r < 0x0590 && unicode.IsLetter(r))
+}
+
+// visualOrder reverses the runes of a right to left word into the order
+// they're drawn in, from left to right. Marks stay after the letter they're
+// on, and runs of digits or Latin letters keep their own order.
+func visualOrder(s []shapedRune) []shapedRune {
+	var clusters [][]shapedRune
+	for _, sr := range s {
+		if n := len(clusters); n > 0 && transparent(sr.r) {
+			clusters[n-1] = append(clusters[n-1], sr)
+			continue
+		}
+		if m, ok := mirrored[sr.r]; ok {
+			sr = shapedRune{m, sr.text}
+		}
+		clusters = append(clusters, []shapedRune{sr})
+	}
+
+	for i, j := 0, len(clusters)-1; i < j; i, j = i+1, j-1 {
+		clusters[i], clusters[j] = clusters[j], clusters[i]
+	}
+	for start := 0; start < len(clusters); {
+		if !leftToRightRun(clusters[start][0].r) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(clusters) && leftToRightRun(clusters[end][0].r) {
+			end++
+		}
+		for i, j := start, end-1; i < j; i, j = i+1, j-1 {
+			clusters[i], clusters[j] = clusters[j], clusters[i]
+		}
+		start = end
+	}
+
+	var visual []shapedRune
+	for _, c := range clusters {
+		visual = append(visual, c...)
+	}
+	return visual
+}
@@ -0,0 +1,276 @@
+package export
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// siteTemplates lay out the pages of the static site. Every page is a
+// "page" wrapping the body of its own template.
+const siteTemplates = `
+{{define "page"}}<!DOCTYPE html>
+<html lang="{{.Lang}}" dir="{{.Dir}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Root}}style.css">
+</head>
+<body>
+{{if .Up}}<nav><a href="{{.Up}}">{{.UpTitle}}</a></nav>{{end}}
+<h1>{{.Title}}</h1>
+{{template "body" .Body}}
+</body>
+</html>
+{{end}}
+{{define "languages"}}<ul>{{range .}}<li><a href="{{.Code}}/index.html" lang="{{.Code}}">{{.Name}}</a> ({{.Count}})</li>{{end}}</ul>{{end}}
+{{define "language"}}<input type="search" id="search" placeholder="&#x1F50D;" autocomplete="off">
+<ol id="results"></ol>
+<ul>{{range .}}<li><a href="{{.File}}">{{.Name}}</a> ({{len .Prayers}})</li>{{end}}</ul>
+<script src="../search.js"></script>{{end}}
+{{define "category"}}<ul>{{range .}}<li><a href="{{.ID}}.html">{{.OpeningWords}}</a>{{if .Author}} — {{.Author}}{{end}}</li>{{end}}</ul>{{end}}
+{{define "prayer"}}<article>{{.Text}}</article>
+{{if .Author}}<p class="author">{{.Author}}</p>{{end}}
+{{if .Citation}}<p class="citation">{{.Citation}}</p>{{end}}{{end}}
+`
+
+// siteStyle is style.css, shared by every page
+const siteStyle = `body { max-width: 40em; margin: 2em auto; padding: 0 1em; font-family: Georgia, serif; line-height: 1.5; }
+nav { font-size: 0.9em; }
+.author, .citation { font-style: italic; text-align: end; }
+.opening:first-letter, .versal { font-size: 2em; }
+.centered { text-align: center; }
+.comment, .instruction { font-size: 0.9em; color: #555; }
+#search { width: 100%; font-size: 1.1em; }
+`
+
+// siteSearch is search.js. It loads the search index of the language page
+// it's on, and lists the prayers containing every word typed, folding the
+// query the way searchText is folded.
+const siteSearch = `(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+  var index = null;
+  function fold(s) {
+    return s.toLowerCase().normalize("NFD").replace(/[\u0300-\u036f]/g, "").replace(/['’‘ʼ` + "`" + `]/g, "");
+  }
+  function show() {
+    var words = fold(input.value).split(/\s+/).filter(Boolean);
+    results.textContent = "";
+    if (!index || words.length === 0) return;
+    index.forEach(function (p) {
+      if (!words.every(function (w) { return p.text.indexOf(w) >= 0; })) return;
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = p.id + ".html";
+      a.textContent = p.openingWords;
+      li.appendChild(a);
+      results.appendChild(li);
+    });
+  }
+  fetch("search.json").then(function (r) { return r.json(); }).then(function (i) { index = i; show(); });
+  input.addEventListener("input", show);
+})();
+`
+
+// sitePage is passed to the "page" template. Root is the relative path to
+// the top of the site, and Up links the page to its parent.
+type sitePage struct {
+	Lang    string
+	Dir     string
+	Title   string
+	Root    string
+	Up      string
+	UpTitle string
+	Body    interface{}
+}
+
+type siteLanguage struct {
+	Code       string
+	Name       string
+	Count      int
+	Categories []*siteCategory
+}
+
+type siteCategory struct {
+	Name    string
+	File    string
+	Prayers []sitePrayer
+}
+
+type sitePrayer struct {
+	ID           int           `json:"id"`
+	OpeningWords string        `json:"openingWords"`
+	Author       string        `json:"-"`
+	Citation     template.HTML `json:"-"`
+	Text         template.HTML `json:"-"`
+	SearchText   string        `json:"text"`
+}
+
+// Site renders the prayers of a merged database into a static website in
+// dir: an index of the languages, and for each language an index of its
+// categories, a page per category and prayer, and the search index its
+// index page searches. The prayer text of the database is trusted as HTML.
+func Site(db *sql.DB, dir string) error {
+	langs, err := readSiteLanguages(db)
+	if err != nil {
+		return err
+	}
+
+	tmpl := template.Must(template.New("site").Parse(siteTemplates))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, content := range map[string]string{"style.css": siteStyle, "search.js": siteSearch} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	err = writeSitePage(tmpl, filepath.Join(dir, "index.html"), "languages", sitePage{Lang: "en", Dir: "ltr", Title: "Prayers", Body: langs})
+	if err != nil {
+		return err
+	}
+
+	for _, l := range langs {
+		langDir := filepath.Join(dir, l.Code)
+		if err := os.MkdirAll(langDir, 0755); err != nil {
+			return err
+		}
+		page := sitePage{Lang: l.Code, Dir: "ltr", Root: "../"}
+		if bpnet.RightToLeft(l.Code) {
+			page.Dir = "rtl"
+		}
+
+		var index []sitePrayer
+		for _, c := range l.Categories {
+			for _, p := range c.Prayers {
+				prayerPage := page
+				prayerPage.Title, prayerPage.Up, prayerPage.UpTitle, prayerPage.Body = p.OpeningWords, c.File, c.Name, p
+				if err := writeSitePage(tmpl, filepath.Join(langDir, fmt.Sprintf("%d.html", p.ID)), "prayer", prayerPage); err != nil {
+					return err
+				}
+				index = append(index, p)
+			}
+
+			categoryPage := page
+			categoryPage.Title, categoryPage.Up, categoryPage.UpTitle, categoryPage.Body = c.Name, "index.html", l.Name, c.Prayers
+			if err := writeSitePage(tmpl, filepath.Join(langDir, c.File), "category", categoryPage); err != nil {
+				return err
+			}
+		}
+
+		page.Title, page.Up, page.UpTitle, page.Body = l.Name, "../index.html", "Prayers", l.Categories
+		if err := writeSitePage(tmpl, filepath.Join(langDir, "index.html"), "language", page); err != nil {
+			return err
+		}
+		buf, err := json.Marshal(index)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(langDir, "search.json"), buf, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSiteLanguages reads the prayers of a merged database, grouped by
+// language and category and ordered the way the app lists them
+func readSiteLanguages(db *sql.DB) ([]*siteLanguage, error) {
+	rows, err := db.Query(`SELECT language, category, id, openingWords, author, citation, prayerText, searchText FROM prayers ORDER BY language, category, sortKey, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var langs []*siteLanguage
+	var files map[string]bool
+	for rows.Next() {
+		var lang, category, citation, text string
+		var p sitePrayer
+		if err := rows.Scan(&lang, &category, &p.ID, &p.OpeningWords, &p.Author, &citation, &text, &p.SearchText); err != nil {
+			return nil, err
+		}
+		p.Citation, p.Text = template.HTML(citation), template.HTML(text)
+
+		if len(langs) == 0 || langs[len(langs)-1].Code != lang {
+			langs = append(langs, &siteLanguage{Code: lang, Name: LanguageName(lang)})
+			files = make(map[string]bool)
+		}
+		l := langs[len(langs)-1]
+		l.Count++
+		if len(l.Categories) == 0 || l.Categories[len(l.Categories)-1].Name != category {
+			l.Categories = append(l.Categories, &siteCategory{Name: category, File: categoryFile(category, files)})
+		}
+		c := l.Categories[len(l.Categories)-1]
+		c.Prayers = append(c.Prayers, p)
+	}
+	return langs, rows.Err()
+}
+
+// LanguageName returns the name of a language in itself, or its code if
+// x/text doesn't know it
+func LanguageName(code string) string {
+	tag, err := language.Parse(code)
+	if err != nil {
+		return code
+	}
+	if name := display.Self.Name(tag); name != "" {
+		return name
+	}
+	return code
+}
+
+// categoryFile names the page of a category after it, numbering the names
+// that would otherwise be taken by another category or a prayer page
+func categoryFile(category string, taken map[string]bool) string {
+	slug := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, category)
+	slug = strings.Trim(slug, "-")
+	if slug == "" || slug == "index" || unicode.IsDigit([]rune(slug)[0]) {
+		slug = "category-" + slug
+	}
+
+	file := slug + ".html"
+	for n := 2; taken[file]; n++ {
+		file = fmt.Sprintf("%s-%d.html", slug, n)
+	}
+	taken[file] = true
+	return file
+}
+
+// writeSitePage renders a page of the site, with the template named body as
+// its contents
+func writeSitePage(tmpl *template.Template, path, body string, page sitePage) error {
+	t, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	if _, err := t.AddParseTree("body", tmpl.Lookup(body).Tree); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := t.ExecuteTemplate(f, "page", page); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,221 @@
+package export
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// The SQL dialects SQL can write. SQLite's is close enough to standard SQL
+// that most other databases load it as it is.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+)
+
+// sqlDumpTables are the tables of merged.db a dump recreates, in an order
+// that satisfies their references
+var sqlDumpTables = append([]struct {
+	name    string
+	orderBy string
+}{{"meta", "key"}}, plistTables...)
+
+// sqlColumn is a column of a table being dumped
+type sqlColumn struct {
+	name, sqlType string
+	notNull       bool
+	pk            int
+}
+
+// SQL writes the contents of a merged database to w as prayers.sql, the
+// statements that recreate its tables in dialect. The rows are inserted in
+// transactions of opts.BatchSize rows, and the dump names opts.ScraperVersion
+// as what wrote it.
+func SQL(db *sql.DB, out io.Writer, dialect string, opts prayerdb.Options) error {
+	if dialect != DialectSQLite && dialect != DialectPostgres && dialect != DialectMySQL {
+		return fmt.Errorf("unknown SQL dialect '%s'", dialect)
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = prayerdb.DefaultBatchSize
+	}
+	d := sqlDumper{dialect: dialect, batchSize: opts.BatchSize}
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "-- merged.db, dumped by bpnet-scraper %s for %s\n", opts.ScraperVersion, dialect)
+	if dialect == DialectMySQL {
+		w.WriteString("SET NAMES utf8mb4;\n")
+	}
+	for _, table := range sqlDumpTables {
+		if err := d.dumpTable(db, w, table.name, table.orderBy); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// sqlDumper writes the statements of a dump
+type sqlDumper struct {
+	dialect   string
+	batchSize int
+}
+
+// dumpTable writes the CREATE TABLE and INSERT statements of a table
+func (d sqlDumper) dumpTable(db *sql.DB, w *bufio.Writer, table, orderBy string) error {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+
+	var defs, names, pk []string
+	for _, c := range columns {
+		names = append(names, d.quoteIdentifier(c.name))
+		def := d.quoteIdentifier(c.name) + " " + d.dialectType(c)
+		if c.notNull {
+			def += " NOT NULL"
+		}
+		defs = append(defs, def)
+	}
+	for i := 1; ; i++ {
+		found := false
+		for _, c := range columns {
+			if c.pk == i {
+				pk = append(pk, d.quoteIdentifier(c.name))
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	if len(pk) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pk, ", ")))
+	}
+	fmt.Fprintf(w, "\nDROP TABLE IF EXISTS %s;\n", d.quoteIdentifier(table))
+	fmt.Fprintf(w, "CREATE TABLE %s (\n\t%s\n);\n", d.quoteIdentifier(table), strings.Join(defs, ",\n\t"))
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT * FROM %s ORDER BY %s`, table, orderBy))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (", d.quoteIdentifier(table), strings.Join(names, ", "))
+	pending := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if pending == 0 {
+			w.WriteString("BEGIN;\n")
+		}
+		literals := make([]string, len(values))
+		for i, v := range values {
+			literals[i] = d.sqlLiteral(v)
+		}
+		w.WriteString(insert + strings.Join(literals, ", ") + ");\n")
+		if pending++; pending >= d.batchSize {
+			w.WriteString("COMMIT;\n")
+			pending = 0
+		}
+	}
+	if pending > 0 {
+		w.WriteString("COMMIT;\n")
+	}
+	return rows.Err()
+}
+
+// tableColumns reads the columns of a table of the database
+func tableColumns(db *sql.DB, table string) ([]sqlColumn, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []sqlColumn
+	for rows.Next() {
+		var c sqlColumn
+		var cid int
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &c.name, &c.sqlType, &c.notNull, &dflt, &c.pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("merged.db has no %s table", table)
+	}
+	return columns, rows.Err()
+}
+
+func (d sqlDumper) quoteIdentifier(name string) string {
+	if d.dialect == DialectMySQL {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+// dialectType translates the SQLite type of a column. IDs don't fit 32 bits,
+// so integers are 64 bit, and MySQL can only key text of a bounded length.
+func (d sqlDumper) dialectType(c sqlColumn) string {
+	switch d.dialect {
+	case DialectPostgres:
+		switch c.sqlType {
+		case "INTEGER":
+			return "BIGINT"
+		case "BLOB":
+			return "BYTEA"
+		case "REAL":
+			return "DOUBLE PRECISION"
+		}
+	case DialectMySQL:
+		switch c.sqlType {
+		case "INTEGER":
+			return "BIGINT"
+		case "BLOB":
+			return "LONGBLOB"
+		case "REAL":
+			return "DOUBLE"
+		case "TEXT":
+			if c.pk > 0 {
+				return "VARCHAR(191)"
+			}
+			return "LONGTEXT"
+		}
+	}
+	return c.sqlType
+}
+
+// sqlLiteral writes a value of a column as a literal of the dialect
+func (d sqlDumper) sqlLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		if d.dialect == DialectPostgres {
+			return `'\x` + hex.EncodeToString(v) + `'`
+		}
+		return "X'" + hex.EncodeToString(v) + "'"
+	default:
+		s := fmt.Sprint(v)
+		if d.dialect == DialectMySQL {
+			// MySQL treats backslashes in strings as escapes
+			s = strings.ReplaceAll(s, `\`, `\\`)
+		}
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+}
@@ -0,0 +1,202 @@
+package export
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"io"
+)
+
+// xmlNamespace identifies version 1 of the XML export's schema. A new
+// version of the schema gets a new namespace.
+const xmlNamespace = "http://arashpayan.com/bpnet-scraper/prayers/1"
+
+// XMLSchema is prayers.xsd, the schema prayers.xml is valid against. It's
+// written next to every export, and has to be kept in sync with the xml
+// types below.
+const XMLSchema = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:p="http://arashpayan.com/bpnet-scraper/prayers/1"
+           targetNamespace="http://arashpayan.com/bpnet-scraper/prayers/1"
+           elementFormDefault="qualified">
+
+  <xs:element name="prayers">
+    <xs:annotation>
+      <xs:documentation>Every prayer of a merged database, grouped by language and category.</xs:documentation>
+    </xs:annotation>
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="language" type="p:language" minOccurs="0" maxOccurs="unbounded"/>
+      </xs:sequence>
+      <xs:attribute name="scraperVersion" type="xs:string" use="required"/>
+      <xs:attribute name="mergedAt" type="xs:dateTime"/>
+    </xs:complexType>
+    <xs:unique name="prayerID">
+      <xs:selector xpath="p:language/p:category/p:prayer"/>
+      <xs:field xpath="@id"/>
+    </xs:unique>
+  </xs:element>
+
+  <xs:complexType name="language">
+    <xs:sequence>
+      <xs:element name="category" type="p:category" maxOccurs="unbounded"/>
+    </xs:sequence>
+    <xs:attribute name="code" type="xs:language" use="required"/>
+    <xs:attribute name="scrapedAt" type="xs:dateTime"/>
+  </xs:complexType>
+
+  <xs:complexType name="category">
+    <xs:sequence>
+      <xs:element name="prayer" type="p:prayer" maxOccurs="unbounded"/>
+    </xs:sequence>
+    <xs:attribute name="name" type="xs:string" use="required"/>
+  </xs:complexType>
+
+  <xs:complexType name="prayer">
+    <xs:sequence>
+      <xs:element name="openingWords" type="xs:string"/>
+      <xs:element name="prayerText" type="xs:string">
+        <xs:annotation>
+          <xs:documentation>The HTML of the prayer, as text.</xs:documentation>
+        </xs:annotation>
+      </xs:element>
+      <xs:element name="plainText" type="xs:string"/>
+      <xs:element name="citation" type="xs:string"/>
+      <xs:element name="author" type="xs:string"/>
+      <xs:element name="footnotes" minOccurs="0">
+        <xs:complexType>
+          <xs:sequence>
+            <xs:element name="footnote" maxOccurs="unbounded">
+              <xs:complexType>
+                <xs:simpleContent>
+                  <xs:extension base="xs:string">
+                    <xs:attribute name="number" type="xs:positiveInteger" use="required"/>
+                  </xs:extension>
+                </xs:simpleContent>
+              </xs:complexType>
+            </xs:element>
+          </xs:sequence>
+        </xs:complexType>
+      </xs:element>
+    </xs:sequence>
+    <xs:attribute name="id" type="xs:positiveInteger" use="required"/>
+    <xs:attribute name="authorId" type="xs:nonNegativeInteger" use="required"/>
+    <xs:attribute name="wordCount" type="xs:nonNegativeInteger" use="required"/>
+    <xs:attribute name="hasInstructions" type="xs:boolean" use="required"/>
+    <xs:attribute name="duplicateOf" type="xs:positiveInteger">
+      <xs:annotation>
+        <xs:documentation>The prayer this one is a copy of, if merged with -duplicates link.</xs:documentation>
+      </xs:annotation>
+    </xs:attribute>
+    <xs:attribute name="contentHash" type="xs:hexBinary" use="required"/>
+    <xs:attribute name="createdAt" type="xs:dateTime" use="required"/>
+    <xs:attribute name="updatedAt" type="xs:dateTime" use="required"/>
+  </xs:complexType>
+</xs:schema>
+`
+
+type xmlExport struct {
+	XMLName        xml.Name      `xml:"http://arashpayan.com/bpnet-scraper/prayers/1 prayers"`
+	XSI            string        `xml:"xmlns:xsi,attr"`
+	SchemaLocation string        `xml:"xsi:schemaLocation,attr"`
+	ScraperVersion string        `xml:"scraperVersion,attr"`
+	MergedAt       string        `xml:"mergedAt,attr,omitempty"`
+	Languages      []xmlLanguage `xml:"language"`
+}
+
+type xmlLanguage struct {
+	Code       string        `xml:"code,attr"`
+	ScrapedAt  string        `xml:"scrapedAt,attr,omitempty"`
+	Categories []xmlCategory `xml:"category"`
+}
+
+type xmlCategory struct {
+	Name    string      `xml:"name,attr"`
+	Prayers []xmlPrayer `xml:"prayer"`
+}
+
+type xmlPrayer struct {
+	ID              int           `xml:"id,attr"`
+	AuthorID        int           `xml:"authorId,attr"`
+	WordCount       int           `xml:"wordCount,attr"`
+	HasInstructions bool          `xml:"hasInstructions,attr"`
+	DuplicateOf     int           `xml:"duplicateOf,attr,omitempty"`
+	ContentHash     string        `xml:"contentHash,attr"`
+	CreatedAt       string        `xml:"createdAt,attr"`
+	UpdatedAt       string        `xml:"updatedAt,attr"`
+	OpeningWords    string        `xml:"openingWords"`
+	PrayerText      string        `xml:"prayerText"`
+	PlainText       string        `xml:"plainText"`
+	Citation        string        `xml:"citation"`
+	Author          string        `xml:"author"`
+	Footnotes       *xmlFootnotes `xml:"footnotes"`
+}
+
+// xmlFootnotes is left out of prayers without footnotes, since the schema
+// requires at least one
+type xmlFootnotes struct {
+	Footnotes []xmlFootnote `xml:"footnote"`
+}
+
+type xmlFootnote struct {
+	Number int    `xml:"number,attr"`
+	Text   string `xml:",chardata"`
+}
+
+// XML writes the prayers of a merged database to w as prayers.xml, which
+// refers to XMLSchema as prayers.xsd
+func XML(db *sql.DB, w io.Writer) error {
+	export, err := readJSONExport(db)
+	if err != nil {
+		return err
+	}
+	buf, err := xml.MarshalIndent(toXMLExport(export), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append([]byte(xml.Header), append(buf, '\n')...))
+	return err
+}
+
+// toXMLExport lays out the prayers read for the JSON export the way the
+// schema of the XML export describes
+func toXMLExport(export *jsonExport) xmlExport {
+	x := xmlExport{
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: xmlNamespace + " prayers.xsd",
+		ScraperVersion: export.ScraperVersion,
+		MergedAt:       export.MergedAt,
+	}
+	for _, l := range export.Languages {
+		xl := xmlLanguage{Code: l.Language, ScrapedAt: l.ScrapedAt}
+		for _, c := range l.Categories {
+			xc := xmlCategory{Name: c.Name}
+			for _, p := range c.Prayers {
+				xp := xmlPrayer{
+					ID:              p.ID,
+					AuthorID:        p.AuthorID,
+					WordCount:       p.WordCount,
+					HasInstructions: p.HasInstructions,
+					DuplicateOf:     p.DuplicateOf,
+					ContentHash:     p.ContentHash,
+					CreatedAt:       p.CreatedAt,
+					UpdatedAt:       p.UpdatedAt,
+					OpeningWords:    p.OpeningWords,
+					PrayerText:      p.PrayerText,
+					PlainText:       p.PlainText,
+					Citation:        p.Citation,
+					Author:          p.Author,
+				}
+				if len(p.Footnotes) > 0 {
+					xp.Footnotes = &xmlFootnotes{}
+				}
+				for _, fn := range p.Footnotes {
+					xp.Footnotes.Footnotes = append(xp.Footnotes.Footnotes, xmlFootnote{Number: fn.Number, Text: fn.Text})
+				}
+				xc.Prayers = append(xc.Prayers, xp)
+			}
+			xl.Categories = append(xl.Categories, xc)
+		}
+		x.Languages = append(x.Languages, xl)
+	}
+	return x
+}
@@ -1,8 +1,11 @@
 module arashpayan.com/bpnet-scraper
 
-go 1.14
+go 1.21
 
 require (
-	github.com/jmoiron/sqlx v1.2.0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.2
+	github.com/mutecomm/go-sqlcipher/v4 v4.4.2
+	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
+	golang.org/x/text v0.3.3
 )
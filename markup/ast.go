@@ -0,0 +1,243 @@
+package markup
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Block is a node in the parsed form of a prayer. The source text is parsed
+// into a list of blocks once, and the HTML, Markdown and plain text versions
+// of the prayer (as well as its opening words and citations) are all derived
+// from that list, so they can't disagree with each other.
+//
+// The text of a block still carries the inline tags (<em>, <i>, <sup>, <br/>)
+// that the source or the parser put there. Renderers deal with those.
+type Block interface {
+	block()
+}
+
+// Title is a paragraph marked with '#'. It isn't rendered, but it can stand in
+// for the opening words of the prayer.
+type Title struct {
+	Text string
+}
+
+// OpeningParagraph is the first paragraph of the prayer proper
+type OpeningParagraph struct {
+	Text string
+}
+
+// BodyParagraph is any paragraph of the prayer after the opening one
+type BodyParagraph struct {
+	Text string
+	// Refrain is set when the paragraph is repeated within the prayer
+	Refrain bool
+}
+
+// Comment is a paragraph marked with '*' (or '##' for Caps) that isn't part
+// of the prayer itself, like an instruction or a heading
+type Comment struct {
+	Text string
+	Caps bool
+}
+
+// Instruction is a comment in an obligatory prayer, telling the reader what
+// to do (e.g. when to kneel) rather than what to say
+type Instruction struct {
+	Text string
+}
+
+// Blockquote is a run of consecutive paragraphs marked with '>'
+type Blockquote struct {
+	Paragraphs []string
+}
+
+// CenteredLine is a paragraph marked with '='
+type CenteredLine struct {
+	Text string
+}
+
+// Citation is one of the '*' paragraphs ending the prayer, naming its source
+type Citation struct {
+	Text string
+}
+
+// Footnotes holds the notes referenced from the rest of the prayer
+type Footnotes struct {
+	Notes []Footnote
+}
+
+func (Title) block()            {}
+func (OpeningParagraph) block() {}
+func (BodyParagraph) block()    {}
+func (Comment) block()          {}
+func (Instruction) block()      {}
+func (Blockquote) block()       {}
+func (CenteredLine) block()     {}
+func (Citation) block()         {}
+func (Footnotes) block()        {}
+
+// Document is a parsed prayer
+type Document struct {
+	Blocks []Block
+}
+
+// Parse parses the marked up text of a prayer from the API. Paragraphs
+// are separated by newlines, and can start with a marker:
+//
+//	##  a comment that's displayed in caps
+//	#   a title for the prayer
+//	*   a comment, or a citation when it's at the end of the prayer
+//	>   a blockquoted passage
+//	=   a centered line
+//
+// Unmarked paragraphs are the prayer itself. Footnote definitions and inline
+// emphasis are handled here too.
+func Parse(text string) Document {
+	var parts []string
+	for _, p := range strings.Split(text, "\n") {
+		if trimmed := strings.TrimSpace(p); trimmed != "" {
+			parts = append(parts, trimmed)
+		}
+	}
+
+	notes, parts := extractFootnotes(parts)
+
+	// the asterisk'ed paragraphs at the end are citations (often a
+	// translator's note followed by the source)
+	citationStart := len(parts)
+	for citationStart > 0 && isComment(parts[citationStart-1]) {
+		citationStart--
+	}
+
+	refrains := findRefrains(parts)
+
+	doc := Document{}
+	sawOpening := false
+	for i, p := range parts {
+		switch {
+		case strings.HasPrefix(p, "##"):
+			doc.Blocks = append(doc.Blocks, Comment{Text: emphasize(p[2:]), Caps: true})
+		case strings.HasPrefix(p, "#"):
+			doc.Blocks = append(doc.Blocks, Title{Text: p[1:]})
+		case strings.HasPrefix(p, ">"):
+			text := emphasize(strings.TrimSpace(p[1:]))
+			if last := len(doc.Blocks) - 1; last >= 0 {
+				if bq, ok := doc.Blocks[last].(Blockquote); ok {
+					bq.Paragraphs = append(bq.Paragraphs, text)
+					doc.Blocks[last] = bq
+					continue
+				}
+			}
+			doc.Blocks = append(doc.Blocks, Blockquote{Paragraphs: []string{text}})
+		case strings.HasPrefix(p, "="):
+			doc.Blocks = append(doc.Blocks, CenteredLine{Text: emphasize(strings.TrimSpace(p[1:]))})
+		case isComment(p) && i >= citationStart:
+			doc.Blocks = append(doc.Blocks, Citation{Text: p[1:]})
+		case isComment(p):
+			doc.Blocks = append(doc.Blocks, Comment{Text: emphasize(p[1:])})
+		case !sawOpening:
+			doc.Blocks = append(doc.Blocks, OpeningParagraph{Text: emphasize(p)})
+			sawOpening = true
+		default:
+			doc.Blocks = append(doc.Blocks, BodyParagraph{Text: emphasize(p), Refrain: refrains[refrainKey(p)]})
+		}
+	}
+	if len(notes) > 0 {
+		doc.Blocks = append(doc.Blocks, Footnotes{Notes: notes})
+	}
+
+	return doc
+}
+
+// MarkInstructions turns the comments of the document into instructions.
+// It's used for obligatory prayers, where that's what comments are.
+func (doc Document) MarkInstructions() {
+	for i, b := range doc.Blocks {
+		if c, ok := b.(Comment); ok && !c.Caps {
+			doc.Blocks[i] = Instruction{Text: c.Text}
+		}
+	}
+}
+
+// HasInstructions reports whether the document has any instructions
+func (doc Document) HasInstructions() bool {
+	for _, b := range doc.Blocks {
+		if _, ok := b.(Instruction); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMarker reports whether paragraph p starts with one of the markers, as
+// opposed to being part of the prayer's text
+func hasMarker(p string) bool {
+	return strings.HasPrefix(p, "#") || strings.HasPrefix(p, ">") || strings.HasPrefix(p, "=") || isComment(p)
+}
+
+// OpeningWords returns the words the prayer is listed by: the title or the
+// start of the opening paragraph, whichever comes last. The opening paragraph
+// is cut to at most length runes, with ellipsis appended when it's cut, and
+// its footnote references are left out.
+func (doc Document) OpeningWords(length int, ellipsis string) string {
+	words := ""
+	for _, b := range doc.Blocks {
+		switch b := b.(type) {
+		case Title:
+			words = b.Text
+		case OpeningParagraph:
+			words = truncateWords(PlainInline(footnoteSupRegexp.ReplaceAllString(b.Text, "")), length, ellipsis)
+		}
+	}
+	return words
+}
+
+// Citations returns the text of the citations ending the prayer
+func (doc Document) Citations() []string {
+	var citations []string
+	for _, b := range doc.Blocks {
+		if c, ok := b.(Citation); ok {
+			citations = append(citations, c.Text)
+		}
+	}
+	return citations
+}
+
+// Footnotes returns the notes of the prayer, if it has any
+func (doc Document) Footnotes() []Footnote {
+	for _, b := range doc.Blocks {
+		if fn, ok := b.(Footnotes); ok {
+			return fn.Notes
+		}
+	}
+	return nil
+}
+
+// isComment reports whether paragraph p is marked as a comment (or, at the end
+// of a prayer, a citation)
+func isComment(p string) bool {
+	return strings.HasPrefix(p, "*") && !startsWithEmphasis(p)
+}
+
+// truncateWords shortens s to at most length runes, cutting it at the last
+// word boundary that fits and appending ellipsis. Text without spaces (e.g.
+// Chinese) is cut at exactly length runes.
+func truncateWords(s string, length int, ellipsis string) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+
+	cut := length
+	for cut > 0 && !unicode.IsSpace(runes[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		cut = length
+	}
+	truncated := strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return truncated + ellipsis
+}
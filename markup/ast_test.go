@@ -0,0 +1,22 @@
+package markup
+
+import "testing"
+
+func TestOpeningWords(t *testing.T) {
+	cases := []struct {
+		name, text, want string
+	}{
+		{"short", "O God, my God!", "O God, my God!"},
+		{"cut at a word", "O God, my God! Thou art my hope", "O God, my God…"},
+		{"title", "O God, my God!\n#The Healing Prayer", "The Healing Prayer"},
+		{"footnote reference", "O God[1], my God!\n[1] The Beloved", "O God, my God!"},
+		{"footnote reference at the cut", "O my God[1] and my hope\n[1] The Beloved", "O my God and my…"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Parse(c.text).OpeningWords(15, "…"); got != c.want {
+				t.Errorf("the opening words of %q are %q, want %q", c.text, got, c.want)
+			}
+		})
+	}
+}
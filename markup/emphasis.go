@@ -0,0 +1,90 @@
+package markup
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// emphasisMarkers are the characters that can wrap an emphasized phrase
+// inside a paragraph
+var emphasisMarkers = []byte{'*', '_'}
+
+// emphasize converts the inline emphasis markers in s (*like this* or
+// _like this_) to <em> tags. A marker only opens or closes emphasis at the
+// edge of a word, so things like snake_case or 2*3 are left alone.
+func emphasize(s string) string {
+	for _, marker := range emphasisMarkers {
+		s = emphasizeMarker(s, marker)
+	}
+	return s
+}
+
+func emphasizeMarker(s string, marker byte) string {
+	if strings.IndexByte(s, marker) < 0 {
+		return s
+	}
+	emphasized := strings.Builder{}
+	emphasized.Grow(len(s) + len("<em></em>"))
+	last := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != marker || !opensEmphasis(s, i) {
+			continue
+		}
+		end := closingEmphasis(s, i, marker)
+		if end < 0 {
+			break
+		}
+		emphasized.WriteString(s[last:i])
+		emphasized.WriteString("<em>")
+		emphasized.WriteString(s[i+1 : end])
+		emphasized.WriteString("</em>")
+		last = end + 1
+		i = end
+	}
+	emphasized.WriteString(s[last:])
+	return emphasized.String()
+}
+
+// startsWithEmphasis reports whether a paragraph starting with '*' is an
+// emphasized phrase followed by more text, rather than a comment
+func startsWithEmphasis(p string) bool {
+	if !opensEmphasis(p, 0) {
+		return false
+	}
+	end := closingEmphasis(p, 0, p[0])
+	return end > 0 && strings.TrimSpace(p[end+1:]) != ""
+}
+
+// opensEmphasis reports whether the marker at s[i] can open emphasis: it
+// must not follow a letter or digit, and must be followed by a non-space
+func opensEmphasis(s string, i int) bool {
+	if i > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(s[:i])
+		if unicode.IsLetter(prev) || unicode.IsDigit(prev) {
+			return false
+		}
+	}
+	next, size := utf8.DecodeRuneInString(s[i+1:])
+	return size > 0 && !unicode.IsSpace(next) && next != rune(s[i])
+}
+
+// closingEmphasis returns the index of the marker closing the emphasis
+// opened at s[open], or -1 if there's none
+func closingEmphasis(s string, open int, marker byte) int {
+	for j := open + 2; j < len(s); j++ {
+		if s[j] != marker {
+			continue
+		}
+		prev, _ := utf8.DecodeLastRuneInString(s[:j])
+		if unicode.IsSpace(prev) {
+			continue
+		}
+		next, size := utf8.DecodeRuneInString(s[j+1:])
+		if size > 0 && (unicode.IsLetter(next) || unicode.IsDigit(next)) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
@@ -0,0 +1,79 @@
+package markup
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Footnote is a note referenced from the text of a prayer with a marker like
+// [1], and defined in a paragraph of its own starting with the same marker
+type Footnote struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+var footnoteDefRegexp = regexp.MustCompile(`^\[(\d+)\]\s*(.+)$`)
+var footnoteRefRegexp = regexp.MustCompile(`\[(\d+)\]`)
+
+// footnoteSupRegexp matches the footnote references extractFootnotes makes
+var footnoteSupRegexp = regexp.MustCompile(`<sup>\d+</sup>`)
+
+// extractFootnotes pulls the footnote definitions out of a prayer's
+// paragraphs, and replaces the markers referencing them with <sup> tags. A
+// paragraph only counts as a definition when its marker is referenced from
+// another paragraph, so text that merely starts with a bracketed number stays
+// where it is.
+func extractFootnotes(parts []string) ([]Footnote, []string) {
+	defs := make(map[int]int) // footnote number -> index of its paragraph
+	for i, p := range parts {
+		m := footnoteDefRegexp.FindStringSubmatch(p)
+		if m == nil {
+			continue
+		}
+		num, _ := strconv.Atoi(m[1])
+		for j, other := range parts {
+			if j != i && strings.Contains(other, "["+m[1]+"]") {
+				defs[num] = i
+				break
+			}
+		}
+	}
+	if len(defs) == 0 {
+		return nil, parts
+	}
+
+	var footnotes []Footnote
+	var remaining []string
+	for i, p := range parts {
+		m := footnoteDefRegexp.FindStringSubmatch(p)
+		if m != nil {
+			num, _ := strconv.Atoi(m[1])
+			if defs[num] == i {
+				footnotes = append(footnotes, Footnote{Number: num, Text: m[2]})
+				continue
+			}
+		}
+		remaining = append(remaining, footnoteRefRegexp.ReplaceAllStringFunc(p, func(ref string) string {
+			num, _ := strconv.Atoi(ref[1 : len(ref)-1])
+			if _, ok := defs[num]; !ok {
+				return ref
+			}
+			return "<sup>" + strconv.Itoa(num) + "</sup>"
+		}))
+	}
+	return footnotes, remaining
+}
+
+// plainFootnotes writes footnotes to the plain text version of a prayer
+func plainFootnotes(plain *strings.Builder, footnotes []Footnote) {
+	for i, fn := range footnotes {
+		if i > 0 {
+			plain.WriteString("\n")
+		}
+		plain.WriteString("[")
+		plain.WriteString(strconv.Itoa(fn.Number))
+		plain.WriteString("] ")
+		plainInline(plain, fn.Text)
+	}
+}
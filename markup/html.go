@@ -0,0 +1,197 @@
+package markup
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// templateNames are the templates a Renderer renders prayers with. Each of
+// them can be replaced by putting a file called <name>.tmpl in the directory
+// passed to LoadTemplates. Text from the API should go through the source
+// function, which escapes it while keeping the inline tags prayers may use.
+var templateNames = []string{"opening", "versal", "paragraph", "refrain", "comment", "commentcaps", "instruction", "blockquote", "centered", "citation", "footnotes"}
+
+const defaultTemplates = `
+{{define "opening"}}{{if .Versal}}<p class="{{class "opening"}}">{{source .Lead}}{{template "versal" .Versal}}{{source .Text}}</p>{{else}}<p>{{source .Text}}</p>{{end}}{{end}}
+{{define "versal"}}<span class="{{class "versal"}}">{{source .}}</span>{{end}}
+{{define "paragraph"}}<p>{{source .}}</p>{{end}}
+{{define "refrain"}}<p class="{{class "refrain"}}">{{source .}}</p>{{end}}
+{{define "comment"}}<p class="{{class "comment"}}">{{source .}}</p>{{end}}
+{{define "commentcaps"}}<p class="{{class "commentcaps"}}">{{source .}}</p>{{end}}
+{{define "instruction"}}<p class="{{class "instruction"}}">{{source .}}</p>{{end}}
+{{define "blockquote"}}<blockquote class="{{class "blockquote"}}">{{range $i, $p := .}}{{if $i}}
+{{end}}<p>{{source $p}}</p>{{end}}</blockquote>{{end}}
+{{define "centered"}}<p class="{{class "centered"}}">{{source .}}</p>{{end}}
+{{define "citation"}}{{.}}{{end}}
+{{define "footnotes"}}<ol class="{{class "footnotes"}}">{{range .}}<li value="{{.Number}}">{{source .Text}}</li>{{end}}</ol>{{end}}
+`
+
+// Renderer renders parsed prayers as HTML
+type Renderer struct {
+	templates *template.Template
+	classes   map[string]string
+}
+
+// NewRenderer returns a Renderer using the default templates. classes
+// renames the CSS classes of the generated HTML, keyed by their default
+// names: opening, versal, refrain, comment, commentcaps, instruction,
+// blockquote, centered, noindent and footnotes.
+func NewRenderer(classes map[string]string) *Renderer {
+	r := &Renderer{classes: classes}
+	r.templates = template.Must(template.New("markup").Funcs(template.FuncMap{
+		"source": escapeSource,
+		"class":  r.className,
+	}).Parse(defaultTemplates))
+	return r
+}
+
+// className returns the CSS class to use in place of the default one
+func (r *Renderer) className(name string) string {
+	if c, ok := r.classes[name]; ok {
+		return c
+	}
+	return name
+}
+
+// sourceEscaper escapes the characters that are significant in HTML text.
+// Quotes are left alone since we never put source text in attributes.
+var sourceEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeSource escapes text from the API for use in HTML, keeping the inline
+// tags (<i>, <em>, <sup>, <br/>) that prayers may contain
+func escapeSource(s string) template.HTML {
+	escaped := strings.Builder{}
+	escaped.Grow(len(s))
+	last := 0
+	for _, loc := range InlineTags.FindAllStringIndex(s, -1) {
+		sourceEscaper.WriteString(&escaped, s[last:loc[0]])
+		escaped.WriteString(strings.ToLower(s[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	sourceEscaper.WriteString(&escaped, s[last:])
+	return template.HTML(escaped.String())
+}
+
+// openingData is passed to the "opening" template. Versal is empty when the
+// language doesn't use one, in which case Text holds the whole paragraph.
+// Lead is what comes before the versal, like an opening quote or <em>.
+type openingData struct {
+	Lead   string
+	Versal string
+	Text   string
+}
+
+// splitVersal splits the opening paragraph around the letter that can be
+// set as a versal: the first one, after any inline tags and punctuation, so
+// the versal never cuts into a tag. It returns false when there's no such
+// letter, or versal turns it down.
+func splitVersal(text string, versal func(first rune) bool) (openingData, bool) {
+	lead := 0
+	for lead < len(text) {
+		if loc := InlineTags.FindStringIndex(text[lead:]); loc != nil && loc[0] == 0 {
+			lead += loc[1]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[lead:])
+		if !unicode.IsPunct(r) && !unicode.IsSpace(r) {
+			break
+		}
+		lead += size
+	}
+	first, size := utf8.DecodeRuneInString(text[lead:])
+	if !unicode.IsLetter(first) || !versal(first) {
+		return openingData{}, false
+	}
+	return openingData{Lead: text[:lead], Versal: text[lead : lead+size], Text: text[lead+size:]}, true
+}
+
+// LoadTemplates replaces the default templates with the ones found in dir.
+// Templates that don't have a file in dir keep their default.
+func (r *Renderer) LoadTemplates(dir string) error {
+	for _, name := range templateNames {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, name+".tmpl"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := r.templates.New(name).Parse(string(buf)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HTML renders a parsed prayer as HTML. versal reports whether the first
+// letter of the opening paragraph, after any opening punctuation, should be
+// set as a versal. The blocks are
+// rendered straight into a single buffer, separated by blank lines.
+func (r *Renderer) HTML(doc Document, versal func(first rune) bool) (string, error) {
+	out := strings.Builder{}
+	rendered := false
+	for _, b := range doc.Blocks {
+		var name string
+		var data interface{}
+		switch b := b.(type) {
+		case OpeningParagraph:
+			opening, ok := splitVersal(b.Text, versal)
+			if !ok {
+				opening = openingData{Text: b.Text}
+			}
+			name, data = "opening", opening
+		case BodyParagraph:
+			name, data = "paragraph", b.Text
+			if b.Refrain {
+				name = "refrain"
+			}
+		case Comment:
+			name, data = "comment", b.Text
+			if b.Caps {
+				name = "commentcaps"
+			}
+		case Instruction:
+			name, data = "instruction", b.Text
+		case Blockquote:
+			name, data = "blockquote", b.Paragraphs
+		case CenteredLine:
+			name, data = "centered", b.Text
+		case Footnotes:
+			name, data = "footnotes", b.Notes
+		default:
+			continue
+		}
+		if rendered {
+			out.WriteString("\n\n")
+		}
+		if err := r.render(&out, name, data); err != nil {
+			return "", err
+		}
+		rendered = true
+	}
+	return out.String(), nil
+}
+
+// Citation renders a citation for the citation column. The column holds
+// plain text, so the citation isn't escaped.
+func (r *Renderer) Citation(c string) (string, error) {
+	out := strings.Builder{}
+	if err := r.render(&out, "citation", template.HTML(c)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (r *Renderer) render(w io.Writer, name string, data interface{}) error {
+	if err := r.templates.ExecuteTemplate(w, name, data); err != nil {
+		return fmt.Errorf("unable to render the '%s' template: %v", name, err)
+	}
+	return nil
+}
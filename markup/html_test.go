@@ -0,0 +1,101 @@
+package markup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func always(rune) bool { return true }
+func never(rune) bool  { return false }
+
+func TestRendererHTML(t *testing.T) {
+	cases := []struct {
+		name, text string
+		versal     func(first rune) bool
+		want       string
+	}{
+		{"versal", "O God!", always, `<p class="opening"><span class="versal">O</span> God!</p>`},
+		{"no versal", "O God!", never, `<p>O God!</p>`},
+		{"versal after punctuation and emphasis", "“*O* God!”", always, `<p class="opening">“<em><span class="versal">O</span></em> God!”</p>`},
+		{"versal turned down for a digit", "1. O God!", always, `<p>1. O God!</p>`},
+		{"escaping", "O God & <Lord>", never, `<p>O God &amp; &lt;Lord&gt;</p>`},
+		{"inline tags kept", "O God,<BR/>my <I>Lord</I>", never, `<p>O God,<br/>my <i>Lord</i></p>`},
+		{"blocks", "#Title\nO God!\n*Kneel\n>Quoted\n>Again\n=Centered\nPraise be\nPraise be\n##Heading\nO Lord[1]\n[1] The Lord\n*Citation", never,
+			"<p>O God!</p>\n\n" +
+				"<p class=\"comment\">Kneel</p>\n\n" +
+				"<blockquote class=\"blockquote\"><p>Quoted</p>\n<p>Again</p></blockquote>\n\n" +
+				"<p class=\"centered\">Centered</p>\n\n" +
+				"<p class=\"refrain\">Praise be</p>\n\n" +
+				"<p class=\"refrain\">Praise be</p>\n\n" +
+				"<p class=\"commentcaps\">Heading</p>\n\n" +
+				"<p>O Lord<sup>1</sup></p>\n\n" +
+				"<ol class=\"footnotes\"><li value=\"1\">The Lord</li></ol>"},
+	}
+	r := NewRenderer(nil)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := r.HTML(Parse(c.text), c.versal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("the HTML of %q is\n%s\nwant\n%s", c.text, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRendererInstructions(t *testing.T) {
+	doc := Parse("O God!\n*Kneel\nPraise be")
+	doc.MarkInstructions()
+	got, err := NewRenderer(nil).HTML(doc, never)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<p>O God!</p>\n\n<p class=\"instruction\">Kneel</p>\n\n<p>Praise be</p>"
+	if got != want {
+		t.Errorf("the HTML is\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRendererClasses(t *testing.T) {
+	r := NewRenderer(map[string]string{"versal": "dropcap", "refrain": "chorus"})
+	got, err := r.HTML(Parse("O God!\nPraise be\nPraise be"), always)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<p class=\"opening\"><span class=\"dropcap\">O</span> God!</p>\n\n<p class=\"chorus\">Praise be</p>\n\n<p class=\"chorus\">Praise be</p>"
+	if got != want {
+		t.Errorf("the HTML is\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRendererLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "paragraph.tmpl"), []byte(`<div>{{source .}}</div>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRenderer(nil)
+	if err := r.LoadTemplates(dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.HTML(Parse("O God!\nPraise & glory"), never)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<p>O God!</p>\n\n<div>Praise &amp; glory</div>"
+	if got != want {
+		t.Errorf("the HTML is\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRendererCitation(t *testing.T) {
+	got, err := NewRenderer(nil).Citation("Bahá’u’lláh & ‘Abdu’l-Bahá")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Bahá’u’lláh & ‘Abdu’l-Bahá"; got != want {
+		t.Errorf("the citation is %q, want %q", got, want)
+	}
+}
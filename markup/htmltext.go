@@ -0,0 +1,65 @@
+package markup
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// inlineTags are the tags that can sit in the middle of a word, like the
+// versal span or a footnote reference, so stripping them mustn't separate
+// the text around them
+var inlineTags = map[string]bool{
+	"span":   true,
+	"i":      true,
+	"em":     true,
+	"b":      true,
+	"strong": true,
+	"sup":    true,
+	"sub":    true,
+	"small":  true,
+	"a":      true,
+}
+
+// HTMLText converts a fragment of the generated HTML to plain text. All the
+// tags are dropped, and entities are unescaped. Any tag that isn't inline
+// separates the words on either side of it, so paragraphs, line breaks and
+// list items never run together. Footnote references are dropped along with
+// their numbers, which aren't words of the prayer.
+func HTMLText(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	z := html.NewTokenizer(strings.NewReader(s))
+	// sup counts the <sup> tags the text is in
+	sup := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return strings.TrimSpace(b.String())
+		case html.TextToken:
+			if sup == 0 {
+				b.Write(z.Text())
+			}
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			if string(name) == "sup" {
+				switch {
+				case tt == html.StartTagToken:
+					sup++
+				case tt == html.EndTagToken && sup > 0:
+					sup--
+				}
+			}
+			if inlineTags[string(name)] {
+				continue
+			}
+			last, _ := utf8.DecodeLastRuneInString(b.String())
+			if b.Len() > 0 && !unicode.IsSpace(last) {
+				b.WriteByte(' ')
+			}
+		}
+	}
+}
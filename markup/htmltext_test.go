@@ -0,0 +1,22 @@
+package markup
+
+import "testing"
+
+func TestHTMLText(t *testing.T) {
+	cases := []struct {
+		name, html, want string
+	}{
+		{"paragraphs", `<p>O God!</p><p>Praise be</p>`, "O God! Praise be"},
+		{"inline tags", `<p><span class="versal">O</span> <em>God</em>!</p>`, "O God!"},
+		{"line breaks", `<p>O God,<br/>my God</p>`, "O God, my God"},
+		{"entities", `<p>&lt;Thou&gt; &amp; I</p>`, "<Thou> & I"},
+		{"footnote references", `<p>O God<sup>1</sup>, my God<sup>12</sup>!</p>`, "O God, my God!"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := HTMLText(c.html); got != c.want {
+				t.Errorf("HTMLText(%q) = %q, want %q", c.html, got, c.want)
+			}
+		})
+	}
+}
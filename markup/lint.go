@@ -0,0 +1,93 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MarkerIssue is a problem with the markers of one paragraph of a prayer
+type MarkerIssue struct {
+	Paragraph int
+	Problem   string
+	Repaired  bool
+}
+
+func (mi MarkerIssue) String() string {
+	status := "not repaired"
+	if mi.Repaired {
+		status = "repaired"
+	}
+	return fmt.Sprintf("paragraph %d: %s (%s)", mi.Paragraph+1, mi.Problem, status)
+}
+
+var (
+	extraHashesRegexp   = regexp.MustCompile(`^#{3,}`)
+	inlineHashRegexp    = regexp.MustCompile(`\s(#{1,2}\pL)`)
+	spaceAfterRegexp    = regexp.MustCompile(`^(##|#|\*)\s+`)
+	citationShapeRegexp = regexp.MustCompile(`^\*\s*(?:[—–-]|\(.*\)$)`)
+)
+
+// Lint checks the markers in the text of a prayer, returning the
+// issues it found along with the text as repaired under these rules:
+//
+//   - '###' (or more) becomes '##'
+//   - '**' at the start of a paragraph becomes '*'
+//   - whitespace between a '#', '##' or '*' marker and the text is dropped;
+//     '>' and '=' can be followed by a space
+//   - paragraphs holding nothing but a marker are dropped
+//   - a '#' or '##' marker in the middle of a paragraph starts a new paragraph
+//
+// A '*' paragraph shaped like a citation (starting with a dash or wrapped in
+// parentheses) in the middle of the prayer is reported, but left where it
+// is, since it's as likely to be an instruction.
+func Lint(text string) (string, []MarkerIssue) {
+	var parts []string
+	for _, p := range strings.Split(text, "\n") {
+		if trimmed := strings.TrimSpace(p); trimmed != "" {
+			parts = append(parts, trimmed)
+		}
+	}
+
+	var issues []MarkerIssue
+	var repaired []string
+	for i, p := range parts {
+		if extraHashesRegexp.MatchString(p) {
+			issues = append(issues, MarkerIssue{i, "more than two '#' markers", true})
+			p = extraHashesRegexp.ReplaceAllString(p, "##")
+		}
+		if strings.HasPrefix(p, "**") && !startsWithEmphasis(p[1:]) {
+			issues = append(issues, MarkerIssue{i, "stray '**' marker", true})
+			p = p[1:]
+		}
+		if spaceAfterRegexp.MatchString(p) && !startsWithEmphasis(p) {
+			issues = append(issues, MarkerIssue{i, "whitespace after marker", true})
+			p = spaceAfterRegexp.ReplaceAllString(p, "$1")
+		}
+		if p == "#" || p == "##" || p == "*" || p == ">" || p == "=" {
+			issues = append(issues, MarkerIssue{i, "marker without text", true})
+			continue
+		}
+		if loc := inlineHashRegexp.FindStringSubmatchIndex(p); loc != nil {
+			issues = append(issues, MarkerIssue{i, "'#' marker not at the start of a paragraph", true})
+			repaired = append(repaired, strings.TrimSpace(p[:loc[2]]))
+			p = p[loc[2]:]
+		}
+		if citationShapeRegexp.MatchString(p) && followedByText(parts[i+1:]) {
+			issues = append(issues, MarkerIssue{i, "citation in the middle of the prayer", false})
+		}
+		repaired = append(repaired, p)
+	}
+
+	return strings.Join(repaired, "\n"), issues
+}
+
+// followedByText reports whether any of parts is an unmarked paragraph
+func followedByText(parts []string) bool {
+	for _, p := range parts {
+		if !hasMarker(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package markup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		name, text, want string
+		issues           []string
+	}{
+		{"clean", "#Title\nO God!\n*Citation", "#Title\nO God!\n*Citation", nil},
+		{"blank lines", "O God!\n\n  \nPraise be", "O God!\nPraise be", nil},
+		{"extra hashes", "###Heading\nO God!", "##Heading\nO God!", []string{"paragraph 1: more than two '#' markers (repaired)"}},
+		{"stray double asterisk", "O God!\n**Citation", "O God!\n*Citation", []string{"paragraph 2: stray '**' marker (repaired)"}},
+		{"emphasis after an asterisk", "O God!\n**Say* this", "O God!\n**Say* this", nil},
+		{"whitespace after markers", "# Title\n##  Heading\n* Kneel\nO God!\n> Quoted\n= Centered", "#Title\n##Heading\n*Kneel\nO God!\n> Quoted\n= Centered", []string{
+			"paragraph 1: whitespace after marker (repaired)",
+			"paragraph 2: whitespace after marker (repaired)",
+			"paragraph 3: whitespace after marker (repaired)",
+		}},
+		{"markers without text", "O God!\n*\n= \n#\nPraise be", "O God!\nPraise be", []string{
+			"paragraph 2: marker without text (repaired)",
+			"paragraph 3: marker without text (repaired)",
+			"paragraph 4: marker without text (repaired)",
+		}},
+		{"hash in a paragraph", "O God! ##Heading\nPraise be", "O God!\n##Heading\nPraise be", []string{"paragraph 1: '#' marker not at the start of a paragraph (repaired)"}},
+		{"citation in the middle", "O God!\n*— Bahá’u’lláh\nPraise be", "O God!\n*— Bahá’u’lláh\nPraise be", []string{"paragraph 2: citation in the middle of the prayer (not repaired)"}},
+		{"citation at the end", "O God!\n*(Prayers and Meditations)", "O God!\n*(Prayers and Meditations)", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, issues := Lint(c.text)
+			if got != c.want {
+				t.Errorf("Lint(%q) repaired it to %q, want %q", c.text, got, c.want)
+			}
+			var problems []string
+			for _, issue := range issues {
+				problems = append(problems, issue.String())
+			}
+			if !reflect.DeepEqual(problems, c.issues) {
+				t.Errorf("Lint(%q) found %q, want %q", c.text, problems, c.issues)
+			}
+		})
+	}
+}
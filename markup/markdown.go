@@ -0,0 +1,126 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// markdownEscaper backslash-escapes the characters that CommonMark would
+// otherwise treat as formatting
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	">", `\>`,
+	"#", `\#`,
+)
+
+// InlineTags matches the inline HTML tags that show up in the source text of
+// some prayers, and that the parser adds to it
+var InlineTags = regexp.MustCompile(`(?i)</?(?:i|em|sup)>|<br\s*/?>`)
+
+// listMarkerRegexp matches paragraph openings that CommonMark would turn
+// into a list item
+var listMarkerRegexp = regexp.MustCompile(`^(?:[-+]|\d+[.)])\s`)
+
+// Markdown renders a parsed prayer as CommonMark, for apps that display
+// Markdown rather than HTML. The blocks are written into a single buffer,
+// separated by blank lines.
+func Markdown(doc Document) string {
+	md := strings.Builder{}
+	block := func() {
+		if md.Len() > 0 {
+			md.WriteString("\n\n")
+		}
+	}
+	for _, b := range doc.Blocks {
+		switch b := b.(type) {
+		case OpeningParagraph:
+			block()
+			markdownBlock(&md, b.Text)
+		case BodyParagraph:
+			block()
+			markdownBlock(&md, b.Text)
+		case Comment:
+			block()
+			emphasis := "*"
+			if b.Caps {
+				emphasis = "**"
+			}
+			md.WriteString(emphasis)
+			markdownInline(&md, b.Text)
+			md.WriteString(emphasis)
+		case Instruction:
+			block()
+			md.WriteString("*")
+			markdownInline(&md, b.Text)
+			md.WriteString("*")
+		case Blockquote:
+			block()
+			for i, p := range b.Paragraphs {
+				if i > 0 {
+					md.WriteString("\n>\n")
+				}
+				md.WriteString("> ")
+				markdownInline(&md, p)
+			}
+		case CenteredLine:
+			// CommonMark has no way to center text
+			block()
+			markdownBlock(&md, b.Text)
+		case Footnotes:
+			block()
+			for i, fn := range b.Notes {
+				if i > 0 {
+					md.WriteString("\n")
+				}
+				fmt.Fprintf(&md, "[^%d]: ", fn.Number)
+				markdownInline(&md, fn.Text)
+			}
+		}
+	}
+	return md.String()
+}
+
+// markdownBlock writes s to md as a paragraph, making sure it won't be
+// mistaken for a list item
+func markdownBlock(md *strings.Builder, s string) {
+	if loc := listMarkerRegexp.FindStringIndex(s); loc != nil {
+		// escape the marker's punctuation, right before the whitespace. None
+		// of the list markers is escaped by markdownInline, so the text up
+		// to it is written as is.
+		i := loc[1] - 2
+		md.WriteString(s[:i])
+		md.WriteString(`\`)
+		s = s[i:]
+	}
+	markdownInline(md, s)
+}
+
+// markdownInline writes s to md escaped for CommonMark, converting the
+// inline HTML tags it may contain to their Markdown equivalents
+func markdownInline(md *strings.Builder, s string) {
+	last := 0
+	for _, loc := range InlineTags.FindAllStringIndex(s, -1) {
+		markdownEscaper.WriteString(md, s[last:loc[0]])
+		switch tag := strings.ToLower(s[loc[0]:loc[1]]); {
+		case strings.HasPrefix(tag, "<br"):
+			md.WriteString("\\\n")
+		case tag == "<sup>":
+			// only footnote references are superscripted, so use the GFM
+			// footnote syntax
+			md.WriteString("[^")
+		case tag == "</sup>":
+			md.WriteString("]")
+		default:
+			md.WriteString("*")
+		}
+		last = loc[1]
+	}
+	markdownEscaper.WriteString(md, s[last:])
+}
@@ -0,0 +1,70 @@
+package markup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name, text string
+		want       []Block
+	}{
+		{"empty", "", nil},
+		{"paragraphs", "O God!\nPraise be", []Block{OpeningParagraph{"O God!"}, BodyParagraph{Text: "Praise be"}}},
+		{"blank lines and spacing", "  O God!  \n\n \nPraise be", []Block{OpeningParagraph{"O God!"}, BodyParagraph{Text: "Praise be"}}},
+		{"title", "#The Healing Prayer\nO God!", []Block{Title{"The Healing Prayer"}, OpeningParagraph{"O God!"}}},
+		{"caps comment", "##Prayer for the Departed\nO God!", []Block{Comment{Text: "Prayer for the Departed", Caps: true}, OpeningParagraph{"O God!"}}},
+		{"comment", "O God!\n*Kneel\nPraise be", []Block{OpeningParagraph{"O God!"}, Comment{Text: "Kneel"}, BodyParagraph{Text: "Praise be"}}},
+		{"citations at the end", "O God!\n*Translated by Shoghi Effendi\n*Prayers and Meditations", []Block{OpeningParagraph{"O God!"}, Citation{"Translated by Shoghi Effendi"}, Citation{"Prayers and Meditations"}}},
+		{"emphasis at the start isn't a comment", "*Blessed* is the spot", []Block{OpeningParagraph{"<em>Blessed</em> is the spot"}}},
+		{"emphasis", "O God!\nThou art _my_ hope", []Block{OpeningParagraph{"O God!"}, BodyParagraph{Text: "Thou art <em>my</em> hope"}}},
+		{"emphasis in a comment", "O God!\n*Say _nine_ times\nPraise be", []Block{OpeningParagraph{"O God!"}, Comment{Text: "Say <em>nine</em> times"}, BodyParagraph{Text: "Praise be"}}},
+		{"blockquotes", "O God!\n>First\n> Second\nPraise be\n>Third", []Block{
+			OpeningParagraph{"O God!"},
+			Blockquote{[]string{"First", "Second"}},
+			BodyParagraph{Text: "Praise be"},
+			Blockquote{[]string{"Third"}},
+		}},
+		{"centered line", "O God!\n= Praise be", []Block{OpeningParagraph{"O God!"}, CenteredLine{"Praise be"}}},
+		{"refrains", "O God!\nHe is God.\nPraise be\nhe is  God", []Block{
+			OpeningParagraph{"O God!"},
+			BodyParagraph{Text: "He is God.", Refrain: true},
+			BodyParagraph{Text: "Praise be"},
+			BodyParagraph{Text: "he is  God", Refrain: true},
+		}},
+		{"footnotes", "O God[1], my God[2]!\n[1] The Beloved\n[2] The Desired One", []Block{
+			OpeningParagraph{"O God<sup>1</sup>, my God<sup>2</sup>!"},
+			Footnotes{[]Footnote{{1, "The Beloved"}, {2, "The Desired One"}}},
+		}},
+		{"unreferenced bracket", "[1] O God!", []Block{OpeningParagraph{"[1] O God!"}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Parse(c.text).Blocks; !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", c.text, got, c.want)
+			}
+		})
+	}
+}
+
+func TestMarkInstructions(t *testing.T) {
+	doc := Parse("##Obligatory Prayer\nO God!\n*Kneel\nPraise be\n*Prayers and Meditations")
+	if doc.HasInstructions() {
+		t.Error("the prayer has instructions before they're marked")
+	}
+	doc.MarkInstructions()
+	if !doc.HasInstructions() {
+		t.Error("the prayer has no instructions after they're marked")
+	}
+	want := []Block{
+		Comment{Text: "Obligatory Prayer", Caps: true},
+		OpeningParagraph{"O God!"},
+		Instruction{"Kneel"},
+		BodyParagraph{Text: "Praise be"},
+		Citation{"Prayers and Meditations"},
+	}
+	if !reflect.DeepEqual(doc.Blocks, want) {
+		t.Errorf("the marked blocks are %#v, want %#v", doc.Blocks, want)
+	}
+}
@@ -0,0 +1,93 @@
+package markup
+
+import (
+	"strings"
+)
+
+// Plain renders a parsed prayer as plain text for sharing and exporting.
+// Paragraph breaks are kept, comments are bracketed, and the footnotes and
+// citations go at the end. The blocks are written into a single buffer.
+func Plain(doc Document) string {
+	plain := strings.Builder{}
+	block := func() {
+		if plain.Len() > 0 {
+			plain.WriteString("\n\n")
+		}
+	}
+	var citations []string
+	for _, b := range doc.Blocks {
+		switch b := b.(type) {
+		case OpeningParagraph:
+			block()
+			plainInline(&plain, b.Text)
+		case BodyParagraph:
+			block()
+			plainInline(&plain, b.Text)
+		case Comment:
+			block()
+			plainComment(&plain, b.Text)
+		case Instruction:
+			block()
+			plainComment(&plain, b.Text)
+		case Blockquote:
+			for _, p := range b.Paragraphs {
+				block()
+				plain.WriteString("    ")
+				plainInline(&plain, p)
+			}
+		case CenteredLine:
+			block()
+			plainInline(&plain, b.Text)
+		case Citation:
+			citations = append(citations, b.Text)
+		case Footnotes:
+			block()
+			plainFootnotes(&plain, b.Notes)
+		}
+	}
+	for _, c := range citations {
+		block()
+		plainCitation(&plain, c)
+	}
+	return plain.String()
+}
+
+// PlainInline strips the inline HTML tags from s, turning line breaks into
+// newlines and putting footnote references back in brackets
+func PlainInline(s string) string {
+	plain := strings.Builder{}
+	plainInline(&plain, s)
+	return plain.String()
+}
+
+// plainInline writes s to plain the way PlainInline returns it
+func plainInline(plain *strings.Builder, s string) {
+	last := 0
+	for _, loc := range InlineTags.FindAllStringIndex(s, -1) {
+		plain.WriteString(s[last:loc[0]])
+		switch tag := strings.ToLower(s[loc[0]:loc[1]]); {
+		case strings.HasPrefix(tag, "<br"):
+			plain.WriteString("\n")
+		case tag == "<sup>":
+			plain.WriteString("[")
+		case tag == "</sup>":
+			plain.WriteString("]")
+		}
+		last = loc[1]
+	}
+	plain.WriteString(s[last:])
+}
+
+// plainComment marks a comment paragraph in the plain text rendering, so it
+// reads apart from the words of the prayer
+func plainComment(plain *strings.Builder, s string) {
+	plain.WriteString("[")
+	plainInline(plain, s)
+	plain.WriteString("]")
+}
+
+// plainCitation marks the citation at the end of the plain text rendering
+func plainCitation(plain *strings.Builder, s string) {
+	plain.WriteString("— ")
+	plainInline(plain, s)
+}
@@ -0,0 +1,34 @@
+package markup
+
+import (
+	"strings"
+	"unicode"
+)
+
+// refrainKey normalizes a paragraph for comparison with the other
+// paragraphs of a prayer, ignoring case, spacing and trailing punctuation
+func refrainKey(p string) string {
+	key := strings.Join(strings.Fields(strings.ToLower(p)), " ")
+	return strings.TrimRightFunc(key, unicode.IsPunct)
+}
+
+// findRefrains returns the keys of the body paragraphs that occur more than
+// once in a prayer. Those are refrains (e.g. in the Fire Tablet) and get
+// marked up so the app can style them.
+func findRefrains(parts []string) map[string]bool {
+	counts := make(map[string]int)
+	for _, p := range parts {
+		if hasMarker(p) {
+			continue
+		}
+		counts[refrainKey(p)]++
+	}
+
+	refrains := make(map[string]bool)
+	for key, count := range counts {
+		if count > 1 && key != "" {
+			refrains[key] = true
+		}
+	}
+	return refrains
+}
@@ -0,0 +1,86 @@
+package markup
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+)
+
+// Quotes holds the opening and closing double quotes, then the opening and
+// closing single quotes of a language
+type Quotes [4]string
+
+// DefaultQuotes are the quotation marks of languages that use the English ones
+var DefaultQuotes = Quotes{"“", "”", "‘", "’"}
+
+// languageQuotes are the quotation marks used by languages that don't use
+// the English ones, keyed by base language
+var languageQuotes = map[string]Quotes{
+	"bg": {"„", "“", "‚", "‘"},
+	"cs": {"„", "“", "‚", "‘"},
+	"de": {"„", "“", "‚", "‘"},
+	"is": {"„", "“", "‚", "‘"},
+	"sk": {"„", "“", "‚", "‘"},
+	"hu": {"„", "”", "»", "«"},
+	"ro": {"„", "”", "«", "»"},
+	"fr": {"«\u00a0", "\u00a0»", "‹\u00a0", "\u00a0›"},
+	"es": {"«", "»", "“", "”"},
+	"it": {"«", "»", "“", "”"},
+	"pt": {"«", "»", "“", "”"},
+	"ru": {"«", "»", "„", "“"},
+	"be": {"«", "»", "„", "“"},
+	"sq": {"«", "»", "‘", "’"},
+	"fa": {"«", "»", "‹", "›"},
+	"ar": {"«", "»", "‹", "›"},
+	"ja": {"「", "」", "『", "』"},
+}
+
+// LanguageQuotes returns the quotation marks a language uses, by its ISO name
+func LanguageQuotes(isoName string) Quotes {
+	base, _ := language.Make(isoName).Base()
+	if q, ok := languageQuotes[base.String()]; ok {
+		return q
+	}
+	return DefaultQuotes
+}
+
+var punctuationReplacer = strings.NewReplacer("...", "…", "---", "—", "--", "—")
+
+var doubleSpaceRegexp = regexp.MustCompile(`[ \t\x{00a0}]{2,}`)
+
+// Typeset cleans up the typography of s: straight quotes become the given
+// quotation marks (or apostrophes, between letters), and ellipses, dashes
+// and runs of spaces are normalized
+func Typeset(s string, quotes Quotes) string {
+	s = punctuationReplacer.Replace(s)
+	s = doubleSpaceRegexp.ReplaceAllString(s, " ")
+
+	typeset := strings.Builder{}
+	typeset.Grow(len(s))
+	for i, r := range s {
+		if r != '"' && r != '\'' {
+			typeset.WriteRune(r)
+			continue
+		}
+		prev, _ := utf8.DecodeLastRuneInString(s[:i])
+		next, _ := utf8.DecodeRuneInString(s[i+1:])
+		opening := i == 0 || unicode.IsSpace(prev) || strings.ContainsRune("([{—–-", prev)
+		switch {
+		case r == '"' && opening:
+			typeset.WriteString(quotes[0])
+		case r == '"':
+			typeset.WriteString(quotes[1])
+		case unicode.IsLetter(prev) && unicode.IsLetter(next):
+			// an apostrophe, e.g. in Bahá'u'lláh
+			typeset.WriteRune('’')
+		case opening:
+			typeset.WriteString(quotes[2])
+		default:
+			typeset.WriteString(quotes[3])
+		}
+	}
+	return typeset.String()
+}
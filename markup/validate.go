@@ -0,0 +1,91 @@
+package markup
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags are the tags a Renderer may produce, along with the attributes
+// each of them may have
+var allowedTags = map[string][]string{
+	"p":          {"class"},
+	"span":       {"class"},
+	"blockquote": {"class"},
+	"ol":         {"class"},
+	"li":         {"value"},
+	"i":          nil,
+	"em":         nil,
+	"sup":        nil,
+	"br":         nil,
+}
+
+// voidTags are the allowed tags that never have an end tag
+var voidTags = map[string]bool{"br": true}
+
+// allowedClasses are the classes a Renderer may use, by their default names
+var allowedClasses = []string{"opening", "versal", "refrain", "comment", "commentcaps", "instruction", "blockquote", "centered", "noindent", "footnotes"}
+
+// Validate checks that s is a well-formed HTML fragment, using only the
+// tags, attributes and classes that the renderer is supposed to produce. It
+// returns a description of every problem it finds.
+func (r *Renderer) Validate(s string) []string {
+	classes := make(map[string]bool)
+	for _, c := range allowedClasses {
+		classes[r.className(c)] = true
+	}
+
+	var problems []string
+	var open []string
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				problems = append(problems, z.Err().Error())
+			}
+			break
+		}
+
+		tok := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			attrs, ok := allowedTags[tok.Data]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("unexpected tag <%s>", tok.Data))
+			}
+			for _, a := range tok.Attr {
+				if !contains(attrs, a.Key) {
+					problems = append(problems, fmt.Sprintf("unexpected attribute '%s' on <%s>", a.Key, tok.Data))
+				} else if a.Key == "class" && !classes[a.Val] {
+					problems = append(problems, fmt.Sprintf("unexpected class '%s' on <%s>", a.Val, tok.Data))
+				}
+			}
+			if tt == html.StartTagToken && !voidTags[tok.Data] {
+				open = append(open, tok.Data)
+			}
+		case html.EndTagToken:
+			if len(open) == 0 || open[len(open)-1] != tok.Data {
+				problems = append(problems, fmt.Sprintf("unbalanced </%s>", tok.Data))
+				continue
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	for _, tag := range open {
+		problems = append(problems, fmt.Sprintf("unclosed <%s>", tag))
+	}
+
+	return problems
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
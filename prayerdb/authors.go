@@ -0,0 +1,211 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// canonicalAuthors are the names of the authors as the app shows them
+// regardless of language, keyed by the AuthorId of the API
+var canonicalAuthors = map[int]string{
+	1: "The Báb",
+	2: "Bahá’u’lláh",
+	3: "‘Abdu’l-Bahá",
+}
+
+// authorIDMap maps the AuthorId of the API to the name of the author
+type authorIDMap map[int]string
+
+// languageAuthorMap holds the localized names of the authors, by language
+var languageAuthorMap = map[string]authorIDMap{
+	"en": map[int]string{ // English
+		1: "The Báb",
+		2: "Bahá'u'lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"es": map[int]string{ // Spanish
+		1: "El Báb",
+		2: "Bahá'u'lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"fr": map[int]string{ // French
+		1: "Le Bab",
+		2: "Bahá'u'lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"nl": map[int]string{ // Dutch
+		1: "de Báb",
+		2: "Bahá'u'lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"is": map[int]string{ // Icelandic
+		1: "Bábinn",
+		2: "Bahá’u’lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"fj": map[int]string{ // Fijian
+		1: "Na Báb",
+		2: "Bahá’u’lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"cs": map[int]string{ // Czech
+		1: "Báb",
+		2: "Bahá’u’lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"sk": map[int]string{ // Slovak
+		1: "Báb",
+		2: "Bahá’u’lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"de": map[int]string{ // German
+		1: "Báb",
+		2: "Bahá’u’lláh",
+		3: "`Abdu'l-Bahá",
+	},
+	"ru": map[int]string{ // Russian
+		1: "Баб",
+		2: "Бахаулла",
+		3: "Абдул-Баха",
+	},
+	"fa": map[int]string{ // Persian
+		1: "حضرت ربّ اعلی",
+		2: "حضرت بهاءالّله",
+		3: "حضرت عبدالبها",
+	},
+}
+
+// createAuthorsSQL creates the table of authors, named canonically and in
+// the language of the database
+const createAuthorsSQL = `CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT NOT NULL, localizedName TEXT NOT NULL)`
+
+// createMergedAuthorsSQL is createAuthorsSQL for the merged database, which
+// has a localized name for every language
+const createMergedAuthorsSQL = `CREATE TABLE authors (id INTEGER NOT NULL, language TEXT NOT NULL, name TEXT NOT NULL, localizedName TEXT NOT NULL, PRIMARY KEY (id, language))`
+
+// LocalizedAuthor returns the name of an author in a language, or "" if
+// there's no name for it in the language
+func LocalizedAuthor(lang string, authorID int) string {
+	return norm.NFC.String(languageAuthorMap[lang][authorID])
+}
+
+// populateAuthors stores the authors of a language. Languages without
+// localized names fall back to the canonical ones.
+func populateAuthors(ctx context.Context, tx *sql.Tx, lang string) error {
+	for id, name := range canonicalAuthors {
+		localizedName := LocalizedAuthor(lang, id)
+		if localizedName == "" {
+			localizedName = name
+		}
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO authors (id, name, localizedName) VALUES (?, ?, ?)`, id, name, localizedName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MissingAuthor is an AuthorId of the API that has no name in a language,
+// so its prayers would be stored without an author
+type MissingAuthor struct {
+	AuthorID int
+	// Prayers counts the prayers by the author
+	Prayers int
+	// Samples are the titles, or opening words, of a few of the prayers
+	Samples []string
+}
+
+// missingAuthorSamples is how many prayers MissingAuthors describes for each
+// author
+const missingAuthorSamples = 3
+
+// MissingAuthors checks that every author of the prayers has a name in the
+// language, returning the ones that don't, by AuthorId
+func MissingAuthors(lang string, prayers []bpnet.Prayer) []MissingAuthor {
+	byID := make(map[int]*MissingAuthor)
+	var ids []int
+	for _, p := range prayers {
+		if LocalizedAuthor(lang, p.AuthorID) != "" {
+			continue
+		}
+		missing, ok := byID[p.AuthorID]
+		if !ok {
+			missing = &MissingAuthor{AuthorID: p.AuthorID}
+			byID[p.AuthorID] = missing
+			ids = append(ids, p.AuthorID)
+		}
+		missing.Prayers++
+		if len(missing.Samples) < missingAuthorSamples {
+			missing.Samples = append(missing.Samples, sampleTitle(p))
+		}
+	}
+	sort.Ints(ids)
+	var missing []MissingAuthor
+	for _, id := range ids {
+		missing = append(missing, *byID[id])
+	}
+	return missing
+}
+
+// sampleTitle names a prayer in a report: by its title, or the start of its
+// text when it has none
+func sampleTitle(p bpnet.Prayer) string {
+	if p.Title != "" {
+		return p.Title
+	}
+	words := strings.Fields(p.Text)
+	if len(words) > 6 {
+		return strings.Join(words[:6], " ") + "…"
+	}
+	return strings.Join(words, " ")
+}
+
+// mergeAuthors copies the authors of a per-language database into the
+// merged one. Databases of the same language share their authors.
+func mergeAuthors(ctx context.Context, tx *sql.Tx, lang string) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO authors (id, language, name, localizedName) SELECT id, ?, name, localizedName FROM lang.authors`, lang)
+	return err
+}
+
+// migrateAuthors adds the authors table, and works out the authorId of each
+// prayer from its localized author name
+func migrateAuthors(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, createAuthorsSQL)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `ALTER TABLE prayers ADD COLUMN authorId INTEGER NOT NULL DEFAULT 0 REFERENCES authors (id)`)
+	if err != nil {
+		return err
+	}
+
+	var lang string
+	err = tx.QueryRowContext(ctx, `SELECT language FROM prayers LIMIT 1`).Scan(&lang)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := populateAuthors(ctx, tx, lang); err != nil {
+		return err
+	}
+	for id := range canonicalAuthors {
+		author := LocalizedAuthor(lang, id)
+		if author == "" {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE prayers SET authorId=? WHERE author=?`, id, author)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
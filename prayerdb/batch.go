@@ -0,0 +1,95 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+)
+
+// batchInserter runs a prepared statement for many rows, committing every
+// batchSize rows and logging the progress after each commit. Call flush
+// once the last row is in.
+type batchInserter struct {
+	db        *sql.DB
+	query     string
+	batchSize int
+	tx        *sql.Tx
+	stmt      *sql.Stmt
+	pending   int
+	// shared is set when the transaction belongs to the caller, who
+	// commits it
+	shared bool
+
+	// language and total describe the rows for the progress messages, and
+	// committed counts the rows committed so far
+	language  string
+	total     int
+	committed int
+}
+
+// newBatchInserter runs the prepared statement for the total rows of a
+// language, batchSize at a time
+func newBatchInserter(db *sql.DB, query string, batchSize int, language string, total int) *batchInserter {
+	return &batchInserter{db: db, query: query, batchSize: batchSize, language: language, total: total}
+}
+
+// newTxInserter runs the prepared statement within tx, for when the rows
+// have to be committed together with other changes
+func newTxInserter(ctx context.Context, tx *sql.Tx, query string) (*batchInserter, error) {
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &batchInserter{query: query, tx: tx, stmt: stmt, shared: true}, nil
+}
+
+func (b *batchInserter) insert(ctx context.Context, args ...interface{}) error {
+	if b.tx == nil {
+		tx, err := b.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(ctx, b.query)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		b.tx, b.stmt = tx, stmt
+	}
+
+	if _, err := b.stmt.ExecContext(ctx, args...); err != nil {
+		return err
+	}
+	b.pending++
+	if b.pending >= b.batchSize && !b.shared {
+		return b.flush()
+	}
+	return nil
+}
+
+// flush commits the rows inserted since the last commit
+func (b *batchInserter) flush() error {
+	if b.tx == nil || b.shared {
+		return nil
+	}
+	b.stmt.Close()
+	err := b.tx.Commit()
+	if err == nil {
+		b.committed += b.pending
+		logger().Info("committed rows", "phase", "store", "language", b.language, "rows", b.committed, "total", b.total)
+	}
+	b.tx, b.stmt, b.pending = nil, nil, 0
+	return err
+}
+
+// close rolls back any rows that weren't flushed, after an error
+func (b *batchInserter) close() {
+	if b.shared {
+		b.stmt.Close()
+		return
+	}
+	if b.tx != nil {
+		b.stmt.Close()
+		b.tx.Rollback()
+		b.tx, b.stmt, b.pending = nil, nil, 0
+	}
+}
@@ -0,0 +1,289 @@
+package prayerdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// ErrUnknownTagKind is returned when the first tag of a prayer is of a kind
+// that Categorize doesn't know to file it under
+var ErrUnknownTagKind = errors.New("unknown tag kind")
+
+// Translations names the categories that prayers are grouped under by the
+// kind of their tag, in each language. It's keyed by the ISO name of the
+// language, and then by the English name of the category: Obligatory,
+// Occassional, Tablets, The Fast or Uncategorized. An empty name is a valid
+// translation.
+type Translations map[string]map[string]string
+
+// DefaultTranslations are the names of the categories that the scraper
+// ships with
+var DefaultTranslations = Translations{
+	"en": {
+		"Obligatory":    "Obligatory",
+		"Tablets":       "Tablets",
+		"Occassional":   "Occassional",
+		"The Fast":      "The Fast",
+		"Uncategorized": "Uncategorized",
+	},
+	"de": {
+		"Obligatory":  "Pflichtgebet",
+		"Tablets":     "Tableten",
+		"Occassional": "Besondere Gelegenheiten",
+	},
+	"es": {
+		"Obligatory":  "Obligatoria",
+		"Tablets":     "Tablas",
+		"Occassional": "Ocasional",
+	},
+	"fa": {
+		"Obligatory":  "نماز",
+		"Tablets":     "الواح",
+		"Occassional": "مخصوص",
+	},
+	"ar": {
+		"Obligatory":  "صلاة",
+		"Tablets":     "",
+		"Occassional": "",
+	},
+	"fr": {
+		"Obligatory":  "Prescrites",
+		"Tablets":     "Tablettes",
+		"Occassional": "Occasionnel",
+	},
+	"ru": {
+		"Obligatory":  "Oбязательная", // TODO
+		"Tablets":     "",             // TODO
+		"Occassional": "случайный",    // TODO
+	},
+}
+
+// translate returns the name of the category in the language
+func (t Translations) translate(l bpnet.Language, category string) (string, error) {
+	if name, ok := t[l.ISOName][category]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("no translation for '%s' found for %s", category, l.ISOName)
+}
+
+// uncategorized is the English name of the category of prayers without
+// any tags or tag name, used for languages without a translation of it
+const uncategorized = "Uncategorized"
+
+// Categorize files each prayer under a category, decided by its first tag.
+// General tags are categories of their own, while the prayers with one of
+// the other kinds of tags are grouped under a name for that kind, taking
+// the name of their tag as their title. The names come from t, and
+// languages without a translation of one get the English name, with a
+// warning. Prayers without tags are filed as general ones under their
+// FirstTagName, or under Uncategorized when they don't have one either, and
+// are logged.
+func Categorize(s *Scrape, lang bpnet.Language, t Translations) error {
+	untranslated := make(map[string]bool)
+	name := func(category string) string {
+		name, err := t.translate(lang, category)
+		if err != nil {
+			if !untranslated[category] {
+				untranslated[category] = true
+				logger().Warn("category has no translation", "phase", "categorize", "language", lang.ISOName, "category", category)
+			}
+			return category
+		}
+		return name
+	}
+
+	untagged := 0
+	for i := range s.Prayers {
+		prayer := &s.Prayers[i]
+		if len(prayer.Tags) == 0 {
+			prayer.Kind = bpnet.TagKindGeneral
+			prayer.Category = prayer.FirstTagName
+			if prayer.Category == "" {
+				name, err := t.translate(lang, uncategorized)
+				if err != nil || name == "" {
+					name = uncategorized
+				}
+				prayer.Category = name
+			}
+			logger().Warn("prayer has no tags", "phase", "categorize", "language", lang.ISOName, "prayer", prayer.ID, "category", prayer.Category)
+			untagged++
+			continue
+		}
+		tag := prayer.Tags[0]
+		prayer.Kind = tag.Kind
+		switch tag.Kind {
+		case bpnet.TagKindGeneral:
+			prayer.Category = tag.Name
+		case bpnet.TagKindObligatory:
+			prayer.Category = name("Obligatory")
+			prayer.Title = tag.Name
+		case bpnet.TagKindOccassional:
+			prayer.Category = name("Occassional")
+			prayer.Title = tag.Name
+		case bpnet.TagKindTablets:
+			prayer.Category = name("Tablets")
+		default:
+			return fmt.Errorf("prayer %d: %w - %v", prayer.ID, ErrUnknownTagKind, tag.Kind)
+		}
+
+		if o := name("Obligatory"); o != "" && strings.HasPrefix(prayer.FirstTagName, o) {
+			logger().Warn("bad prayer tag", "phase", "categorize", "language", lang.ISOName, "prayer", prayer.ID)
+		}
+	}
+	if untagged > 0 {
+		logger().Warn("prayers without tags", "phase", "categorize", "language", lang.ISOName, "count", untagged)
+	}
+	return nil
+}
+
+// DefaultCategoryWeights order the categories of a language by the kind of
+// their prayers: the obligatory prayers first, then the general categories,
+// the occasional prayers and the tablets. Categories of the same weight are
+// in alphabetical order.
+var DefaultCategoryWeights = map[string]int{
+	bpnet.TagKindObligatory:  100,
+	bpnet.TagKindGeneral:     200,
+	bpnet.TagKindOccassional: 300,
+	bpnet.TagKindTablets:     400,
+}
+
+// DefaultCategoryWeight weighs a category by DefaultCategoryWeights, as a
+// general one if its kind is unknown
+func DefaultCategoryWeight(category, kind string) int {
+	if weight, ok := DefaultCategoryWeights[kind]; ok {
+		return weight
+	}
+	return DefaultCategoryWeights[bpnet.TagKindGeneral]
+}
+
+// Category is a category of the prayers of a language, in the order the app
+// lists them
+type Category struct {
+	Name string
+	// Kind is the kind of the tag of the first prayer filed under it
+	Kind   string
+	Weight int
+}
+
+// createCategoriesSQL creates the table of the categories of a language, in
+// their display order: position counts from 1, by weight and then
+// alphabetically
+const createCategoriesSQL = `CREATE TABLE categories (name TEXT PRIMARY KEY, kind TEXT NOT NULL, weight INTEGER NOT NULL, position INTEGER NOT NULL)`
+
+// createMergedCategoriesSQL is createCategoriesSQL with the language of each
+// category, for the merged database
+const createMergedCategoriesSQL = `CREATE TABLE categories (language TEXT NOT NULL, name TEXT NOT NULL, kind TEXT NOT NULL, weight INTEGER NOT NULL, position INTEGER NOT NULL, PRIMARY KEY (language, name))`
+
+// OrderCategories lists the categories of the categorized prayers of a
+// scrape in s.Categories, in their display order. weight returns the weight
+// of a category by its name and kind; lighter ones come first.
+func OrderCategories(s *Scrape, lang bpnet.Language, weight func(category, kind string) int) {
+	s.Categories = nil
+	seen := make(map[string]bool)
+	for _, prayer := range s.Prayers {
+		if seen[prayer.Category] {
+			continue
+		}
+		seen[prayer.Category] = true
+		s.Categories = append(s.Categories, Category{Name: prayer.Category, Kind: prayer.Kind, Weight: weight(prayer.Category, prayer.Kind)})
+	}
+	sortCategories(s.Categories, lang.ISOName)
+}
+
+// sortCategories sorts categories by weight, then alphabetically in the
+// language
+func sortCategories(categories []Category, isoName string) {
+	keys := make(map[string][]byte)
+	for _, c := range categories {
+		keys[c.Name] = sortKey(c.Name, isoName)
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Weight != categories[j].Weight {
+			return categories[i].Weight < categories[j].Weight
+		}
+		return bytes.Compare(keys[categories[i].Name], keys[categories[j].Name]) < 0
+	})
+}
+
+// populateCategories replaces the categories of a language with those of the
+// scrape. Scrapes that weren't ordered get the default weights.
+func populateCategories(ctx context.Context, tx *sql.Tx, s Scrape, lang bpnet.Language) error {
+	if s.Categories == nil {
+		OrderCategories(&s, lang, DefaultCategoryWeight)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories`); err != nil {
+		return err
+	}
+	return insertCategories(ctx, tx, s.Categories)
+}
+
+// insertCategories stores categories in their order
+func insertCategories(ctx context.Context, tx *sql.Tx, categories []Category) error {
+	for i, c := range categories {
+		_, err := tx.ExecContext(ctx, `INSERT INTO categories (name, kind, weight, position) VALUES (?, ?, ?, ?)`, c.Name, c.Kind, c.Weight, i+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// translatedKinds are the kinds of tags whose prayers Categorize files under
+// the translation of a name, keyed by that name
+var translatedKinds = map[string]string{
+	"Obligatory":  bpnet.TagKindObligatory,
+	"Occassional": bpnet.TagKindOccassional,
+	"Tablets":     bpnet.TagKindTablets,
+}
+
+// migrateCategories creates the categories table from the categories of the
+// prayers. Their kinds aren't stored, so the ones named like the translation
+// of a kind are taken for it, and the rest for general ones.
+func migrateCategories(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, createCategoriesSQL); err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT category, language FROM prayers WHERE deleted=0`)
+	if err != nil {
+		return err
+	}
+	var categories []Category
+	isoName := ""
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.Name, &isoName); err != nil {
+			rows.Close()
+			return err
+		}
+		c.Kind = bpnet.TagKindGeneral
+		for english, kind := range translatedKinds {
+			if name := DefaultTranslations[isoName][english]; name != "" && name == c.Name {
+				c.Kind = kind
+			}
+		}
+		c.Weight = DefaultCategoryWeight(c.Name, c.Kind)
+		categories = append(categories, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sortCategories(categories, isoName)
+	return insertCategories(ctx, tx, categories)
+}
+
+// mergeCategories copies the categories of a per-language database into the
+// merged one. Databases of the same language share their categories, which
+// keep the position the first of them gave them.
+func mergeCategories(ctx context.Context, tx *sql.Tx, lang string) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO categories (language, name, kind, weight, position) SELECT ?, name, kind, weight, position FROM lang.categories`, lang)
+	return err
+}
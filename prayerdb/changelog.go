@@ -0,0 +1,125 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sort"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// ChangedPrayer is a prayer in a Changelog, as it's listed in the app
+type ChangedPrayer struct {
+	ID           int    `json:"id"`
+	Category     string `json:"category"`
+	OpeningWords string `json:"openingWords"`
+}
+
+// LanguageChanges are the prayers a scrape added to, changed in and removed
+// from a language, compared to its database before the scrape
+type LanguageChanges struct {
+	Language string          `json:"language"`
+	Added    []ChangedPrayer `json:"added"`
+	Changed  []ChangedPrayer `json:"changed"`
+	Removed  []ChangedPrayer `json:"removed"`
+}
+
+// Empty reports whether the scrape left the language as it was
+func (lc LanguageChanges) Empty() bool {
+	return len(lc.Added) == 0 && len(lc.Changed) == 0 && len(lc.Removed) == 0
+}
+
+// Changelog collects the changes of every language scraped in a run
+type Changelog struct {
+	Languages []LanguageChanges `json:"languages"`
+}
+
+// DiffLanguage compares a scrape of a language with the prayers in its
+// database at dbPath, by their content hashes. Without a database, every
+// prayer is added. Prayers with local overrides don't change when the
+// database is updated in place, so with update they're left out.
+func DiffLanguage(ctx context.Context, dbPath string, s Scrape, lang bpnet.Language, update bool) (LanguageChanges, error) {
+	changes := LanguageChanges{Language: lang.ISOName}
+	previous := make(map[int]ChangedPrayer)
+	hashes := make(map[int]string)
+	overridden := make(map[int]bool)
+	if _, err := os.Stat(dbPath); err == nil {
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			return changes, err
+		}
+		defer db.Close()
+		rows, err := db.QueryContext(ctx, `SELECT id, category, openingWords, contentHash, overridden FROM prayers WHERE deleted=0`)
+		if err != nil {
+			return changes, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var p ChangedPrayer
+			var hash string
+			var isOverridden bool
+			if err := rows.Scan(&p.ID, &p.Category, &p.OpeningWords, &hash, &isOverridden); err != nil {
+				return changes, err
+			}
+			previous[p.ID] = p
+			hashes[p.ID] = hash
+			overridden[p.ID] = isOverridden && update
+		}
+		if err := rows.Err(); err != nil {
+			return changes, err
+		}
+	}
+
+	scraped := make(map[int]bool)
+	for _, prayer := range s.Prayers {
+		scraped[prayer.ID] = true
+		if overridden[prayer.ID] {
+			continue
+		}
+		p := ChangedPrayer{ID: prayer.ID, Category: prayer.Category, OpeningWords: prayer.OpeningWords}
+		hash, ok := hashes[prayer.ID]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, p)
+		case hash != ContentHash(prayer.RawText):
+			changes.Changed = append(changes.Changed, p)
+		}
+	}
+	for id, p := range previous {
+		if !scraped[id] && !overridden[id] {
+			changes.Removed = append(changes.Removed, p)
+		}
+	}
+
+	for _, list := range [][]ChangedPrayer{changes.Added, changes.Changed, changes.Removed} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Category != list[j].Category {
+				return list[i].Category < list[j].Category
+			}
+			return list[i].ID < list[j].ID
+		})
+	}
+	return changes, nil
+}
+
+// ChangelogSink adds the changes of every language stored to Changelog. It
+// compares with the per-language database, so it has to come before the
+// SQLiteSink that replaces it. Update is the SQLiteSink's.
+type ChangelogSink struct {
+	Changelog *Changelog
+	Update    bool
+}
+
+func (ChangelogSink) Name() string {
+	return "changelog"
+}
+
+func (c ChangelogSink) Store(ctx context.Context, s Scrape, lang bpnet.Language) error {
+	changes, err := DiffLanguage(ctx, lang.ISOName+".db", s, lang, c.Update)
+	if err != nil {
+		return err
+	}
+	c.Changelog.Languages = append(c.Changelog.Languages, changes)
+	return nil
+}
@@ -0,0 +1,157 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// LanguageCoverage is how many prayers a language has in each category of
+// the English prayers
+type LanguageCoverage struct {
+	Language string
+	// Counts are keyed by the name of the English category
+	Counts map[string]int
+	// Unmatched counts the prayers of the categories that couldn't be
+	// matched with an English one, keyed by their own name
+	Unmatched map[string]int
+}
+
+// Missing lists the English categories the language has no prayers in
+func (lc LanguageCoverage) Missing(categories []string) []string {
+	var missing []string
+	for _, c := range categories {
+		if lc.Counts[c] == 0 {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// Coverage compares the categories of every language of the merged
+// database with those of English
+type Coverage struct {
+	// Categories are the English ones, sorted
+	Categories []string
+	// English is the coverage of English itself, the baseline
+	English   LanguageCoverage
+	Languages []LanguageCoverage
+}
+
+// coveredPrayer is what CategoryCoverage reads of a prayer
+type coveredPrayer struct {
+	id                       int
+	language, category, kind string
+}
+
+// CategoryCoverage counts the prayers of every language of the merged
+// database by category, matching the categories with the English ones. The
+// categories of the kinds other than GENERAL are matched by their kind. The
+// general ones, whose names are translated, are matched with the English
+// category most of their prayers' counterparts are in, when counterparts
+// (keyed by language, then by prayer ID, as the English prayer ID) has them,
+// and otherwise by name.
+func CategoryCoverage(ctx context.Context, db *sql.DB, counterparts map[string]map[int]int) (Coverage, error) {
+	rows, err := db.QueryContext(ctx, `SELECT p.id, p.language, p.category, COALESCE(t.kind, ?) FROM prayers p
+		LEFT JOIN prayer_tags pt ON pt.prayerId=p.id AND pt.position=0
+		LEFT JOIN tags t ON t.id=pt.tagId
+		ORDER BY p.language, p.id`, bpnet.TagKindGeneral)
+	if err != nil {
+		return Coverage{}, err
+	}
+	defer rows.Close()
+	var prayers []coveredPrayer
+	for rows.Next() {
+		var p coveredPrayer
+		if err := rows.Scan(&p.id, &p.language, &p.category, &p.kind); err != nil {
+			return Coverage{}, err
+		}
+		prayers = append(prayers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return Coverage{}, err
+	}
+
+	// the English categories, by name and by the kind they were filed by
+	englishCategory := make(map[int]string)
+	englishNames := make(map[string]bool)
+	englishKinds := make(map[string]string)
+	for _, p := range prayers {
+		if p.language != "en" {
+			continue
+		}
+		englishCategory[p.id] = p.category
+		englishNames[p.category] = true
+		if p.kind != bpnet.TagKindGeneral {
+			englishKinds[p.kind] = p.category
+		}
+	}
+
+	// votes for the English category of each general category of a
+	// language, from the counterparts of its prayers
+	type languageCategory struct{ language, category string }
+	votes := make(map[languageCategory]map[string]int)
+	for _, p := range prayers {
+		englishID, ok := counterparts[p.language][p.id]
+		if !ok || p.kind != bpnet.TagKindGeneral {
+			continue
+		}
+		if c, ok := englishCategory[englishID]; ok {
+			key := languageCategory{p.language, p.category}
+			if votes[key] == nil {
+				votes[key] = make(map[string]int)
+			}
+			votes[key][c]++
+		}
+	}
+	match := func(p coveredPrayer) (string, bool) {
+		if p.language == "en" {
+			return p.category, true
+		}
+		if p.kind != bpnet.TagKindGeneral {
+			c, ok := englishKinds[p.kind]
+			return c, ok
+		}
+		best, bestVotes := "", 0
+		for c, n := range votes[languageCategory{p.language, p.category}] {
+			if n > bestVotes || (n == bestVotes && c < best) {
+				best, bestVotes = c, n
+			}
+		}
+		if bestVotes > 0 {
+			return best, true
+		}
+		return p.category, englishNames[p.category]
+	}
+
+	coverage := Coverage{}
+	for c := range englishNames {
+		coverage.Categories = append(coverage.Categories, c)
+	}
+	sort.Strings(coverage.Categories)
+	byLanguage := make(map[string]*LanguageCoverage)
+	var langs []string
+	for _, p := range prayers {
+		lc, ok := byLanguage[p.language]
+		if !ok {
+			lc = &LanguageCoverage{Language: p.language, Counts: make(map[string]int), Unmatched: make(map[string]int)}
+			byLanguage[p.language] = lc
+			langs = append(langs, p.language)
+		}
+		if c, ok := match(p); ok {
+			lc.Counts[c]++
+		} else {
+			lc.Unmatched[p.category]++
+		}
+	}
+	for _, lang := range langs {
+		if lang == "en" {
+			coverage.English = *byLanguage[lang]
+			continue
+		}
+		coverage.Languages = append(coverage.Languages, *byLanguage[lang])
+	}
+	return coverage, nil
+}
@@ -0,0 +1,135 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// DBSource reads the prayers back out of the per-language databases in Dir,
+// so they can be marked up again without the API. Only the prayers that
+// weren't removed are read, with the text they were scraped with.
+type DBSource struct {
+	Dir string
+}
+
+func (d DBSource) Language(ctx context.Context, query string) (*bpnet.Language, error) {
+	langs, err := d.Languages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bpnet.ResolveLanguage(langs, query)
+}
+
+func (d DBSource) Languages(ctx context.Context) ([]bpnet.Language, error) {
+	paths, err := filepath.Glob(filepath.Join(d.Dir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+	var langs []bpnet.Language
+	for _, path := range paths {
+		if filepath.Base(path) == "merged.db" {
+			continue
+		}
+		lang, err := dbLanguage(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the language of %s: %w", path, err)
+		}
+		langs = append(langs, lang)
+	}
+	return langs, nil
+}
+
+// dbLanguage describes the language of a per-language database from its
+// meta table, falling back to the name of the file, and keeps the source it
+// was scraped from
+func dbLanguage(ctx context.Context, dbPath string) (bpnet.Language, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return bpnet.Language{}, err
+	}
+	defer db.Close()
+
+	meta, err := ReadMeta(ctx, db)
+	if err != nil {
+		return bpnet.Language{}, err
+	}
+	isoName := meta["language"]
+	if isoName == "" {
+		isoName = strings.TrimSuffix(filepath.Base(dbPath), ".db")
+	}
+	id, _ := strconv.Atoi(meta["languageID"])
+	source := meta["source"]
+	if source == bpnet.DefaultSource {
+		source = ""
+	}
+	return bpnet.Language{ID: id, ISOName: isoName, LeftToRight: !bpnet.RightToLeft(isoName), Source: source}, nil
+}
+
+func (d DBSource) Prayers(ctx context.Context, lang bpnet.Language) (*bpnet.PrayersResponse, error) {
+	dbPath := filepath.Join(d.Dir, lang.ISOName+".db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if err := CheckSchema(ctx, dbPath, db); err != nil {
+		return nil, err
+	}
+	meta, err := ReadMeta(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := strconv.Atoi(meta["apiVersion"])
+	pr := &bpnet.PrayersResponse{Version: version}
+
+	rows, err := db.QueryContext(ctx, `SELECT id, authorId, rawText FROM prayers WHERE deleted=0 ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	indices := make(map[int]int)
+	for rows.Next() {
+		p := bpnet.Prayer{LanguageID: lang.ID}
+		if err := rows.Scan(&p.ID, &p.AuthorID, &p.Text); err != nil {
+			return nil, err
+		}
+		if p.Text == "" {
+			return nil, fmt.Errorf("prayer %d of %s has no raw text; scrape it from the API again", p.ID, dbPath)
+		}
+		indices[p.ID] = len(pr.Prayers)
+		pr.Prayers = append(pr.Prayers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tagRows, err := db.QueryContext(ctx, `SELECT pt.prayerId, t.id, t.name, t.kind FROM prayer_tags pt JOIN tags t ON t.id=pt.tagId ORDER BY pt.prayerId, pt.position`)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var prayerID int
+		var tag bpnet.Tag
+		if err := tagRows.Scan(&prayerID, &tag.ID, &tag.Name, &tag.Kind); err != nil {
+			return nil, err
+		}
+		i, ok := indices[prayerID]
+		if !ok {
+			continue
+		}
+		p := &pr.Prayers[i]
+		if len(p.Tags) == 0 {
+			p.FirstTagName = tag.Name
+		}
+		p.Tags = append(p.Tags, tag)
+	}
+	return pr, tagRows.Err()
+}
@@ -0,0 +1,204 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DeltaTables are the tables of a merged database a Delta covers. The
+// full-text index isn't one of them, since its triggers keep it in sync
+// with the prayers as the delta is applied.
+var DeltaTables = []string{"meta", "prayers", "tags", "prayer_tags", "authors", "removed_prayers", "runs", "categories"}
+
+// Delta is what changed between two merged databases, row by row, for the
+// app to bring its copy of the older one up to date without downloading the
+// newer one. It applies to a database whose meta table has From as its
+// mergedAt. The app deletes the Removed rows, updates the Changed ones and
+// inserts the Added ones, table by table, in one transaction; updating
+// rather than replacing rows keeps the full-text index right.
+type Delta struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	From          string       `json:"from"`
+	To            string       `json:"to"`
+	Tables        []TableDelta `json:"tables"`
+}
+
+// TableDelta is what changed in one table. Rows are identified by the
+// values of the Key columns, in that order.
+type TableDelta struct {
+	Table   string        `json:"table"`
+	Columns []DeltaColumn `json:"columns"`
+	Key     []string      `json:"key"`
+	// Added are whole rows, their values in the order of Columns
+	Added   [][]interface{} `json:"added,omitempty"`
+	Changed []ChangedRow    `json:"changed,omitempty"`
+	// Removed are the keys of the rows
+	Removed [][]interface{} `json:"removed,omitempty"`
+}
+
+// DeltaColumn is a column of a table in a Delta. BLOB values are base64
+// encoded in the JSON.
+type DeltaColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ChangedRow is a row of a table whose values changed, with only the
+// columns that did
+type ChangedRow struct {
+	Key []interface{}          `json:"key"`
+	Set map[string]interface{} `json:"set"`
+}
+
+// Empty reports whether nothing changed
+func (d *Delta) Empty() bool {
+	return len(d.Tables) == 0
+}
+
+// tableRows is the contents of a table, by the keys of its rows
+type tableRows struct {
+	columns []DeltaColumn
+	key     []int
+	rows    map[string][]interface{}
+	order   []string
+}
+
+// DiffMerged compares the merged databases from and to. Both have to have
+// the same schema version, since a delta only carries rows.
+func DiffMerged(ctx context.Context, from, to *sql.DB) (*Delta, error) {
+	var fromVersion, toVersion int
+	if err := from.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&fromVersion); err != nil {
+		return nil, err
+	}
+	if err := to.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&toVersion); err != nil {
+		return nil, err
+	}
+	if fromVersion != toVersion {
+		return nil, fmt.Errorf("the databases have schema versions %d and %d, and a delta can't change the schema", fromVersion, toVersion)
+	}
+
+	d := &Delta{SchemaVersion: toVersion}
+	for _, db := range []struct {
+		db *sql.DB
+		at *string
+	}{{from, &d.From}, {to, &d.To}} {
+		err := db.db.QueryRowContext(ctx, `SELECT value FROM meta WHERE key='mergedAt'`).Scan(db.at)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	for _, table := range DeltaTables {
+		old, err := readTableRows(ctx, from, table)
+		if err != nil {
+			return nil, err
+		}
+		current, err := readTableRows(ctx, to, table)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(old.columns, current.columns) {
+			return nil, fmt.Errorf("the %s tables of the databases have different columns", table)
+		}
+
+		td := TableDelta{Table: table, Columns: current.columns}
+		for _, i := range current.key {
+			td.Key = append(td.Key, current.columns[i].Name)
+		}
+		for _, k := range old.order {
+			if _, ok := current.rows[k]; !ok {
+				td.Removed = append(td.Removed, keyOf(old.rows[k], old.key))
+			}
+		}
+		for _, k := range current.order {
+			row := current.rows[k]
+			oldRow, ok := old.rows[k]
+			if !ok {
+				td.Added = append(td.Added, row)
+				continue
+			}
+			set := make(map[string]interface{})
+			for i, c := range current.columns {
+				if !reflect.DeepEqual(row[i], oldRow[i]) {
+					set[c.Name] = row[i]
+				}
+			}
+			if len(set) > 0 {
+				td.Changed = append(td.Changed, ChangedRow{Key: keyOf(row, current.key), Set: set})
+			}
+		}
+		if len(td.Added) > 0 || len(td.Changed) > 0 || len(td.Removed) > 0 {
+			d.Tables = append(d.Tables, td)
+		}
+	}
+	return d, nil
+}
+
+// readTableRows reads every row of a table, in the order of its key
+func readTableRows(ctx context.Context, db *sql.DB, table string) (*tableRows, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, type, pk FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	t := &tableRows{rows: make(map[string][]interface{})}
+	keys := make(map[int]int)
+	for rows.Next() {
+		var c DeltaColumn
+		var pk int
+		if err := rows.Scan(&c.Name, &c.Type, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if pk > 0 {
+			keys[pk] = len(t.columns)
+		}
+		t.columns = append(t.columns, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.columns) == 0 {
+		return nil, fmt.Errorf("no %s table", table)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("the %s table has no primary key", table)
+	}
+	var keyNames []string
+	for pk := 1; pk <= len(keys); pk++ {
+		t.key = append(t.key, keys[pk])
+		keyNames = append(keyNames, t.columns[keys[pk]].Name)
+	}
+
+	rows, err = db.QueryContext(ctx, fmt.Sprintf(`SELECT * FROM %s ORDER BY %s`, table, strings.Join(keyNames, ", ")))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := make([]interface{}, len(t.columns))
+		dest := make([]interface{}, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		k := fmt.Sprintf("%#v", keyOf(row, t.key))
+		t.rows[k] = row
+		t.order = append(t.order, k)
+	}
+	return t, rows.Err()
+}
+
+// keyOf returns the values of a row's key columns
+func keyOf(row []interface{}, key []int) []interface{} {
+	values := make([]interface{}, len(key))
+	for i, col := range key {
+		values[i] = row[col]
+	}
+	return values
+}
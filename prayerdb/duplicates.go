@@ -0,0 +1,121 @@
+package prayerdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// The ways Merge can handle a prayer whose text duplicates another one of
+// the same language. Duplicates are always reported; skip leaves them out of
+// the merged database, and link stores the ID of the first copy in their
+// duplicateOf column.
+const (
+	DuplicatesReport = "report"
+	DuplicatesSkip   = "skip"
+	DuplicatesLink   = "link"
+)
+
+// textHash identifies the text of a prayer in a language, ignoring markup,
+// case, accents and spacing
+func textHash(searchText, lang string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(searchText), " ")))
+	return fmt.Sprintf("%s:%x", lang, sum)
+}
+
+// duplicateOf returns the ID of an already merged prayer with the same text
+// hash as prayer id, or 0 if it's the first of its text
+func (m *merger) duplicateOf(id int, hash string) int {
+	if original, ok := m.seenTexts[hash]; ok {
+		return original
+	}
+	m.seenTexts[hash] = id
+	return 0
+}
+
+// seedTexts remembers the texts of the prayers already in the merged
+// database, which it has when appending, so the prayers merged into it are
+// checked against them too
+func (m *merger) seedTexts(ctx context.Context) error {
+	texts, err := readTextHashes(ctx, m.db, `SELECT id, language, searchText FROM prayers WHERE duplicateOf=0 ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	for _, t := range texts {
+		m.duplicateOf(t.id, t.hash)
+	}
+	return nil
+}
+
+// forgetTexts forgets the texts of languages that are being replaced, so
+// their new prayers aren't taken for duplicates of their old ones
+func (m *merger) forgetTexts(langs []string) {
+	for _, lang := range langs {
+		prefix := lang + ":"
+		for hash := range m.seenTexts {
+			if strings.HasPrefix(hash, prefix) {
+				delete(m.seenTexts, hash)
+			}
+		}
+	}
+}
+
+// hashedText is the text hash of a prayer of a per-language database
+type hashedText struct {
+	id         int
+	lang, hash string
+}
+
+// readTextHashes hashes the text of the prayers query returns as id,
+// language, searchText rows. It only reads, so it can run while other
+// databases are merged.
+func readTextHashes(ctx context.Context, db Querier, query string) ([]hashedText, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var texts []hashedText
+	for rows.Next() {
+		var id int
+		var lang, searchText string
+		if err := rows.Scan(&id, &lang, &searchText); err != nil {
+			return nil, err
+		}
+		texts = append(texts, hashedText{id: id, lang: lang, hash: textHash(searchText, lang)})
+	}
+	return texts, rows.Err()
+}
+
+// findDuplicates reports the prayers of a per-language database that
+// duplicate an already merged one. It returns the ID of the original of each
+// duplicate, and how many duplicates of each language are skipped.
+func (m *merger) findDuplicates(texts []hashedText) (map[int]int, map[string]int) {
+	duplicates := make(map[int]int)
+	langs := make(map[string]int)
+	for _, t := range texts {
+		original := m.duplicateOf(t.id, t.hash)
+		if original == 0 {
+			continue
+		}
+		logger().Info("duplicate prayer", "phase", "merge", "language", t.lang, "prayer", t.id, "duplicateOf", original)
+		duplicates[t.id] = original
+		if m.duplicateMode == DuplicatesSkip {
+			langs[t.lang]++
+		}
+	}
+	return duplicates, langs
+}
+
+// ContentHash fingerprints the raw text of a prayer, as its source sent it,
+// so changed prayers can be found without comparing the text itself. The
+// markup isn't hashed, so changing how prayers are marked up doesn't change
+// every hash.
+func ContentHash(rawText string) string {
+	sum := sha256.Sum256([]byte(norm.NFC.String(strings.TrimSpace(rawText))))
+	return fmt.Sprintf("%x", sum)
+}
@@ -0,0 +1,93 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrNoFullTextSearch is returned when a merged database is to have the
+// full-text index, but the linked SQLite doesn't have FTS5
+var ErrNoFullTextSearch = errors.New("SQLite was built without FTS5 for the full-text index; build with -tags sqlite_fts5")
+
+// createFullTextSQL creates an FTS5 index over the opening words and plain
+// text of the merged prayers. The index doesn't store its own copy of the
+// text; it reads it from the prayers table, and the triggers keep the two in
+// sync. The unicode61 tokenizer folds case and diacritics, like searchText.
+const createFullTextSQL = `
+CREATE VIRTUAL TABLE prayers_fts USING fts5(
+	openingWords,
+	plainText,
+	content='prayers',
+	content_rowid='id',
+	tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER prayers_fts_insert AFTER INSERT ON prayers BEGIN
+	INSERT INTO prayers_fts (rowid, openingWords, plainText) VALUES (new.id, new.openingWords, new.plainText);
+END;
+
+CREATE TRIGGER prayers_fts_delete AFTER DELETE ON prayers BEGIN
+	INSERT INTO prayers_fts (prayers_fts, rowid, openingWords, plainText) VALUES ('delete', old.id, old.openingWords, old.plainText);
+END;
+
+CREATE TRIGGER prayers_fts_update AFTER UPDATE ON prayers BEGIN
+	INSERT INTO prayers_fts (prayers_fts, rowid, openingWords, plainText) VALUES ('delete', old.id, old.openingWords, old.plainText);
+	INSERT INTO prayers_fts (rowid, openingWords, plainText) VALUES (new.id, new.openingWords, new.plainText);
+END;`
+
+// createFullTextIndex adds the FTS5 table and its triggers to the merged
+// database. It has to run before any prayers are inserted, so the triggers
+// index them. SQLite only has FTS5 when built with the sqlite_fts5 tag, and
+// without it this fails with ErrNoFullTextSearch.
+func createFullTextIndex(ctx context.Context, db *sql.DB) error {
+	if !FullTextSearch {
+		return ErrNoFullTextSearch
+	}
+
+	_, err := db.ExecContext(ctx, createFullTextSQL)
+	return err
+}
+
+// OptimizeFullText merges the full-text index's b-trees once all the
+// prayers are in, when the merged database has the index
+func OptimizeFullText(ctx context.Context, db *sql.DB) error {
+	indexed, err := hasFullTextIndex(ctx, db)
+	if err != nil || !indexed {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `INSERT INTO prayers_fts (prayers_fts) VALUES ('optimize')`)
+	return err
+}
+
+// hasFullTextIndex is whether a merged database has the FTS5 index, which
+// databases merged with it turned off don't
+func hasFullTextIndex(ctx context.Context, db *sql.DB) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM sqlite_master WHERE type='table' AND name='prayers_fts'`).Scan(&count)
+	return count > 0, err
+}
+
+// searchFullText runs SearchPrayers against the FTS5 index, which every
+// word has to start a word of the opening words or plain text in. The
+// prayers are ranked by bm25, best first.
+func searchFullText(ctx context.Context, db *sql.DB, words []string, language string, limit int) ([]PrayerListing, error) {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"*`
+	}
+	q := `SELECT p.id, p.language, p.category, p.openingWords, p.author, p.wordCount
+		FROM prayers_fts JOIN prayers p ON p.id=prayers_fts.rowid
+		WHERE prayers_fts MATCH ?`
+	args := []interface{}{strings.Join(terms, " ")}
+	if language != "" {
+		q, args = q+` AND p.language=?`, append(args, language)
+	}
+	q += ` ORDER BY prayers_fts.rank, p.id`
+	if limit > 0 {
+		q, args = q+` LIMIT ?`, append(args, limit)
+	}
+	return queryListings(ctx, db, q, args...)
+}
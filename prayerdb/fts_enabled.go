@@ -0,0 +1,8 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package prayerdb
+
+// FullTextSearch is whether the linked SQLite has the FTS5 extension, which
+// mattn/go-sqlite3 only builds with the sqlite_fts5 tag
+const FullTextSearch = true
@@ -0,0 +1,99 @@
+package prayerdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// scrapeFile is the JSON that JSONSink writes for a language, and that
+// JSONSource reads back
+type scrapeFile struct {
+	Language bpnet.Language
+	Version  int
+	Prayers  []Prayer
+}
+
+// JSONSink writes the scrape of each language to <ISO name>.json in Dir, so
+// it can be inspected, or marked up again later without the API
+type JSONSink struct {
+	Dir string
+}
+
+func (JSONSink) Name() string {
+	return "JSON"
+}
+
+func (j JSONSink) Store(ctx context.Context, s Scrape, lang bpnet.Language) error {
+	buf, err := json.MarshalIndent(scrapeFile{Language: lang, Version: s.Version, Prayers: s.Prayers}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(j.Dir, lang.ISOName+".json"), append(buf, '\n'), 0644)
+}
+
+// JSONSource reads the scrapes that a JSONSink wrote to Dir, handing out the
+// text of the prayers as the API sent it
+type JSONSource struct {
+	Dir string
+}
+
+func (j JSONSource) Language(ctx context.Context, query string) (*bpnet.Language, error) {
+	langs, err := j.Languages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bpnet.ResolveLanguage(langs, query)
+}
+
+func (j JSONSource) Languages(ctx context.Context) ([]bpnet.Language, error) {
+	paths, err := filepath.Glob(filepath.Join(j.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var langs []bpnet.Language
+	for _, path := range paths {
+		f, err := readScrapeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// other JSON in the directory, like -export json's, has no language
+		if f.Language.ISOName != "" {
+			langs = append(langs, f.Language)
+		}
+	}
+	return langs, nil
+}
+
+func (j JSONSource) Prayers(ctx context.Context, lang bpnet.Language) (*bpnet.PrayersResponse, error) {
+	f, err := readScrapeFile(filepath.Join(j.Dir, lang.ISOName+".json"))
+	if err != nil {
+		return nil, err
+	}
+	pr := &bpnet.PrayersResponse{Version: f.Version}
+	for _, p := range f.Prayers {
+		p.Text = p.RawText
+		pr.Prayers = append(pr.Prayers, p.Prayer)
+	}
+	return pr, nil
+}
+
+func readScrapeFile(path string) (*scrapeFile, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no scrape at %s", path)
+		}
+		return nil, err
+	}
+	f := &scrapeFile{}
+	if err := json.Unmarshal(buf, f); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,146 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ContentIssue is a problem with the content of a stored prayer that the
+// markup doesn't catch, for curators to fix upstream
+type ContentIssue struct {
+	PrayerID int
+	Language string
+	Problem  string
+}
+
+func (ci ContentIssue) String() string {
+	return fmt.Sprintf("%s prayer %d: %s", ci.Language, ci.PrayerID, ci.Problem)
+}
+
+// lintedPrayer is what LintContent reads of a prayer
+type lintedPrayer struct {
+	id                                         int
+	language, category, openingWords, citation string
+	plainText, prayerText                      string
+}
+
+// LintContent checks the prayers of a per-language or merged database for:
+//
+//   - empty text
+//   - a missing citation, in a category where most prayers have one
+//   - opening words cut off in the middle of a word
+//   - control characters, or replacement characters from broken encodings
+//   - paragraphs still starting with a '#' or '*' marker after markup
+//
+// Removed prayers aren't checked. The issues are sorted by language and
+// prayer.
+func LintContent(ctx context.Context, db *sql.DB) ([]ContentIssue, error) {
+	hasDeleted, err := hasColumn(ctx, db, "prayers", "deleted")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT id, language, category, openingWords, citation, plainText, prayerText FROM prayers`
+	if hasDeleted {
+		query += ` WHERE deleted=0`
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var prayers []lintedPrayer
+	for rows.Next() {
+		var p lintedPrayer
+		if err := rows.Scan(&p.id, &p.language, &p.category, &p.openingWords, &p.citation, &p.plainText, &p.prayerText); err != nil {
+			return nil, err
+		}
+		prayers = append(prayers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// how many prayers of each language and category there are, and how
+	// many of them have a citation
+	type categoryKey struct{ language, category string }
+	total := make(map[categoryKey]int)
+	cited := make(map[categoryKey]int)
+	for _, p := range prayers {
+		key := categoryKey{p.language, p.category}
+		total[key]++
+		if strings.TrimSpace(p.citation) != "" {
+			cited[key]++
+		}
+	}
+
+	var issues []ContentIssue
+	for _, p := range prayers {
+		report := func(problem string) {
+			issues = append(issues, ContentIssue{PrayerID: p.id, Language: p.language, Problem: problem})
+		}
+		if strings.TrimSpace(p.plainText) == "" || strings.TrimSpace(p.prayerText) == "" {
+			report("empty text")
+		}
+		key := categoryKey{p.language, p.category}
+		if strings.TrimSpace(p.citation) == "" && cited[key]*2 > total[key] {
+			report(fmt.Sprintf("no citation, though %d of the %d prayers of '%s' have one", cited[key], total[key], p.category))
+		}
+		if truncatedMidWord(p.openingWords, p.plainText) {
+			report(fmt.Sprintf("opening words cut off mid-word: '%s'", p.openingWords))
+		}
+		for _, field := range [][2]string{{"text", p.plainText}, {"opening words", p.openingWords}, {"citation", p.citation}} {
+			if r, ok := suspiciousRune(field[1]); ok {
+				report(fmt.Sprintf("suspicious character %U in the %s", r, field[0]))
+			}
+		}
+		for _, para := range strings.Split(p.plainText, "\n\n") {
+			if para = strings.TrimSpace(para); strings.HasPrefix(para, "#") || strings.HasPrefix(para, "*") {
+				report(fmt.Sprintf("raw marker left in paragraph: '%s'", openingOf(para)))
+			}
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Language != issues[j].Language {
+			return issues[i].Language < issues[j].Language
+		}
+		return issues[i].PrayerID < issues[j].PrayerID
+	})
+	return issues, nil
+}
+
+// truncatedMidWord reports whether the opening words of a prayer end in the
+// middle of a word of its text. Opening words that aren't the start of the
+// text, like titles, aren't checked.
+func truncatedMidWord(openingWords, plainText string) bool {
+	words := strings.TrimSuffix(openingWords, "…")
+	if words == "" || !strings.HasPrefix(plainText, words) || len(words) == len(plainText) {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(words)
+	next, _ := utf8.DecodeRuneInString(plainText[len(words):])
+	return unicode.IsLetter(last) && unicode.IsLetter(next)
+}
+
+// suspiciousRune returns the first control character, other than newlines
+// and tabs, or replacement character in s
+func suspiciousRune(s string) (rune, bool) {
+	for _, r := range s {
+		if r == utf8.RuneError || (unicode.IsControl(r) && r != '\n' && r != '\t') {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// openingOf shortens a paragraph to its first few words, for reports
+func openingOf(para string) string {
+	if words := strings.Fields(para); len(words) > 6 {
+		return strings.Join(words[:6], " ") + "…"
+	}
+	return para
+}
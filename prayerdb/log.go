@@ -0,0 +1,14 @@
+package prayerdb
+
+import "log/slog"
+
+// Logger gets what the package logs, with the phase, language and prayer a
+// message is about as attributes. When nil, slog.Default() is used.
+var Logger *slog.Logger
+
+func logger() *slog.Logger {
+	if Logger != nil {
+		return Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,400 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MergedIndices are the indices IndexMerged makes
+var MergedIndices = []string{"language_index", "category_language_index", "language_sort_key_index", "prayer_tags_tag_index"}
+
+// CreateMerged sets up a new merged database, with the FTS5 full-text index
+// when fullText is set, which fails with ErrNoFullTextSearch unless the
+// linked SQLite has FTS5
+func CreateMerged(ctx context.Context, db *sql.DB, fullText bool) error {
+	const createTableSQL = `
+	CREATE TABLE prayers (	id INTEGER PRIMARY KEY,
+							category TEXT NOT NULL,
+							prayerText TEXT NOT NULL,
+							openingWords TEXT NOT NULL,
+							citation TEXT NOT NULL,
+							author TEXT NOT NULL,
+							authorId INTEGER NOT NULL,
+							language TEXT NOT NULL,
+							wordCount INTEGER NOT NULL,
+							searchText TEXT NOT NULL,
+							sortKey BLOB NOT NULL,
+							plainText TEXT NOT NULL,
+							footnotes TEXT NOT NULL,
+							rawText TEXT NOT NULL,
+							hasInstructions INTEGER NOT NULL,
+							duplicateOf INTEGER NOT NULL,
+							contentHash TEXT NOT NULL,
+							createdAt TEXT NOT NULL,
+							updatedAt TEXT NOT NULL,
+							sortOrder INTEGER NOT NULL,
+							source TEXT NOT NULL)`
+
+	for _, query := range []string{createTableSQL, createMergedTagsSQL, createMergedAuthorsSQL, createRemovedSQL, createRunsSQL, createMergedCategoriesSQL, createMetaTableSQL} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	if err := stampSchema(ctx, db, MergedSchemaVersion); err != nil {
+		return err
+	}
+	if !fullText {
+		return nil
+	}
+	return createFullTextIndex(ctx, db)
+}
+
+// IndexMerged indexes a merged database once it's filled
+func IndexMerged(ctx context.Context, db *sql.DB) error {
+	for _, query := range []string{
+		`CREATE INDEX language_index ON prayers (language)`,
+		`CREATE INDEX category_language_index on prayers (category,language)`,
+		`CREATE INDEX language_sort_key_index on prayers (language,sortKey)`,
+		mergedTagsIndexSQL,
+	} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergeInput is what Merge reads of a per-language database before writing
+// to the merged one: its provenance, its languages and the hashes of its
+// texts, which finding duplicates takes most of the reading for. Reading it
+// doesn't touch the merged database, so the inputs of several databases can
+// be read while another is being merged. The rows themselves are copied by
+// SQLite, from the database attached to the merged one.
+type MergeInput struct {
+	Path  string
+	meta  map[string]string
+	langs []string
+	texts []hashedText
+}
+
+// ReadMergeInput reads the MergeInput of the per-language database at path
+func ReadMergeInput(ctx context.Context, path string) (*MergeInput, error) {
+	langDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer langDB.Close()
+
+	in := &MergeInput{Path: path}
+	if in.meta, err = ReadMeta(ctx, langDB); err != nil {
+		return nil, err
+	}
+	if in.langs, err = Languages(ctx, langDB); err != nil {
+		return nil, err
+	}
+	if in.texts, err = readTextHashes(ctx, langDB, `SELECT id, language, searchText FROM prayers WHERE deleted=0 ORDER BY id`); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// merger writes per-language databases to a merged one. It lives for one
+// merge, and remembers the texts merged so far to find duplicates.
+type merger struct {
+	db            *sql.DB
+	duplicateMode string
+	replace       bool
+	// seenTexts maps the text hash of every prayer merged so far to its ID
+	seenTexts map[string]int
+}
+
+// newMerger starts a merge into mergedDB. When appending, the merged
+// database already has prayers, and the ones merged into it are checked
+// against them for duplicates.
+func newMerger(ctx context.Context, mergedDB *sql.DB, duplicateMode string, replace bool) (*merger, error) {
+	m := &merger{
+		db:            mergedDB,
+		duplicateMode: duplicateMode,
+		replace:       replace,
+		seenTexts:     make(map[string]int),
+	}
+	if err := m.seedTexts(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MergeAll merges the per-language databases at paths into the merged one,
+// the way Merge does one by one. The MergeInput of up to readers of them is
+// read ahead concurrently, while the calling goroutine copies them into the
+// merged database one at a time, in the order of paths, so the result is
+// the same as merging them in a loop. merged is called after each database
+// is in. It returns how many duplicates of each language were skipped.
+func MergeAll(ctx context.Context, mergedDB *sql.DB, paths []string, readers int, duplicateMode string, replace bool, merged func(path string)) (map[string]int, error) {
+	if readers < 1 {
+		readers = 1
+	}
+	m, err := newMerger(ctx, mergedDB, duplicateMode, replace)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type readResult struct {
+		in  *MergeInput
+		err error
+	}
+	// each database gets a channel of its own, so the writer can take them
+	// in order however the reads finish
+	results := make([]chan readResult, len(paths))
+	for i := range results {
+		results[i] = make(chan readResult, 1)
+	}
+	// a slot is taken for every database read and not yet merged, which
+	// bounds how far ahead the readers get
+	slots := make(chan struct{}, readers)
+	go func() {
+		for i, path := range paths {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, path string) {
+				in, err := ReadMergeInput(ctx, path)
+				results[i] <- readResult{in, err}
+			}(i, path)
+		}
+	}()
+
+	skipped := make(map[string]int)
+	for i, path := range paths {
+		var r readResult
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		<-slots
+		if r.err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", path, r.err)
+		}
+		counts, err := m.merge(ctx, r.in)
+		if err != nil {
+			return nil, fmt.Errorf("unable to merge %s: %w", path, err)
+		}
+		for lang, count := range counts {
+			skipped[lang] += count
+		}
+		if merged != nil {
+			merged(path)
+		}
+	}
+	return skipped, nil
+}
+
+// Merge copies the prayers of a per-language database into the merged one,
+// along with its provenance. The database is attached to the merged one, so
+// the rows are copied by SQLite itself instead of passing through Go. With
+// replace, the rows of its languages already in the merged database are
+// deleted in the same transaction. duplicateMode is one of the Duplicates
+// constants. It returns how many duplicates of each language it skipped.
+func Merge(ctx context.Context, mergedDB *sql.DB, langDBPath string, duplicateMode string, replace bool) (map[string]int, error) {
+	in, err := ReadMergeInput(ctx, langDBPath)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMerger(ctx, mergedDB, duplicateMode, replace)
+	if err != nil {
+		return nil, err
+	}
+	return m.merge(ctx, in)
+}
+
+// merge writes a per-language database, whose MergeInput has been read, to
+// the merged database
+func (m *merger) merge(ctx context.Context, in *MergeInput) (map[string]int, error) {
+	// ATTACH doesn't work inside a transaction, and only applies to the
+	// connection it runs on
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS lang`, in.Path); err != nil {
+		return nil, err
+	}
+	skipped, err := m.copy(ctx, conn, in)
+	// the connection goes back to the pool, so lang has to be detached even
+	// when ctx is cancelled, or the next ATTACH on it fails
+	if _, detachErr := conn.ExecContext(context.Background(), `DETACH DATABASE lang`); detachErr != nil && err == nil {
+		err = detachErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}
+
+// copy copies the rows of the per-language database attached as lang on
+// conn into the merged database, in one transaction
+func (m *merger) copy(ctx context.Context, conn *sql.Conn, in *MergeInput) (map[string]int, error) {
+	meta, langs := in.meta, in.langs
+	if m.replace {
+		m.forgetTexts(langs)
+	}
+	duplicates, skippedLangs := m.findDuplicates(in.texts)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if m.replace {
+		for _, lang := range langs {
+			if err := deleteLanguage(ctx, tx, lang); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE TEMP TABLE merge_duplicates (id INTEGER PRIMARY KEY, duplicateOf INTEGER NOT NULL, skip INTEGER NOT NULL)`)
+	if err != nil {
+		return nil, err
+	}
+	for id, original := range duplicates {
+		skip := m.duplicateMode == DuplicatesSkip
+		if m.duplicateMode != DuplicatesLink {
+			original = 0
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO temp.merge_duplicates (id, duplicateOf, skip) VALUES (?, ?, ?)`, id, original, skip)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO prayers (id, category, prayerText, openingWords, citation, author, authorId, language, wordCount, searchText, sortKey, plainText, footnotes, rawText, hasInstructions, duplicateOf, contentHash, createdAt, updatedAt, sortOrder, source)
+		SELECT p.id, p.category, p.prayerText, p.openingWords, p.citation, p.author, p.authorId, p.language, p.wordCount, p.searchText, p.sortKey, p.plainText, p.footnotes, p.rawText, p.hasInstructions, COALESCE(d.duplicateOf, 0), p.contentHash, p.createdAt, p.updatedAt, p.sortOrder, p.source
+		FROM lang.prayers p LEFT JOIN temp.merge_duplicates d ON d.id=p.id
+		WHERE p.deleted=0 AND COALESCE(d.skip, 0)=0`)
+	if err != nil {
+		return nil, err
+	}
+
+	languages, err := LanguageCounts(ctx, tx, `SELECT language, count(*) FROM lang.prayers WHERE deleted=0 AND id NOT IN (SELECT id FROM temp.merge_duplicates WHERE skip=1) GROUP BY language`)
+	if err != nil {
+		return nil, err
+	}
+
+	// databases migrated from before the meta table don't know their
+	// language, but their prayers do
+	dbLang := meta["language"]
+	for lang := range languages {
+		// databases of the same language add up to the language's count
+		var langCount int
+		err = tx.QueryRowContext(ctx, `SELECT count(*) FROM prayers WHERE language=?`, lang).Scan(&langCount)
+		if err != nil {
+			return nil, err
+		}
+		err = WriteMeta(ctx, tx, languageMeta(lang, meta, langCount))
+		if err != nil {
+			return nil, err
+		}
+		if dbLang == "" {
+			dbLang = lang
+		}
+	}
+
+	err = mergeTags(ctx, tx, dbLang)
+	if err != nil {
+		return nil, err
+	}
+	err = mergeAuthors(ctx, tx, dbLang)
+	if err != nil {
+		return nil, err
+	}
+	err = mergeCategories(ctx, tx, dbLang)
+	if err != nil {
+		return nil, err
+	}
+	err = mergeRemoved(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	err = mergeRuns(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `DROP TABLE temp.merge_duplicates`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return skippedLangs, nil
+}
+
+// Languages lists the languages of the prayers in a database
+func Languages(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT language FROM prayers WHERE deleted=0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var langs []string
+	for rows.Next() {
+		var lang string
+		if err := rows.Scan(&lang); err != nil {
+			return nil, err
+		}
+		langs = append(langs, lang)
+	}
+	return langs, rows.Err()
+}
+
+// LanguageCounts runs a query returning language, count rows
+func LanguageCounts(ctx context.Context, db Querier, query string) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var lang string
+		var count int
+		if err := rows.Scan(&lang, &count); err != nil {
+			return nil, err
+		}
+		counts[lang] = count
+	}
+	return counts, rows.Err()
+}
+
+// deleteLanguage removes a language from the merged database
+func deleteLanguage(ctx context.Context, tx *sql.Tx, lang string) error {
+	statements := []string{
+		`DELETE FROM prayer_tags WHERE prayerId IN (SELECT id FROM prayers WHERE language=?)`,
+		`DELETE FROM prayers WHERE language=?`,
+		`DELETE FROM tags WHERE language=?`,
+		`DELETE FROM authors WHERE language=?`,
+		`DELETE FROM removed_prayers WHERE language=?`,
+		`DELETE FROM categories WHERE language=?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, lang); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM meta WHERE substr(key, 1, length(?)) = ?`, lang+".", lang+".")
+	return err
+}
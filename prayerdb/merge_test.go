@@ -0,0 +1,275 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+var (
+	english = bpnet.Language{ID: 1, Name: "English", EnglishName: "English", ISOName: "en", LeftToRight: true}
+	french  = bpnet.Language{ID: 2, Name: "Français", EnglishName: "French", ISOName: "fr", LeftToRight: true}
+)
+
+// testPrayer is a prayer of Bahá'u'lláh made of a paragraph of text
+func testPrayer(id int, category, text string, tags ...bpnet.Tag) Prayer {
+	return Prayer{
+		Prayer:       bpnet.Prayer{ID: id, AuthorID: 2, Tags: tags},
+		RawText:      text,
+		Category:     category,
+		PrayerText:   "<p>" + text + "</p>",
+		PlainText:    text,
+		OpeningWords: text,
+	}
+}
+
+// populate writes the database of a language to name in dir, the way
+// Populate writes it to the current directory
+func populate(t *testing.T, dir, name string, lang bpnet.Language, prayers ...Prayer) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	opts := Options{ScrapeTime: "2024-01-01T00:00:00Z", ScraperVersion: "test"}
+	if err := Populate(context.Background(), Scrape{Version: 1, Prayers: prayers}, lang, false, opts); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.Rename(filepath.Join(dir, lang.ISOName+".db"), path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// createMerged makes an empty merged database in dir
+func createMerged(t *testing.T, dir string) *sql.DB {
+	t.Helper()
+	db := openDB(t, filepath.Join(dir, "merged.db"))
+	if err := CreateMerged(context.Background(), db, false); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// count runs a query returning a single count
+func count(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestMergeAll(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	paths := []string{
+		populate(t, dir, "en.db", english,
+			testPrayer(1, "General", "O God, my God!", bpnet.Tag{ID: 10, Name: "General", Kind: bpnet.TagKindGeneral}),
+			testPrayer(2, "Healing", "Thy name is my healing", bpnet.Tag{ID: 11, Name: "Healing", Kind: bpnet.TagKindGeneral})),
+		populate(t, dir, "fr.db", french,
+			testPrayer(3, "Général", "Ô Dieu, mon Dieu !", bpnet.Tag{ID: 20, Name: "Général", Kind: bpnet.TagKindGeneral})),
+	}
+	db := createMerged(t, dir)
+
+	var merged []string
+	skipped, err := MergeAll(ctx, db, paths, 2, DuplicatesReport, false, func(path string) { merged = append(merged, path) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("skipped %v, want none", skipped)
+	}
+	if !reflect.DeepEqual(merged, paths) {
+		t.Errorf("merged %v, want %v", merged, paths)
+	}
+
+	version, err := SchemaVersion(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != MergedSchemaVersion {
+		t.Errorf("the schema version is %d, want %d", version, MergedSchemaVersion)
+	}
+	for _, c := range []struct {
+		query string
+		want  int
+	}{
+		{`SELECT count(*) FROM prayers WHERE language='en'`, 2},
+		{`SELECT count(*) FROM prayers WHERE language='fr'`, 1},
+		{`SELECT count(*) FROM tags WHERE language='en'`, 2},
+		{`SELECT count(*) FROM tags WHERE language='fr'`, 1},
+		{`SELECT count(*) FROM prayer_tags`, 3},
+		{`SELECT count(*) FROM categories WHERE language='en'`, 2},
+		{`SELECT count(*) FROM authors WHERE language='fr'`, 3},
+		{`SELECT count(*) FROM prayers WHERE wordCount=0 OR searchText='' OR contentHash=''`, 0},
+	} {
+		if got := count(t, db, c.query); got != c.want {
+			t.Errorf("%s = %d, want %d", c.query, got, c.want)
+		}
+	}
+
+	meta, err := ReadMeta(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"en.prayerCount": "2", "fr.prayerCount": "1", "fr.languageID": "2", "en.scraperVersion": "test"} {
+		if meta[key] != want {
+			t.Errorf("meta %s is %q, want %q", key, meta[key], want)
+		}
+	}
+	if _, ok := meta["en.language"]; ok {
+		t.Error("the language of a database is copied to the merged meta")
+	}
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	cases := []struct {
+		mode string
+		// prayers are the English prayers merged, and duplicateOf what the
+		// second one links to
+		prayers     int
+		duplicateOf int
+		skipped     map[string]int
+	}{
+		{DuplicatesReport, 3, 0, map[string]int{}},
+		{DuplicatesSkip, 2, 0, map[string]int{"en": 1}},
+		{DuplicatesLink, 3, 1, map[string]int{}},
+	}
+	for _, c := range cases {
+		t.Run(c.mode, func(t *testing.T) {
+			dir := t.TempDir()
+			// the duplicate differs in markup, case and spacing, and the
+			// French prayer has the same text in another language
+			en := populate(t, dir, "en.db", english,
+				testPrayer(1, "General", "O God, my God!"),
+				testPrayer(2, "General", "O  god, my <em>God!</em>"),
+				testPrayer(3, "General", "Thy name is my healing"))
+			fr := populate(t, dir, "fr.db", french, testPrayer(4, "General", "O God, my God!"))
+			db := createMerged(t, dir)
+
+			skipped, err := MergeAll(context.Background(), db, []string{en, fr}, 1, c.mode, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(skipped, c.skipped) {
+				t.Errorf("skipped %v, want %v", skipped, c.skipped)
+			}
+			if got := count(t, db, `SELECT count(*) FROM prayers WHERE language='en'`); got != c.prayers {
+				t.Errorf("merged %d English prayers, want %d", got, c.prayers)
+			}
+			if got := count(t, db, `SELECT count(*) FROM prayers WHERE language='fr'`); got != 1 {
+				t.Errorf("merged %d French prayers, want 1", got)
+			}
+			if got := count(t, db, `SELECT coalesce(max(duplicateOf), 0) FROM prayers WHERE id=2`); got != c.duplicateOf {
+				t.Errorf("the duplicate links to %d, want %d", got, c.duplicateOf)
+			}
+			meta, err := ReadMeta(context.Background(), db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := c.prayers; meta["en.prayerCount"] != strconv.Itoa(want) {
+				t.Errorf("meta en.prayerCount is %q, want %d", meta["en.prayerCount"], want)
+			}
+		})
+	}
+}
+
+func TestMergeSameLanguage(t *testing.T) {
+	dir := t.TempDir()
+	tag := bpnet.Tag{ID: 10, Name: "General", Kind: bpnet.TagKindGeneral}
+	api := populate(t, dir, "en-api.db", english, testPrayer(1, "General", "O God, my God!", tag))
+	files := populate(t, dir, "en-files.db", bpnet.Language{ID: 1, ISOName: "en", Source: "files"}, testPrayer(2, "General", "Thy name is my healing", tag))
+	db := createMerged(t, dir)
+
+	if _, err := MergeAll(context.Background(), db, []string{api, files}, 1, DuplicatesReport, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		query string
+		want  int
+	}{
+		{`SELECT count(*) FROM prayers WHERE language='en'`, 2},
+		{`SELECT count(*) FROM prayers WHERE source='files'`, 1},
+		{`SELECT count(*) FROM tags`, 1},
+		{`SELECT count(*) FROM prayer_tags WHERE tagId=10`, 2},
+		{`SELECT count(*) FROM categories`, 1},
+	} {
+		if got := count(t, db, c.query); got != c.want {
+			t.Errorf("%s = %d, want %d", c.query, got, c.want)
+		}
+	}
+	meta, err := ReadMeta(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta["en.prayerCount"] != "2" {
+		t.Errorf("meta en.prayerCount is %q, want the two databases' 2", meta["en.prayerCount"])
+	}
+}
+
+func TestMergeReplace(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	old := populate(t, dir, "en-old.db", english,
+		testPrayer(1, "General", "O God, my God!", bpnet.Tag{ID: 10, Name: "General", Kind: bpnet.TagKindGeneral}),
+		testPrayer(2, "Healing", "Thy name is my healing"))
+	fr := populate(t, dir, "fr.db", french, testPrayer(3, "Général", "Ô Dieu, mon Dieu !"))
+	// the new scrape has the first prayer again, which isn't a duplicate of
+	// the one it replaces
+	updated := populate(t, dir, "en.db", english,
+		testPrayer(1, "General", "O God, my God!", bpnet.Tag{ID: 10, Name: "General", Kind: bpnet.TagKindGeneral}),
+		testPrayer(4, "General", "Blessed is the spot"))
+	db := createMerged(t, dir)
+
+	if _, err := MergeAll(ctx, db, []string{old, fr}, 1, DuplicatesSkip, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Merge(ctx, db, updated, DuplicatesSkip, false); err == nil {
+		t.Error("merged the same prayers twice without replacing them")
+	}
+	skipped, err := Merge(ctx, db, updated, DuplicatesSkip, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("skipped %v, want none", skipped)
+	}
+
+	for _, c := range []struct {
+		query string
+		want  int
+	}{
+		{`SELECT count(*) FROM prayers WHERE language='en'`, 2},
+		{`SELECT count(*) FROM prayers WHERE id=2`, 0},
+		{`SELECT count(*) FROM prayers WHERE id=4`, 1},
+		{`SELECT count(*) FROM prayers WHERE language='fr'`, 1},
+		{`SELECT count(*) FROM prayer_tags`, 1},
+		{`SELECT count(*) FROM categories WHERE language='en'`, 1},
+		{`SELECT count(*) FROM categories WHERE language='fr'`, 1},
+	} {
+		if got := count(t, db, c.query); got != c.want {
+			t.Errorf("%s = %d, want %d", c.query, got, c.want)
+		}
+	}
+	meta, err := ReadMeta(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta["en.prayerCount"] != "2" || meta["fr.prayerCount"] != "1" {
+		t.Errorf("the prayer counts are en %q and fr %q, want 2 and 1", meta["en.prayerCount"], meta["fr.prayerCount"])
+	}
+}
@@ -0,0 +1,71 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+const createMetaTableSQL = `CREATE TABLE meta (key TEXT PRIMARY KEY, value TEXT NOT NULL)`
+
+// WriteMeta stores the key value pairs in the meta table
+func WriteMeta(ctx context.Context, db Execer, meta map[string]string) error {
+	for key, value := range meta {
+		_, err := db.ExecContext(ctx, `INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scrapeMeta describes the scrape that produced a per-language database
+func scrapeMeta(s Scrape, lang bpnet.Language, opts Options) map[string]string {
+	return map[string]string{
+		"scrapedAt":      opts.ScrapeTime,
+		"scraperVersion": opts.ScraperVersion,
+		"apiBaseURL":     bpnet.BaseURL,
+		"apiVersion":     strconv.Itoa(s.Version),
+		"language":       lang.ISOName,
+		"languageID":     strconv.Itoa(lang.ID),
+		"prayerCount":    strconv.Itoa(len(s.Prayers)),
+		"source":         lang.SourceName(),
+	}
+}
+
+// ReadMeta returns the contents of a database's meta table. Databases
+// migrated from before there was one have an empty table.
+func ReadMeta(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT key, value FROM meta`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	meta := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		meta[key] = value
+	}
+	return meta, rows.Err()
+}
+
+// languageMeta namespaces the provenance of a per-language database for the
+// meta table of the merged database, e.g. de.apiVersion
+func languageMeta(lang string, meta map[string]string, prayerCount int) map[string]string {
+	namespaced := make(map[string]string)
+	for key, value := range meta {
+		if key == "language" {
+			continue
+		}
+		namespaced[fmt.Sprintf("%s.%s", lang, key)] = value
+	}
+	namespaced[lang+".prayerCount"] = strconv.Itoa(prayerCount)
+	return namespaced
+}
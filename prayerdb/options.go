@@ -0,0 +1,40 @@
+package prayerdb
+
+import "time"
+
+// DefaultBatchSize is how many rows go into each transaction when filling a
+// database, unless the Options say otherwise
+const DefaultBatchSize = 500
+
+// Options are the settings of a run of the scraper that go into the
+// databases it writes. The sinks carry them, so runs in the same process
+// don't share them. Fields left zero get their defaults.
+type Options struct {
+	// ScrapeTime is when the run started, in RFC 3339, used for every
+	// timestamp it writes. It defaults to when the database is written.
+	ScrapeTime string
+	// ScraperVersion identifies the build of the scraper, for the meta and
+	// runs tables
+	ScraperVersion string
+	// BatchSize is how many rows go into each transaction when filling a
+	// database
+	BatchSize int
+}
+
+// NewOptions returns the Options of a run starting now
+func NewOptions() Options {
+	return Options{}.withDefaults()
+}
+
+func (o Options) withDefaults() Options {
+	if o.ScrapeTime == "" {
+		o.ScrapeTime = time.Now().UTC().Format(time.RFC3339)
+	}
+	if o.ScraperVersion == "" {
+		o.ScraperVersion = "unknown"
+	}
+	if o.BatchSize < 1 {
+		o.BatchSize = DefaultBatchSize
+	}
+	return o
+}
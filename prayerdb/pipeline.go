@@ -0,0 +1,169 @@
+package prayerdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// PrayerSource is where a scrape gets the prayers of a language from
+type PrayerSource interface {
+	// Language finds the language a query names, as an id, ISO code or
+	// name
+	Language(ctx context.Context, query string) (*bpnet.Language, error)
+	// Languages lists every language the source has prayers in
+	Languages(ctx context.Context) ([]bpnet.Language, error)
+	// Prayers returns the prayers of the language, with their text as the
+	// API sent it
+	Prayers(ctx context.Context, lang bpnet.Language) (*bpnet.PrayersResponse, error)
+}
+
+// PrayerSink is somewhere a scraped language gets stored
+type PrayerSink interface {
+	// Name describes the sink in progress messages
+	Name() string
+	// Store saves the marked up prayers of a language, replacing what the
+	// sink had for it before
+	Store(ctx context.Context, s Scrape, lang bpnet.Language) error
+}
+
+// APISource gets the prayers from the bahaiprayers.net API through Client,
+// or bpnet.DefaultClient when it's nil. A Client with another BaseURL reads
+// them from an alternative API that answers like bahaiprayers.net, and its
+// languages are sourced from its host.
+type APISource struct {
+	Client *bpnet.Client
+}
+
+func (a APISource) client() *bpnet.Client {
+	if a.Client != nil {
+		return a.Client
+	}
+	return bpnet.DefaultClient
+}
+
+// source is the Source of the languages of the API, empty for
+// bahaiprayers.net
+func (a APISource) source() string {
+	base := a.client().BaseURL
+	if base == "" || base == bpnet.BaseURL {
+		return ""
+	}
+	if u, err := url.Parse(base); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return base
+}
+
+func (a APISource) Language(ctx context.Context, query string) (*bpnet.Language, error) {
+	lang, err := a.client().LookUpLanguage(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	lang.Source = a.source()
+	return lang, nil
+}
+
+func (a APISource) Languages(ctx context.Context) ([]bpnet.Language, error) {
+	langs, err := a.client().Languages(ctx)
+	for i := range langs {
+		langs[i].Source = a.source()
+	}
+	return langs, err
+}
+
+func (a APISource) Prayers(ctx context.Context, lang bpnet.Language) (*bpnet.PrayersResponse, error) {
+	return a.client().PrayersByLanguage(ctx, lang.ID)
+}
+
+// Sources reads each language from the first of its sources that has it, so
+// the API can be supplemented with the languages it doesn't cover
+type Sources []PrayerSource
+
+func (s Sources) Language(ctx context.Context, query string) (*bpnet.Language, error) {
+	var firstErr error
+	for _, source := range s {
+		lang, err := source.Language(ctx, query)
+		if err == nil {
+			return lang, nil
+		}
+		if !errors.Is(err, bpnet.ErrLanguageNotFound) {
+			return nil, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("%w: '%s'", bpnet.ErrLanguageNotFound, query)
+	}
+	return nil, firstErr
+}
+
+func (s Sources) Languages(ctx context.Context) ([]bpnet.Language, error) {
+	var all []bpnet.Language
+	seen := make(map[string]bool)
+	for _, source := range s {
+		langs, err := source.Languages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, lang := range langs {
+			if !seen[lang.ISOName] {
+				seen[lang.ISOName] = true
+				all = append(all, lang)
+			}
+		}
+	}
+	return all, nil
+}
+
+func (s Sources) Prayers(ctx context.Context, lang bpnet.Language) (*bpnet.PrayersResponse, error) {
+	for _, source := range s {
+		langs, err := source.Languages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range langs {
+			if l.ISOName == lang.ISOName {
+				return source.Prayers(ctx, l)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s'", bpnet.ErrLanguageNotFound, lang.ISOName)
+}
+
+// SQLiteSink writes the per-language SQLite database that gets merged for
+// the app. With Update, an existing database is updated in place, as
+// Populate describes.
+type SQLiteSink struct {
+	Update  bool
+	Options Options
+}
+
+func (SQLiteSink) Name() string {
+	return "database"
+}
+
+func (s SQLiteSink) Store(ctx context.Context, scrape Scrape, lang bpnet.Language) error {
+	return Populate(ctx, scrape, lang, s.Update, s.Options)
+}
+
+// PostgresSink stores scraped languages in the Postgres database at DSN, for
+// server-side search and APIs. The program has to register the "postgres"
+// driver.
+type PostgresSink struct {
+	DSN     string
+	Options Options
+}
+
+func (PostgresSink) Name() string {
+	return "Postgres"
+}
+
+func (p PostgresSink) Store(ctx context.Context, s Scrape, lang bpnet.Language) error {
+	return StorePostgres(ctx, p.DSN, s, lang, p.Options)
+}
@@ -0,0 +1,171 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// postgresDriver is the database/sql driver StorePostgres uses. It has to be
+// registered by the program, e.g. by importing lib/pq.
+const postgresDriver = "postgres"
+
+// postgresTypes are the Postgres types of the prayerColumns
+var postgresTypes = map[string]string{
+	"id":              "BIGINT PRIMARY KEY",
+	"hasInstructions": "BOOLEAN NOT NULL",
+	"authorId":        "INTEGER NOT NULL",
+	"wordCount":       "INTEGER NOT NULL",
+	"sortKey":         "BYTEA NOT NULL",
+	"sortOrder":       "INTEGER NOT NULL",
+	"createdAt":       "TIMESTAMPTZ NOT NULL",
+	"updatedAt":       "TIMESTAMPTZ NOT NULL",
+}
+
+// createPostgresSQL sets up the Postgres schema. It mirrors the merged
+// SQLite database, except that removed prayers stay in the prayers table
+// with a removedAt, and searchVector indexes searchText for full-text
+// queries. Postgres folds the unquoted camelCase names to lower case.
+func createPostgresSQL() string {
+	var columns []string
+	for _, column := range prayerColumns {
+		sqlType, ok := postgresTypes[column]
+		if !ok {
+			sqlType = "TEXT NOT NULL"
+		}
+		columns = append(columns, column+" "+sqlType)
+	}
+	columns = append(columns,
+		"removedAt TIMESTAMPTZ",
+		"searchVector TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', searchText)) STORED")
+
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS prayers (
+	%s
+);
+CREATE INDEX IF NOT EXISTS prayers_language_index ON prayers (language);
+CREATE INDEX IF NOT EXISTS prayers_search_index ON prayers USING GIN (searchVector);
+CREATE TABLE IF NOT EXISTS authors (id INTEGER NOT NULL, language TEXT NOT NULL, name TEXT NOT NULL, localizedName TEXT NOT NULL, PRIMARY KEY (id, language));
+CREATE TABLE IF NOT EXISTS tags (id BIGINT PRIMARY KEY, name TEXT NOT NULL, kind TEXT NOT NULL, language TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS prayer_tags (prayerId BIGINT NOT NULL REFERENCES prayers (id) ON DELETE CASCADE, tagId BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE, position INTEGER NOT NULL, PRIMARY KEY (prayerId, tagId));
+CREATE TABLE IF NOT EXISTS categories (language TEXT NOT NULL, name TEXT NOT NULL, kind TEXT NOT NULL, weight INTEGER NOT NULL, position INTEGER NOT NULL, PRIMARY KEY (language, name));`,
+		strings.Join(columns, ",\n\t"))
+}
+
+// postgresUpsertSQL inserts or updates a prayer, with the same rules for
+// createdAt and updatedAt as the SQLite -update mode
+func postgresUpsertSQL() string {
+	var placeholders, assignments []string
+	for i, column := range prayerColumns {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		switch column {
+		case "id", "createdAt":
+		case "updatedAt":
+			assignments = append(assignments, "updatedAt=CASE WHEN prayers.contentHash=excluded.contentHash THEN prayers.updatedAt ELSE excluded.updatedAt END")
+		default:
+			assignments = append(assignments, fmt.Sprintf("%s=excluded.%s", column, column))
+		}
+	}
+	assignments = append(assignments, "removedAt=NULL")
+
+	return fmt.Sprintf(`INSERT INTO prayers (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s`,
+		strings.Join(prayerColumns, ", "), strings.Join(placeholders, ", "), strings.Join(assignments, ", "))
+}
+
+// StorePostgres stores a scraped language in the Postgres database at dsn,
+// for server-side search and APIs. It upserts the prayers of the language,
+// and marks the ones of the language that weren't scraped as removed, all in
+// one transaction. opts date the changes.
+func StorePostgres(ctx context.Context, dsn string, s Scrape, lang bpnet.Language, opts Options) error {
+	opts = opts.withDefaults()
+	db, err := sql.Open(postgresDriver, dsn)
+	if err != nil {
+		return fmt.Errorf("%v (build with -tags postgres for the Postgres driver)", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, createPostgresSQL())
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert, err := tx.PrepareContext(ctx, postgresUpsertSQL())
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+
+	var ids []string
+	for _, prayer := range s.Prayers {
+		values, err := prayerValues(prayer, lang, nil, opts.ScrapeTime)
+		if err != nil {
+			return err
+		}
+		if _, err := upsert.ExecContext(ctx, values...); err != nil {
+			return err
+		}
+		ids = append(ids, fmt.Sprint(prayer.ID))
+	}
+
+	removeSQL := `UPDATE prayers SET removedAt=$1 WHERE language=$2 AND removedAt IS NULL`
+	if len(ids) > 0 {
+		removeSQL += fmt.Sprintf(` AND id NOT IN (%s)`, strings.Join(ids, ", "))
+	}
+	_, err = tx.ExecContext(ctx, removeSQL, opts.ScrapeTime, lang.ISOName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM prayer_tags WHERE prayerId IN (SELECT id FROM prayers WHERE language=$1)`, lang.ISOName)
+	if err != nil {
+		return err
+	}
+	for _, prayer := range s.Prayers {
+		for i, tag := range prayer.Tags {
+			_, err := tx.ExecContext(ctx, `INSERT INTO tags (id, name, kind, language) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO UPDATE SET name=excluded.name, kind=excluded.kind, language=excluded.language`, tag.ID, tag.Name, tag.Kind, lang.ISOName)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO prayer_tags (prayerId, tagId, position) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, prayer.ID, tag.ID, i)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for id, name := range canonicalAuthors {
+		localizedName := LocalizedAuthor(lang.ISOName, id)
+		if localizedName == "" {
+			localizedName = name
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO authors (id, language, name, localizedName) VALUES ($1, $2, $3, $4) ON CONFLICT (id, language) DO UPDATE SET name=excluded.name, localizedName=excluded.localizedName`, id, lang.ISOName, name, localizedName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Categories == nil {
+		OrderCategories(&s, lang, DefaultCategoryWeight)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM categories WHERE language=$1`, lang.ISOName)
+	if err != nil {
+		return err
+	}
+	for i, c := range s.Categories {
+		_, err := tx.ExecContext(ctx, `INSERT INTO categories (language, name, kind, weight, position) VALUES ($1, $2, $3, $4, $5)`, lang.ISOName, c.Name, c.Kind, c.Weight, i+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,64 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MobilePageSize matches the page size of the flash storage on phones,
+// where the databases end up
+const MobilePageSize = 4096
+
+// buildPragmas speed up filling a new database. The page size only takes
+// effect if it's set before any tables are created. The cache size is in
+// KiB when negative.
+var buildPragmas = []string{
+	fmt.Sprintf(`PRAGMA page_size = %d`, MobilePageSize),
+	`PRAGMA journal_mode = WAL`,
+	`PRAGMA synchronous = NORMAL`,
+	`PRAGMA cache_size = -65536`,
+	`PRAGMA temp_store = MEMORY`,
+}
+
+// BeginBuild switches a new database to the fast build settings. The
+// per-connection pragmas only hold if every statement goes through the same
+// connection, so the pool is limited to one.
+func BeginBuild(ctx context.Context, db *sql.DB) error {
+	db.SetMaxOpenConns(1)
+	for _, pragma := range buildPragmas {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FinishBuild checkpoints the write-ahead log and switches the database back
+// to a rollback journal, so it ships as a single, read-optimized file
+func FinishBuild(ctx context.Context, db *sql.DB) error {
+	for _, pragma := range []string{`PRAGMA wal_checkpoint(TRUNCATE)`, `PRAGMA journal_mode = DELETE`, `PRAGMA synchronous = FULL`} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compact readies a finished database for shipping. ANALYZE gives the query
+// planner statistics to work with on the phone, and VACUUM drops the free
+// pages left behind by the build, rewriting the file with MobilePageSize
+// pages if it was created with another size.
+func Compact(ctx context.Context, db *sql.DB) error {
+	statements := []string{
+		fmt.Sprintf(`PRAGMA page_size = %d`, MobilePageSize),
+		`ANALYZE`,
+		`VACUUM`,
+	}
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
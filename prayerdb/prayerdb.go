@@ -0,0 +1,214 @@
+// Package prayerdb stores scraped prayers in the per-language SQLite
+// databases, and merges those into the database the apps ship with. The
+// databases are opened with the "sqlite3" database/sql driver, which the
+// program has to register, e.g. by importing github.com/mattn/go-sqlite3.
+// The merged database has an FTS5 full-text index, which go-sqlite3 only
+// has when built with the sqlite_fts5 tag:
+//
+//	go build -tags sqlite_fts5 ./cmd/bpnet-scraper
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/markup"
+)
+
+// Prayer is a prayer from the API along with everything the scraper derives
+// from it before it's stored
+type Prayer struct {
+	bpnet.Prayer
+	// RawText is the text as the API sent it, so the markup can be redone
+	// without scraping again
+	RawText  string
+	Kind     string
+	Category string
+	Citation string
+	// PrayerText is the prayer marked up as HTML or Markdown
+	PrayerText string
+	PlainText  string
+	// OpeningWords are what the prayer is listed by
+	OpeningWords string
+	Footnotes    []markup.Footnote
+	// HasInstructions is set for obligatory prayers with ritual instructions
+	HasInstructions bool
+	// SortOrder is the position of the prayer in its category, as
+	// OrderPrayers numbers it
+	SortOrder int
+}
+
+// Scrape holds the prayers of a language as they go through the scraper
+type Scrape struct {
+	// Version is the API's version of the prayers
+	Version int
+	Prayers []Prayer
+	// Started is when the scrape of the language started
+	Started time.Time
+	// Categories are the categories of the prayers in their display order,
+	// as OrderCategories lists them
+	Categories []Category
+}
+
+// NewScrape starts a Scrape from the API's response, holding on to the text
+// of each prayer as it was sent
+func NewScrape(pr *bpnet.PrayersResponse) *Scrape {
+	s := &Scrape{Version: pr.Version}
+	for _, p := range pr.Prayers {
+		s.Prayers = append(s.Prayers, Prayer{Prayer: p, RawText: p.Text})
+	}
+	return s
+}
+
+// Populate writes the database of a language, <ISO name>.db, replacing any
+// old one but keeping track of when its prayers were added, changed and
+// removed. The new database is built in <ISO name>.db.tmp and only takes the
+// old one's place once it's complete, so a failed scrape leaves the old one
+// as it was. With update, an existing database is updated in place instead,
+// as updateDatabase describes. opts stamp the database and size the
+// transactions that fill it.
+func Populate(ctx context.Context, s Scrape, lang bpnet.Language, update bool, opts Options) error {
+	opts = opts.withDefaults()
+	dbPath := lang.ISOName + ".db"
+	if update {
+		if _, err := os.Stat(dbPath); err == nil {
+			return updateDatabase(ctx, s, lang, dbPath, opts)
+		}
+	}
+
+	// keep track of when the prayers of the old database were added and
+	// changed, and build the new one beside it
+	previous := previousStamps(ctx, dbPath)
+	removals := previousRemovals(ctx, dbPath)
+	runs := previousRuns(ctx, dbPath)
+	tmpPath := dbPath + ".tmp"
+	os.Remove(tmpPath)
+
+	db, err := sql.Open("sqlite3", tmpPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	built := false
+	defer func() {
+		if !built {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	const createTableSQL = `CREATE TABLE prayers (id INTEGER PRIMARY KEY, category TEXT NOT NULL, prayerText TEXT NOT NULL, openingWords TEXT NOT NULL, citation TEXT NOT NULL, author TEXT NOT NULL, language TEXT NOT NULL, plainText TEXT NOT NULL, footnotes TEXT NOT NULL, rawText TEXT NOT NULL, hasInstructions INTEGER NOT NULL, authorId INTEGER NOT NULL REFERENCES authors (id), deleted INTEGER NOT NULL DEFAULT 0, overridden INTEGER NOT NULL DEFAULT 0, wordCount INTEGER NOT NULL, searchText TEXT NOT NULL, sortKey BLOB NOT NULL, contentHash TEXT NOT NULL, createdAt TEXT NOT NULL, updatedAt TEXT NOT NULL, sortOrder INTEGER NOT NULL, source TEXT NOT NULL)`
+	_, err = db.ExecContext(ctx, createTableSQL)
+	if err != nil {
+		return err
+	}
+
+	inserter := newBatchInserter(db, insertPrayerSQL, opts.BatchSize, lang.ISOName, len(s.Prayers))
+	defer inserter.close()
+	for _, prayer := range s.Prayers {
+		values, err := prayerValues(prayer, lang, previous, opts.ScrapeTime)
+		if err != nil {
+			return err
+		}
+		err = inserter.insert(ctx, values...)
+		if err != nil {
+			return err
+		}
+	}
+	if err = inserter.flush(); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, createAuthorsSQL)
+	if err != nil {
+		return err
+	}
+	err = populateAuthors(ctx, tx, lang.ISOName)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, createTagsSQL)
+	if err != nil {
+		return err
+	}
+	err = populateTags(ctx, tx, s.Prayers)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, createRemovedSQL)
+	if err != nil {
+		return err
+	}
+	err = populateRemoved(ctx, tx, s.Prayers, lang.ISOName, previous, removals, opts.ScrapeTime)
+	if err != nil {
+		return err
+	}
+	err = populateRuns(ctx, tx, runs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, createCategoriesSQL)
+	if err != nil {
+		return err
+	}
+	err = populateCategories(ctx, tx, s, lang)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, createMetaTableSQL)
+	if err != nil {
+		return err
+	}
+	err = WriteMeta(ctx, tx, scrapeMeta(s, lang, opts))
+	if err != nil {
+		return err
+	}
+	err = stampSchema(ctx, tx, LanguageSchemaVersion)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	if err = Compact(ctx, db); err != nil {
+		return err
+	}
+	if err = db.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpPath, dbPath); err != nil {
+		return err
+	}
+	built = true
+	return nil
+}
+
+// prayerValues returns the values of the prayerColumns for a prayer scraped
+// at scrapeTime, with timestamps carried over from the previous database of
+// the language
+func prayerValues(prayer Prayer, lang bpnet.Language, previous map[int]prayerStamps, scrapeTime string) ([]interface{}, error) {
+	footnotes := ""
+	if len(prayer.Footnotes) > 0 {
+		buf, err := json.Marshal(prayer.Footnotes)
+		if err != nil {
+			return nil, err
+		}
+		footnotes = string(buf)
+	}
+	author := LocalizedAuthor(lang.ISOName, prayer.AuthorID)
+	wordCount, searchText, key := searchFields(prayer.PrayerText, prayer.OpeningWords, lang.ISOName)
+	hash := ContentHash(prayer.RawText)
+	createdAt, updatedAt := stamp(previous, prayer.ID, hash, scrapeTime)
+	return []interface{}{prayer.ID, prayer.Category, prayer.PrayerText, prayer.OpeningWords, prayer.Citation, author, lang.ISOName, prayer.PlainText, footnotes, prayer.RawText, prayer.HasInstructions, prayer.AuthorID, wordCount, searchText, key, hash, createdAt, updatedAt, prayer.SortOrder, lang.SourceName()}, nil
+}
@@ -0,0 +1,198 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/markup"
+)
+
+// ErrPrayerNotFound is returned by GetPrayer for an ID the merged database
+// doesn't have
+var ErrPrayerNotFound = errors.New("prayer not found")
+
+// CatalogLanguage is a language of the merged database, with how many
+// prayers it has and the categories they're in
+type CatalogLanguage struct {
+	Language   string
+	Prayers    int
+	Categories []Count
+}
+
+// PrayerListing is a prayer as it's listed, without its text
+type PrayerListing struct {
+	ID           int
+	Language     string
+	Category     string
+	OpeningWords string
+	Author       string
+	WordCount    int
+}
+
+// StoredPrayer is a prayer of the merged database. DuplicateOf is the ID of
+// the prayer it's a copy of, if merged with -duplicates link, and TagIDs are
+// the tags it has, in the order it was tagged.
+type StoredPrayer struct {
+	PrayerListing
+	PrayerText      string
+	PlainText       string
+	Citation        string
+	AuthorID        int
+	HasInstructions bool
+	Footnotes       []markup.Footnote
+	DuplicateOf     int
+	ContentHash     string
+	CreatedAt       string
+	UpdatedAt       string
+	Source          string
+	TagIDs          []int
+}
+
+// listingColumns are the columns scanned into a PrayerListing
+const listingColumns = `id, language, category, openingWords, author, wordCount`
+
+// Catalog lists the languages of a merged database, by language code, with
+// the categories of each in their display order
+func Catalog(ctx context.Context, db *sql.DB) ([]CatalogLanguage, error) {
+	rows, err := db.QueryContext(ctx, `SELECT p.language, p.category, count(*) FROM prayers p LEFT JOIN categories c ON c.language=p.language AND c.name=p.category
+		GROUP BY p.language, p.category ORDER BY p.language, c.position IS NULL, c.position, p.category`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var langs []CatalogLanguage
+	for rows.Next() {
+		var lang string
+		var category Count
+		if err := rows.Scan(&lang, &category.Name, &category.Count); err != nil {
+			return nil, err
+		}
+		if len(langs) == 0 || langs[len(langs)-1].Language != lang {
+			langs = append(langs, CatalogLanguage{Language: lang})
+		}
+		current := &langs[len(langs)-1]
+		current.Prayers += category.Count
+		current.Categories = append(current.Categories, category)
+	}
+	return langs, rows.Err()
+}
+
+// ListPrayers lists the prayers of a merged database the way the app does:
+// by language, then alphabetically by opening words, or by their sortOrder
+// within a category. An empty language or category doesn't filter by it.
+func ListPrayers(ctx context.Context, db *sql.DB, language, category string) ([]PrayerListing, error) {
+	var where []string
+	var args []interface{}
+	if language != "" {
+		where, args = append(where, `language=?`), append(args, language)
+	}
+	if category != "" {
+		where, args = append(where, `category=?`), append(args, category)
+	}
+	query := `SELECT ` + listingColumns + ` FROM prayers`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	order := ` ORDER BY language, sortKey, id`
+	if category != "" {
+		order = ` ORDER BY language, sortOrder, id`
+	}
+	return queryListings(ctx, db, query+order, args...)
+}
+
+// GetPrayer reads a prayer of a merged database by its ID
+func GetPrayer(ctx context.Context, db *sql.DB, id int) (StoredPrayer, error) {
+	var p StoredPrayer
+	var footnotes string
+	err := db.QueryRowContext(ctx, `SELECT `+listingColumns+`, prayerText, plainText, citation, authorId, hasInstructions, footnotes, duplicateOf, contentHash, createdAt, updatedAt, source FROM prayers WHERE id=?`, id).Scan(
+		&p.ID, &p.Language, &p.Category, &p.OpeningWords, &p.Author, &p.WordCount,
+		&p.PrayerText, &p.PlainText, &p.Citation, &p.AuthorID, &p.HasInstructions, &footnotes, &p.DuplicateOf, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.Source)
+	if err == sql.ErrNoRows {
+		return p, ErrPrayerNotFound
+	}
+	if err != nil {
+		return p, err
+	}
+	if footnotes != "" {
+		if err := json.Unmarshal([]byte(footnotes), &p.Footnotes); err != nil {
+			return p, fmt.Errorf("footnotes of prayer %d: %v", id, err)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT tagId FROM prayer_tags WHERE prayerId=? ORDER BY position`, id)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tagID int
+		if err := rows.Scan(&tagID); err != nil {
+			return p, err
+		}
+		p.TagIDs = append(p.TagIDs, tagID)
+	}
+	return p, rows.Err()
+}
+
+// SearchPrayers finds the prayers of a merged database whose text has every
+// word of the query, ignoring case and accents the way searchText does. An
+// empty language searches all of them. At most limit prayers are returned,
+// or all of them when limit is 0. The words are looked up in the FTS5 index,
+// as the starts of words, and the prayers ranked by how well they match;
+// only when the scraper is built without FTS5, or the database was merged
+// without the index, is searchText scanned for them instead, anywhere in
+// it, and the prayers listed by language and sort key.
+func SearchPrayers(ctx context.Context, db *sql.DB, query, language string, limit int) ([]PrayerListing, error) {
+	words := strings.Fields(foldDiacritics(query))
+	if len(words) == 0 {
+		return nil, nil
+	}
+	if FullTextSearch {
+		indexed, err := hasFullTextIndex(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		if indexed {
+			return searchFullText(ctx, db, words, language, limit)
+		}
+	}
+
+	var where []string
+	var args []interface{}
+	for _, word := range words {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(word)
+		where, args = append(where, `searchText LIKE ? ESCAPE '\'`), append(args, "%"+escaped+"%")
+	}
+	if language != "" {
+		where, args = append(where, `language=?`), append(args, language)
+	}
+	q := `SELECT ` + listingColumns + ` FROM prayers WHERE ` + strings.Join(where, ` AND `) + ` ORDER BY language, sortKey, id`
+	if limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	return queryListings(ctx, db, q, args...)
+}
+
+func queryListings(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]PrayerListing, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var listings []PrayerListing
+	for rows.Next() {
+		var p PrayerListing
+		if err := rows.Scan(&p.ID, &p.Language, &p.Category, &p.OpeningWords, &p.Author, &p.WordCount); err != nil {
+			return nil, err
+		}
+		listings = append(listings, p)
+	}
+	return listings, rows.Err()
+}
@@ -0,0 +1,98 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"os"
+)
+
+// createRemovedSQL creates the table of prayers that have disappeared from
+// the API, so the app can delete its copies of them when it syncs
+const createRemovedSQL = `CREATE TABLE removed_prayers (id INTEGER PRIMARY KEY, language TEXT NOT NULL, removedAt TEXT NOT NULL)`
+
+// removal is a row of removed_prayers
+type removal struct {
+	id        int
+	language  string
+	removedAt string
+}
+
+// previousRemovals reads the removed prayers of the database a scrape is
+// about to replace, so they carry over
+func previousRemovals(ctx context.Context, dbPath string) []removal {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil
+	}
+	defer db.Close()
+
+	removals, err := readRemovals(ctx, db)
+	if err != nil {
+		return nil
+	}
+	return removals
+}
+
+func readRemovals(ctx context.Context, db *sql.DB) ([]removal, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, language, removedAt FROM removed_prayers`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var removals []removal
+	for rows.Next() {
+		var r removal
+		if err := rows.Scan(&r.id, &r.language, &r.removedAt); err != nil {
+			return nil, err
+		}
+		removals = append(removals, r)
+	}
+	return removals, rows.Err()
+}
+
+// populateRemoved records the prayers the previous database had that the
+// scrape no longer does as removed at removedAt, along with the earlier
+// removals. A prayer that has come back is no longer removed.
+func populateRemoved(ctx context.Context, tx *sql.Tx, prayers []Prayer, lang string, previous map[int]prayerStamps, removals []removal, removedAt string) error {
+	scraped := make(map[int]bool)
+	for _, prayer := range prayers {
+		scraped[prayer.ID] = true
+	}
+
+	for _, r := range removals {
+		if scraped[r.id] {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO removed_prayers (id, language, removedAt) VALUES (?, ?, ?)`, r.id, r.language, r.removedAt)
+		if err != nil {
+			return err
+		}
+	}
+	for id := range previous {
+		if scraped[id] {
+			continue
+		}
+		if err := recordRemoval(ctx, tx, id, lang, removedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordRemoval adds a prayer to removed_prayers, unless it's already there
+func recordRemoval(ctx context.Context, tx *sql.Tx, id int, lang, removedAt string) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO removed_prayers (id, language, removedAt) VALUES (?, ?, ?)`, id, lang, removedAt)
+	return err
+}
+
+// mergeRemoved copies the removed prayers of a per-language database into
+// the merged one
+func mergeRemoved(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO removed_prayers (id, language, removedAt) SELECT id, language, removedAt FROM lang.removed_prayers`)
+	return err
+}
@@ -0,0 +1,101 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"time"
+)
+
+// createRunsSQL creates the audit trail of the runs of the scraper that made
+// a database: the scrapes of a per-language database, and those of all its
+// languages along with the merges in a merged one. A run is keyed by its
+// start, command and languages, so merging the same scrape again records it
+// once.
+const createRunsSQL = `CREATE TABLE runs (startedAt TEXT NOT NULL, command TEXT NOT NULL, languages TEXT NOT NULL, duration REAL NOT NULL, scraperVersion TEXT NOT NULL, warnings INTEGER NOT NULL, PRIMARY KEY (startedAt, command, languages))`
+
+// Run is a run of the scraper for the runs table
+type Run struct {
+	// Command is scrape or merge
+	Command   string
+	StartedAt time.Time
+	Duration  time.Duration
+	Languages []string
+	// Warnings is how many warnings the run logged about its languages
+	Warnings int
+	// ScraperVersion identifies the build of the scraper, "unknown" when
+	// empty
+	ScraperVersion string
+}
+
+// storedRun is a row of runs
+type storedRun struct {
+	command, startedAt string
+	duration           float64
+	languages, version string
+	warnings           int
+}
+
+// RecordRun adds a run of this scraper to the runs table of a database
+func RecordRun(ctx context.Context, db Execer, r Run) error {
+	version := r.ScraperVersion
+	if version == "" {
+		version = "unknown"
+	}
+	_, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO runs (command, startedAt, duration, languages, scraperVersion, warnings) VALUES (?, ?, ?, ?, ?, ?)`,
+		r.Command, r.StartedAt.UTC().Format(time.RFC3339Nano), r.Duration.Seconds(), strings.Join(r.Languages, ","), version, r.Warnings)
+	return err
+}
+
+// previousRuns reads the runs of the database a scrape is about to replace,
+// so the trail carries over. Databases from before there were runs have
+// none.
+func previousRuns(ctx context.Context, dbPath string) []storedRun {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT command, startedAt, duration, languages, scraperVersion, warnings FROM runs ORDER BY startedAt`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var runs []storedRun
+	for rows.Next() {
+		var r storedRun
+		if err := rows.Scan(&r.command, &r.startedAt, &r.duration, &r.languages, &r.version, &r.warnings); err != nil {
+			return nil
+		}
+		runs = append(runs, r)
+	}
+	return runs
+}
+
+// populateRuns creates the runs table with the runs of the previous
+// database
+func populateRuns(ctx context.Context, tx *sql.Tx, runs []storedRun) error {
+	if _, err := tx.ExecContext(ctx, createRunsSQL); err != nil {
+		return err
+	}
+	for _, r := range runs {
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO runs (command, startedAt, duration, languages, scraperVersion, warnings) VALUES (?, ?, ?, ?, ?, ?)`,
+			r.command, r.startedAt, r.duration, r.languages, r.version, r.warnings)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeRuns copies the runs of a per-language database into the merged one
+func mergeRuns(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO runs (command, startedAt, duration, languages, scraperVersion, warnings) SELECT command, startedAt, duration, languages, scraperVersion, warnings FROM lang.runs`)
+	return err
+}
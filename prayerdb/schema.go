@@ -0,0 +1,368 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/markup"
+)
+
+// migration upgrades a per-language database from the previous schema
+// version to version. Up to version 5 each one added a single column, so a
+// database from before versions were stamped can be dated by the columns it
+// has. Later migrations have no column, since the versions are stamped.
+type migration struct {
+	version int
+	column  string
+	migrate func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations lists every schema change to the per-language databases, in
+// order. Version 1 is the original table, without any of these columns.
+var migrations = []migration{
+	{2, "plainText", migratePlainText},
+	{3, "footnotes", migrationSQL(`ALTER TABLE prayers ADD COLUMN footnotes TEXT NOT NULL DEFAULT ''`)},
+	{4, "rawText", migrationSQL(`ALTER TABLE prayers ADD COLUMN rawText TEXT NOT NULL DEFAULT ''`)},
+	{5, "hasInstructions", migrationSQL(`ALTER TABLE prayers ADD COLUMN hasInstructions INTEGER NOT NULL DEFAULT 0`)},
+	{6, "", migrationSQL(createMetaTableSQL)},
+	{7, "", migrationSQL(createTagsSQL)},
+	{8, "", migrateAuthors},
+	{9, "", migrateTombstones},
+	{10, "", migrateSearchFields},
+	{11, "", migrateContentHash},
+	{12, "", migrateTimestamps},
+	{13, "", migrateRemoved},
+	{14, "", migrationSQL(createRunsSQL)},
+	{15, "", migrateCategories},
+	{16, "", migrateSortOrder},
+	{17, "", migrationSQL(`ALTER TABLE prayers ADD COLUMN source TEXT NOT NULL DEFAULT '` + bpnet.DefaultSource + `'`)},
+}
+
+// LanguageSchemaVersion is the version of the databases Populate creates
+var LanguageSchemaVersion = migrations[len(migrations)-1].version
+
+// MergedSchemaVersion is the version of the databases CreateMerged creates
+const MergedSchemaVersion = 12
+
+const createSchemaTableSQL = `CREATE TABLE schema (version INTEGER PRIMARY KEY, applied TEXT NOT NULL)`
+
+// migrationSQL returns a migration that runs a single statement
+func migrationSQL(query string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	}
+}
+
+// migratePlainText adds the plainText column, filling it in from the HTML of
+// each prayer, since the text it was rendered from isn't in the database
+func migratePlainText(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE prayers ADD COLUMN plainText TEXT NOT NULL DEFAULT ''`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, prayerText FROM prayers`)
+	if err != nil {
+		return err
+	}
+	texts := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var prayerText string
+		if err := rows.Scan(&id, &prayerText); err != nil {
+			rows.Close()
+			return err
+		}
+		texts[id] = markup.HTMLText(prayerText)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, text := range texts {
+		_, err := tx.ExecContext(ctx, `UPDATE prayers SET plainText=? WHERE id=?`, text, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stampSchema records version in both the user_version pragma and the schema
+// table, creating the table if it isn't there yet
+func stampSchema(ctx context.Context, db Execer, version int) error {
+	var exists int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM sqlite_master WHERE type='table' AND name='schema'`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		if _, err := db.ExecContext(ctx, createSchemaTableSQL); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.ExecContext(ctx, `INSERT OR REPLACE INTO schema (version, applied) VALUES (?, ?)`, version, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, version))
+	return err
+}
+
+// Execer is what stampSchema and WriteMeta need from either a *sql.DB or a *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Querier is what LanguageCounts needs from either a *sql.DB or a *sql.Tx
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// SchemaVersion returns the schema version of a per-language database. An
+// unstamped database is dated by the newest migration column it has.
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version)
+	if err != nil || version != 0 {
+		return version, err
+	}
+
+	rows, err := db.QueryContext(ctx, `PRAGMA table_info(prayers)`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return 0, err
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("no prayers table")
+	}
+
+	version = 1
+	for _, m := range migrations {
+		if m.column != "" && columns[m.column] {
+			version = m.version
+		}
+	}
+	return version, nil
+}
+
+// hasColumn reports whether a table of the database has the column, to
+// tell per-language databases from merged ones
+func hasColumn(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM pragma_table_info(?) WHERE name=?`, table, column).Scan(&count)
+	return count > 0, err
+}
+
+// Migrate upgrades a per-language database to the current schema
+func Migrate(ctx context.Context, dbPath string) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	version, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if version > LanguageSchemaVersion {
+		return fmt.Errorf("schema version %d is newer than this scraper's %d", version, LanguageSchemaVersion)
+	}
+	if version == LanguageSchemaVersion {
+		logger().Info("already at the current schema version", "phase", "migrate", "db", dbPath, "version", version)
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err := m.migrate(ctx, tx); err != nil {
+			return fmt.Errorf("migrating to version %d: %v", m.version, err)
+		}
+		if err := stampSchema(ctx, tx, m.version); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logger().Info("migrated database", "phase", "migrate", "db", dbPath, "from", version, "to", LanguageSchemaVersion)
+	return nil
+}
+
+// CheckSchema makes sure a per-language database matches the current schema
+// before it gets merged
+func CheckSchema(ctx context.Context, dbPath string, db *sql.DB) error {
+	version, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return fmt.Errorf("unable to read the schema version of %s: %v", dbPath, err)
+	}
+	if version < LanguageSchemaVersion {
+		return fmt.Errorf("%s has schema version %d, but %d is needed. Run -migrate on it first.", dbPath, version, LanguageSchemaVersion)
+	}
+	if version > LanguageSchemaVersion {
+		return fmt.Errorf("%s has schema version %d, which is newer than this scraper's %d", dbPath, version, LanguageSchemaVersion)
+	}
+	return nil
+}
+
+// migrateTombstones adds the columns updateDatabase uses to tombstone
+// removed prayers and to keep local overrides
+func migrateTombstones(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE prayers ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `ALTER TABLE prayers ADD COLUMN overridden INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
+
+// migrateSearchFields adds the search and sort columns that Merge used to
+// derive, computing them from the markup of each prayer
+func migrateSearchFields(ctx context.Context, tx *sql.Tx) error {
+	for _, alter := range []string{
+		`ALTER TABLE prayers ADD COLUMN wordCount INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE prayers ADD COLUMN searchText TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE prayers ADD COLUMN sortKey BLOB NOT NULL DEFAULT x''`,
+	} {
+		if _, err := tx.ExecContext(ctx, alter); err != nil {
+			return err
+		}
+	}
+
+	type fields struct {
+		wordCount  int
+		searchText string
+		key        []byte
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT id, prayerText, openingWords, language FROM prayers`)
+	if err != nil {
+		return err
+	}
+	derived := make(map[int]fields)
+	for rows.Next() {
+		var id int
+		var prayerText, openingWords, lang string
+		if err := rows.Scan(&id, &prayerText, &openingWords, &lang); err != nil {
+			rows.Close()
+			return err
+		}
+		var f fields
+		f.wordCount, f.searchText, f.key = searchFields(prayerText, openingWords, lang)
+		derived[id] = f
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, f := range derived {
+		_, err := tx.ExecContext(ctx, `UPDATE prayers SET wordCount=?, searchText=?, sortKey=? WHERE id=?`, f.wordCount, f.searchText, f.key, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateContentHash adds the contentHash column, hashing the raw text of
+// every prayer. Prayers stored before the rawText column have only their
+// plain text to hash.
+func migrateContentHash(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE prayers ADD COLUMN contentHash TEXT NOT NULL DEFAULT ''`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, rawText, plainText FROM prayers`)
+	if err != nil {
+		return err
+	}
+	hashes := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var rawText, plainText string
+		if err := rows.Scan(&id, &rawText, &plainText); err != nil {
+			rows.Close()
+			return err
+		}
+		if rawText == "" {
+			rawText = plainText
+		}
+		hashes[id] = ContentHash(rawText)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, `UPDATE prayers SET contentHash=? WHERE id=?`, hash, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTimestamps adds the createdAt and updatedAt columns. Their history
+// is unknown, so all prayers date from the scrape that made the database,
+// or from the migration if that isn't recorded.
+func migrateTimestamps(ctx context.Context, tx *sql.Tx) error {
+	since := time.Now().UTC().Format(time.RFC3339)
+	var scrapedAt string
+	err := tx.QueryRowContext(ctx, `SELECT value FROM meta WHERE key='scrapedAt'`).Scan(&scrapedAt)
+	if err == nil {
+		since = scrapedAt
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	for _, column := range []string{"createdAt", "updatedAt"} {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE prayers ADD COLUMN %s TEXT NOT NULL DEFAULT ''`, column))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE prayers SET createdAt=?, updatedAt=?`, since, since)
+	return err
+}
+
+// migrateRemoved adds the removed_prayers table, recording the prayers that
+// updateDatabase had already tombstoned
+func migrateRemoved(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, createRemovedSQL)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO removed_prayers (id, language, removedAt) SELECT id, language, updatedAt FROM prayers WHERE deleted=1`)
+	return err
+}
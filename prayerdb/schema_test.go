@@ -0,0 +1,235 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// createV1Table creates the prayers table as the first scraper did
+const createV1Table = `CREATE TABLE prayers (id INTEGER PRIMARY KEY, category TEXT NOT NULL, prayerText TEXT NOT NULL, openingWords TEXT NOT NULL, citation TEXT NOT NULL, author TEXT NOT NULL, language TEXT NOT NULL)`
+
+// createDB makes a database at path by running statements on it
+func createDB(t *testing.T, path string, statements ...string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+}
+
+// openDB opens the database at path for the rest of the test
+func openDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSchemaVersion(t *testing.T) {
+	cases := []struct {
+		name       string
+		statements []string
+		want       int
+	}{
+		{"original table", []string{createV1Table}, 1},
+		{"plain text", []string{createV1Table, `ALTER TABLE prayers ADD COLUMN plainText TEXT NOT NULL DEFAULT ''`}, 2},
+		{"raw text", []string{
+			createV1Table,
+			`ALTER TABLE prayers ADD COLUMN plainText TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE prayers ADD COLUMN footnotes TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE prayers ADD COLUMN rawText TEXT NOT NULL DEFAULT ''`,
+		}, 4},
+		{"instructions without the columns before", []string{createV1Table, `ALTER TABLE prayers ADD COLUMN hasInstructions INTEGER NOT NULL DEFAULT 0`}, 5},
+		{"stamped", []string{createV1Table, `PRAGMA user_version = 9`}, 9},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "en.db")
+			createDB(t, path, c.statements...)
+			got, err := SchemaVersion(context.Background(), openDB(t, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("the schema version is %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSchemaVersionWithoutPrayers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "en.db")
+	createDB(t, path, `CREATE TABLE other (id INTEGER)`)
+	if _, err := SchemaVersion(context.Background(), openDB(t, path)); err == nil {
+		t.Error("a database without a prayers table has a schema version")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "en.db")
+	createDB(t, path,
+		createV1Table,
+		`INSERT INTO prayers VALUES (1, 'General', '<p>O God, my <em>God!</em></p>', 'O God, my God!', '', 'Bahá''u''lláh', 'en')`,
+		`INSERT INTO prayers VALUES (2, 'General', '<p>Blessed is the spot</p>', 'Blessed is the spot', '', 'Bahá''u''lláh', 'en')`,
+		`INSERT INTO prayers VALUES (3, 'Healing', '<p>Thy name is my healing</p>', 'Thy name is my healing', '', 'Bahá''u''lláh', 'en')`,
+	)
+	if err := Migrate(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+
+	db := openDB(t, path)
+	if err := CheckSchema(ctx, path, db); err != nil {
+		t.Fatal(err)
+	}
+	var userVersion, stamps int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&userVersion); err != nil {
+		t.Fatal(err)
+	}
+	if userVersion != LanguageSchemaVersion {
+		t.Errorf("user_version is %d, want %d", userVersion, LanguageSchemaVersion)
+	}
+	if err := db.QueryRow(`SELECT count(*) FROM schema`).Scan(&stamps); err != nil {
+		t.Fatal(err)
+	}
+	if stamps != LanguageSchemaVersion-1 {
+		t.Errorf("the schema table has %d versions, want %d", stamps, LanguageSchemaVersion-1)
+	}
+
+	var plainText, contentHash, searchText, source, createdAt string
+	var wordCount, authorID, sortOrder int
+	err := db.QueryRow(`SELECT plainText, contentHash, wordCount, searchText, authorId, source, createdAt, sortOrder FROM prayers WHERE id=1`).
+		Scan(&plainText, &contentHash, &wordCount, &searchText, &authorID, &source, &createdAt, &sortOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plainText != "O God, my God!" {
+		t.Errorf("plainText is %q, want %q", plainText, "O God, my God!")
+	}
+	if want := ContentHash("O God, my God!"); contentHash != want {
+		t.Errorf("contentHash is %s, want the hash of the plain text, %s", contentHash, want)
+	}
+	if wordCount != 4 {
+		t.Errorf("wordCount is %d, want 4", wordCount)
+	}
+	if searchText != "o god, my god!" {
+		t.Errorf("searchText is %q, want %q", searchText, "o god, my god!")
+	}
+	if authorID != 2 {
+		t.Errorf("authorId is %d, want 2", authorID)
+	}
+	if source != bpnet.DefaultSource {
+		t.Errorf("source is %q, want %q", source, bpnet.DefaultSource)
+	}
+	if createdAt == "" {
+		t.Error("createdAt is empty")
+	}
+	// the prayers of a category are numbered by their opening words
+	if sortOrder != 2 {
+		t.Errorf("sortOrder is %d, want 2", sortOrder)
+	}
+
+	var categories int
+	if err := db.QueryRow(`SELECT count(*) FROM categories`).Scan(&categories); err != nil {
+		t.Fatal(err)
+	}
+	if categories != 2 {
+		t.Errorf("the categories table has %d categories, want 2", categories)
+	}
+}
+
+func TestMigrateHashesRawText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "en.db")
+	createDB(t, path,
+		createV1Table,
+		`ALTER TABLE prayers ADD COLUMN plainText TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE prayers ADD COLUMN footnotes TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE prayers ADD COLUMN rawText TEXT NOT NULL DEFAULT ''`,
+		`INSERT INTO prayers VALUES (1, 'General', '<p>O God!</p>', 'O God!', '', '', 'en', 'O God!', '', '*O God!*')`,
+	)
+	if err := Migrate(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	var contentHash string
+	if err := openDB(t, path).QueryRow(`SELECT contentHash FROM prayers`).Scan(&contentHash); err != nil {
+		t.Fatal(err)
+	}
+	if want := ContentHash("*O God!*"); contentHash != want {
+		t.Errorf("contentHash is %s, want the hash of the raw text, %s", contentHash, want)
+	}
+}
+
+func TestMigrateCurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "en.db")
+	createDB(t, path, createV1Table)
+	if err := Migrate(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(ctx, path); err != nil {
+		t.Errorf("migrating a current database failed: %v", err)
+	}
+	version, err := SchemaVersion(ctx, openDB(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LanguageSchemaVersion {
+		t.Errorf("the schema version is %d, want %d", version, LanguageSchemaVersion)
+	}
+}
+
+func TestMigrateNewer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "en.db")
+	createDB(t, path, createV1Table, `PRAGMA user_version = 1000`)
+	if err := Migrate(context.Background(), path); err == nil {
+		t.Error("migrated a database newer than the scraper")
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	cases := []struct {
+		name    string
+		version int
+		// err is part of the error, or "" when the schema is current
+		err string
+	}{
+		{"current", LanguageSchemaVersion, ""},
+		{"older", LanguageSchemaVersion - 1, "Run -migrate on it first"},
+		{"newer", LanguageSchemaVersion + 1, "newer than this scraper's"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "en.db")
+			createDB(t, path, createV1Table)
+			db := openDB(t, path)
+			if err := stampSchema(context.Background(), db, c.version); err != nil {
+				t.Fatal(err)
+			}
+			err := CheckSchema(context.Background(), path, db)
+			if c.err == "" {
+				if err != nil {
+					t.Errorf("CheckSchema failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("CheckSchema = %v, want an error with %q", err, c.err)
+			}
+		})
+	}
+}
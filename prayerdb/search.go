@@ -0,0 +1,96 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"arashpayan.com/bpnet-scraper/markup"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// searchFolds spells out the letters that don't decompose into a base letter
+// plus accents, and drops the apostrophes used in transliterated names. They
+// are applied rune by rune, in the same pass that strips the accents.
+var searchFolds = map[rune]string{
+	'\'': "", '’': "", '‘': "", '`': "", 'ʼ': "",
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ð': "d", 'þ': "th", 'ł': "l", 'đ': "d",
+}
+
+// foldDiacritics lowercases s and strips the accents from Latin letters, so
+// users can find Bahá'u'lláh by typing 'bahaullah'. Marks on letters of other
+// scripts (e.g. Cyrillic й) are significant, so those are left alone.
+func foldDiacritics(s string) string {
+	decomposed := norm.NFD.String(strings.ToLower(s))
+	folded := strings.Builder{}
+	folded.Grow(len(decomposed))
+	latinBase := false
+	for _, r := range decomposed {
+		if unicode.Is(unicode.Mn, r) {
+			if latinBase {
+				continue
+			}
+		} else {
+			latinBase = unicode.Is(unicode.Latin, r)
+		}
+		if f, ok := searchFolds[r]; ok {
+			folded.WriteString(f)
+			continue
+		}
+		folded.WriteRune(r)
+	}
+	return norm.NFC.String(folded.String())
+}
+
+// sortKey returns a binary key for s that sorts according to the collation
+// rules of the language, so the app can list prayers alphabetically with a
+// plain ORDER BY instead of implementing collation on the device. A
+// collator isn't safe for concurrent use, and building one costs less than
+// the key, so every call gets its own.
+func sortKey(s string, isoName string) []byte {
+	c := collate.New(language.Make(isoName))
+	buf := collate.Buffer{}
+	key := c.KeyFromString(&buf, s)
+	return append([]byte(nil), key...)
+}
+
+// searchFields derives the columns the app searches and sorts prayers by
+// from a prayer's markup and listing words
+func searchFields(prayerText, openingWords, isoName string) (wordCount int, searchText string, key []byte) {
+	text := markup.HTMLText(prayerText)
+	return len(strings.Fields(text)), foldDiacritics(text), sortKey(openingWords, isoName)
+}
+
+// WordCount counts the words of a prayer's markup, as its wordCount column
+// does
+func WordCount(prayerText string) int {
+	return len(strings.Fields(markup.HTMLText(prayerText)))
+}
+
+// WordCounts reads the word counts of the prayers in a per-language
+// database, keyed by their IDs
+func WordCounts(ctx context.Context, dbPath string) (map[int]int, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT id, wordCount FROM prayers WHERE deleted=0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[int]int)
+	for rows.Next() {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
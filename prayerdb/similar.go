@@ -0,0 +1,164 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SimilarPrayer is a prayer of a cluster of near duplicates
+type SimilarPrayer struct {
+	ID           int
+	Category     string
+	OpeningWords string
+	// Similarity is how alike the prayer is to the first of its cluster,
+	// from 0 to 1
+	Similarity float64
+}
+
+// SimilarCluster is a group of prayers of a language whose texts are nearly
+// the same, like a prayer entered twice with different whitespace
+type SimilarCluster struct {
+	Language string
+	Prayers  []SimilarPrayer
+}
+
+// similarText is a prayer being clustered
+type similarText struct {
+	id                     int
+	category, openingWords string
+	shingles               map[string]bool
+}
+
+// NearDuplicates clusters the prayers of each language in a per-language or
+// merged database by how alike their texts are, once markup, case, accents,
+// punctuation and spacing are ignored. Prayers at least threshold alike,
+// from 0 to 1, end up in the same cluster. Only clusters of more than one
+// prayer are returned, sorted by language and then by their first prayer.
+func NearDuplicates(ctx context.Context, db *sql.DB, threshold float64) ([]SimilarCluster, error) {
+	hasDeleted, err := hasColumn(ctx, db, "prayers", "deleted")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT id, language, category, openingWords, searchText FROM prayers`
+	if hasDeleted {
+		query += ` WHERE deleted=0`
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY language, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	texts := make(map[string][]similarText)
+	var langs []string
+	for rows.Next() {
+		var t similarText
+		var lang, searchText string
+		if err := rows.Scan(&t.id, &lang, &t.category, &t.openingWords, &searchText); err != nil {
+			return nil, err
+		}
+		t.shingles = shingles(searchText)
+		if texts[lang] == nil {
+			langs = append(langs, lang)
+		}
+		texts[lang] = append(texts[lang], t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var clusters []SimilarCluster
+	for _, lang := range langs {
+		clusters = append(clusters, clusterTexts(lang, texts[lang], threshold)...)
+	}
+	return clusters, nil
+}
+
+// clusterTexts groups the texts of a language that are at least threshold
+// alike, directly or through another text of the group
+func clusterTexts(lang string, texts []similarText, threshold float64) []SimilarCluster {
+	parent := make([]int, len(texts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range texts {
+		for j := i + 1; j < len(texts); j++ {
+			if jaccard(texts[i].shingles, texts[j].shingles) >= threshold {
+				if ri, rj := find(i), find(j); ri != rj {
+					parent[rj] = ri
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for i := range texts {
+		root := find(i)
+		if members[root] == nil {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	sort.Ints(roots)
+
+	var clusters []SimilarCluster
+	for _, root := range roots {
+		if len(members[root]) < 2 {
+			continue
+		}
+		cluster := SimilarCluster{Language: lang}
+		first := texts[members[root][0]]
+		for _, i := range members[root] {
+			t := texts[i]
+			cluster.Prayers = append(cluster.Prayers, SimilarPrayer{
+				ID:           t.id,
+				Category:     t.category,
+				OpeningWords: t.openingWords,
+				Similarity:   jaccard(first.shingles, t.shingles),
+			})
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// shingles splits the search text of a prayer into its runs of three words,
+// ignoring punctuation. Texts of fewer words are a single shingle.
+func shingles(searchText string) map[string]bool {
+	words := strings.FieldsFunc(searchText, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	set := make(map[string]bool)
+	if len(words) < 3 {
+		set[strings.Join(words, " ")] = true
+		return set
+	}
+	for i := 0; i+3 <= len(words); i++ {
+		set[strings.Join(words[i:i+3], " ")] = true
+	}
+	return set
+}
+
+// jaccard is how much two sets of shingles overlap, from 0 to 1
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
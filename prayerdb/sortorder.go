@@ -0,0 +1,92 @@
+package prayerdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"sort"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// Orders of the prayers within a category
+const (
+	// OrderAPI keeps the prayers in the order the API lists them
+	OrderAPI = "api"
+	// OrderAlphabetical sorts the prayers by their opening words, collated
+	// for the language
+	OrderAlphabetical = "alphabetical"
+)
+
+// OrderPrayers numbers the prayers of each category of a scrape from 1, in
+// their sortOrder column, so the app can list them without depending on the
+// order of the rows. order returns OrderAPI or OrderAlphabetical for a
+// category by its name and kind. The opening words have to be marked up
+// first. Ties go by the order of the API.
+func OrderPrayers(s *Scrape, lang bpnet.Language, order func(category, kind string) string) {
+	byCategory := make(map[string][]int)
+	var categories []string
+	for i, prayer := range s.Prayers {
+		if _, ok := byCategory[prayer.Category]; !ok {
+			categories = append(categories, prayer.Category)
+		}
+		byCategory[prayer.Category] = append(byCategory[prayer.Category], i)
+	}
+
+	for _, category := range categories {
+		indices := byCategory[category]
+		first := s.Prayers[indices[0]]
+		if order(category, first.Kind) == OrderAlphabetical {
+			keys := make(map[int][]byte)
+			for _, i := range indices {
+				keys[i] = sortKey(s.Prayers[i].OpeningWords, lang.ISOName)
+			}
+			sort.SliceStable(indices, func(a, b int) bool {
+				return bytes.Compare(keys[indices[a]], keys[indices[b]]) < 0
+			})
+		}
+		for position, i := range indices {
+			s.Prayers[i].SortOrder = position + 1
+		}
+	}
+}
+
+// migrateSortOrder adds the sortOrder column, numbering the prayers of each
+// category alphabetically, since the order of the API isn't stored
+func migrateSortOrder(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE prayers ADD COLUMN sortOrder INTEGER NOT NULL DEFAULT 0`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, category FROM prayers ORDER BY category, sortKey, id`)
+	if err != nil {
+		return err
+	}
+	positions := make(map[int]int)
+	category, position := "", 0
+	for rows.Next() {
+		var id int
+		var c string
+		if err := rows.Scan(&id, &c); err != nil {
+			rows.Close()
+			return err
+		}
+		if c != category || position == 0 {
+			category, position = c, 0
+		}
+		position++
+		positions[id] = position
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, position := range positions {
+		if _, err := tx.ExecContext(ctx, `UPDATE prayers SET sortOrder=? WHERE id=?`, position, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
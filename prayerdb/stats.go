@@ -0,0 +1,150 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"unicode"
+)
+
+// LengthBucket is a range of word counts in a histogram of prayer lengths.
+// Max is 0 for the last, open ended, bucket.
+type LengthBucket struct {
+	Min, Max int
+	Prayers  int
+}
+
+// lengthBuckets are the lower bounds of the LengthBuckets of the histogram
+var lengthBuckets = []int{0, 50, 100, 200, 500, 1000}
+
+// Count is a named count, for the distributions of LanguageStats
+type Count struct {
+	Name  string
+	Count int
+}
+
+// LanguageStats describe the prayers of a language for reviewers
+type LanguageStats struct {
+	Language string
+	Prayers  int
+	Words    int
+	// Lengths is a histogram of the word counts of the prayers
+	Lengths []LengthBucket
+	// Authors, Categories and Scripts count the prayers by author, the
+	// prayers by category, and the letters by the Unicode script they're
+	// in, most first
+	Authors    []Count
+	Categories []Count
+	Scripts    []Count
+}
+
+// CorpusStats computes the LanguageStats of every language of a
+// per-language or merged database, sorted by language
+func CorpusStats(ctx context.Context, db *sql.DB) ([]LanguageStats, error) {
+	hasDeleted, err := hasColumn(ctx, db, "prayers", "deleted")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT language, category, author, wordCount, plainText FROM prayers`
+	if hasDeleted {
+		query += ` WHERE deleted=0`
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY language`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type tally struct {
+		stats                        LanguageStats
+		authors, categories, scripts map[string]int
+	}
+	var tallies []*tally
+	var current *tally
+	scripts := make(map[rune]string)
+	for rows.Next() {
+		var lang, category, author, plainText string
+		var wordCount int
+		if err := rows.Scan(&lang, &category, &author, &wordCount, &plainText); err != nil {
+			return nil, err
+		}
+		if current == nil || current.stats.Language != lang {
+			current = &tally{
+				stats:      LanguageStats{Language: lang},
+				authors:    make(map[string]int),
+				categories: make(map[string]int),
+				scripts:    make(map[string]int),
+			}
+			for i, min := range lengthBuckets {
+				bucket := LengthBucket{Min: min}
+				if i+1 < len(lengthBuckets) {
+					bucket.Max = lengthBuckets[i+1] - 1
+				}
+				current.stats.Lengths = append(current.stats.Lengths, bucket)
+			}
+			tallies = append(tallies, current)
+		}
+
+		current.stats.Prayers++
+		current.stats.Words += wordCount
+		for i := len(lengthBuckets) - 1; i >= 0; i-- {
+			if wordCount >= lengthBuckets[i] {
+				current.stats.Lengths[i].Prayers++
+				break
+			}
+		}
+		if author == "" {
+			author = "(none)"
+		}
+		current.authors[author]++
+		current.categories[category]++
+		for _, r := range plainText {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			script, ok := scripts[r]
+			if !ok {
+				script = scriptOf(r)
+				scripts[r] = script
+			}
+			current.scripts[script]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var stats []LanguageStats
+	for _, t := range tallies {
+		t.stats.Authors = sortedCounts(t.authors)
+		t.stats.Categories = sortedCounts(t.categories)
+		t.stats.Scripts = sortedCounts(t.scripts)
+		stats = append(stats, t.stats)
+	}
+	return stats, nil
+}
+
+// scriptOf names the Unicode script of a letter
+func scriptOf(r rune) string {
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return "Unknown"
+}
+
+// sortedCounts lists the counts, most first and then by name
+func sortedCounts(counts map[string]int) []Count {
+	var list []Count
+	for name, count := range counts {
+		list = append(list, Count{Name: name, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
@@ -0,0 +1,57 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+)
+
+// createTagsSQL creates the tables holding every tag of every prayer.
+// Categorize only files a prayer under its first tag, but the app can use
+// the rest for secondary groupings.
+const createTagsSQL = `
+CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT NOT NULL, kind TEXT NOT NULL);
+CREATE TABLE prayer_tags (prayerId INTEGER NOT NULL, tagId INTEGER NOT NULL, position INTEGER NOT NULL, PRIMARY KEY (prayerId, tagId));
+CREATE INDEX prayer_tags_tag_index ON prayer_tags (tagId);`
+
+// createMergedTagsSQL is createTagsSQL with the language of each tag, for
+// the merged database
+const createMergedTagsSQL = `
+CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT NOT NULL, kind TEXT NOT NULL, language TEXT NOT NULL);
+CREATE TABLE prayer_tags (prayerId INTEGER NOT NULL, tagId INTEGER NOT NULL, position INTEGER NOT NULL, PRIMARY KEY (prayerId, tagId));`
+
+// mergedTagsIndexSQL indexes prayer_tags once the merged database is filled
+const mergedTagsIndexSQL = `CREATE INDEX prayer_tags_tag_index ON prayer_tags (tagId)`
+
+// populateTags stores the tags of the prayers, in the order the API lists
+// them for each prayer
+func populateTags(ctx context.Context, tx *sql.Tx, prayers []Prayer) error {
+	seen := make(map[int]bool)
+	for _, prayer := range prayers {
+		for i, tag := range prayer.Tags {
+			if !seen[tag.ID] {
+				seen[tag.ID] = true
+				_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO tags (id, name, kind) VALUES (?, ?, ?)`, tag.ID, tag.Name, tag.Kind)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO prayer_tags (prayerId, tagId, position) VALUES (?, ?, ?)`, prayer.ID, tag.ID, i)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeTags copies the tags of a per-language database into the merged one,
+// leaving out the prayers Merge skipped. Databases of the same language,
+// from different sources, share their tags.
+func mergeTags(ctx context.Context, tx *sql.Tx, lang string) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO tags (id, name, kind, language) SELECT id, name, kind, ? FROM lang.tags`, lang)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO prayer_tags (prayerId, tagId, position) SELECT prayerId, tagId, position FROM lang.prayer_tags WHERE prayerId IN (SELECT id FROM main.prayers)`)
+	return err
+}
@@ -0,0 +1,225 @@
+package prayerdb
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+	"golang.org/x/text/unicode/norm"
+)
+
+// TextSource reads prayers from text or Markdown files, for the languages
+// bahaiprayers.net doesn't have. Each language is a directory of Dir named
+// by its ISO code, with a .txt or .md file for each prayer, either directly
+// in it or in a subdirectory named after the prayer's category. The text is
+// marked up with the paragraph markers the API uses, and can start with a
+// header of "key: value" lines between "---" lines:
+//
+//	---
+//	id: 1000001
+//	author: Bahá’u’lláh
+//	category: Healing
+//	kind: GENERAL
+//	---
+//	O Lord! ...
+//
+// The author is an ID of the API or a name of the author, and the category
+// is the name of the prayer's tag, of the kind given, GENERAL by default.
+// Prayers without an id get one derived from their path, and prayers
+// without a category take the one of their subdirectory. They're listed in
+// the order of their paths.
+type TextSource struct {
+	Dir string
+	// Name is what the source column says of the prayers, "text" when empty
+	Name string
+}
+
+// textIDBase and textIDRange place the IDs derived for text prayers above
+// the API's and within 32 bits
+const (
+	textIDBase  = 1000000000
+	textIDRange = 1000000000
+)
+
+func (t TextSource) name() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return "text"
+}
+
+func (t TextSource) Language(ctx context.Context, query string) (*bpnet.Language, error) {
+	langs, err := t.Languages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bpnet.ResolveLanguage(langs, query)
+}
+
+func (t TextSource) Languages(ctx context.Context) ([]bpnet.Language, error) {
+	entries, err := ioutil.ReadDir(t.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var langs []bpnet.Language
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tag, err := language.Parse(entry.Name())
+		if err != nil {
+			continue
+		}
+		isoName := entry.Name()
+		langs = append(langs, bpnet.Language{
+			Name:        display.Self.Name(tag),
+			EnglishName: display.English.Tags().Name(tag),
+			ISOName:     isoName,
+			LeftToRight: !bpnet.RightToLeft(isoName),
+			Source:      t.name(),
+		})
+	}
+	return langs, nil
+}
+
+func (t TextSource) Prayers(ctx context.Context, lang bpnet.Language) (*bpnet.PrayersResponse, error) {
+	langDir := filepath.Join(t.Dir, lang.ISOName)
+	var paths []string
+	err := filepath.Walk(langDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".txt", ".md", ".markdown":
+			if !info.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	pr := &bpnet.PrayersResponse{}
+	ids := make(map[int]string)
+	for _, path := range paths {
+		rel, _ := filepath.Rel(langDir, path)
+		p, err := readTextPrayer(path, rel, lang)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if other, ok := ids[p.ID]; ok {
+			return nil, fmt.Errorf("%s and %s have the same id %d; give one of them an id", other, path, p.ID)
+		}
+		ids[p.ID] = path
+		pr.Prayers = append(pr.Prayers, p)
+	}
+	return pr, nil
+}
+
+// readTextPrayer reads the prayer in the file at path, which is at rel in
+// the directory of its language
+func readTextPrayer(path, rel string, lang bpnet.Language) (bpnet.Prayer, error) {
+	p := bpnet.Prayer{LanguageID: lang.ID}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	header, text, err := splitHeader(string(buf))
+	if err != nil {
+		return p, err
+	}
+	p.Text = strings.TrimSpace(text)
+	if p.Text == "" {
+		return p, fmt.Errorf("no text")
+	}
+
+	category := header["category"]
+	if category == "" && filepath.Dir(rel) != "." {
+		category = filepath.Base(filepath.Dir(rel))
+	}
+	kind := strings.ToUpper(header["kind"])
+	if kind == "" {
+		kind = bpnet.TagKindGeneral
+	}
+	if category != "" {
+		p.FirstTagName = category
+		p.Tags = []bpnet.Tag{{ID: textID(lang.ISOName, kind, category), Name: category, Kind: kind}}
+	}
+
+	if id := header["id"]; id != "" {
+		if p.ID, err = strconv.Atoi(id); err != nil {
+			return p, fmt.Errorf("bad id '%s'", id)
+		}
+	} else {
+		p.ID = textID(lang.ISOName, filepath.ToSlash(rel))
+	}
+	if author := header["author"]; author != "" {
+		if p.AuthorID, err = textAuthor(lang.ISOName, author); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// splitHeader splits the "key: value" header off the text of a prayer file.
+// The keys are lower cased.
+func splitHeader(text string) (map[string]string, string, error) {
+	header := make(map[string]string)
+	text = strings.TrimPrefix(text, "\ufeff")
+	lines := strings.SplitAfter(text, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return header, text, nil
+	}
+	consumed := len(lines[0])
+	for _, line := range lines[1:] {
+		consumed += len(line)
+		line = strings.TrimSpace(line)
+		if line == "---" {
+			return header, text[consumed:], nil
+		}
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, "", fmt.Errorf("bad header line '%s'", line)
+		}
+		header[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	return nil, "", fmt.Errorf("the header doesn't end with ---")
+}
+
+// textID derives a stable ID for a prayer or tag of a text source from
+// what identifies it
+func textID(parts ...string) int {
+	return textIDBase + int(crc32.ChecksumIEEE([]byte(strings.Join(parts, "/")))%textIDRange)
+}
+
+// textAuthor finds the ID of an author by its ID or one of its names
+func textAuthor(isoName, author string) (int, error) {
+	if id, err := strconv.Atoi(author); err == nil {
+		return id, nil
+	}
+	author = norm.NFC.String(author)
+	for _, names := range []map[int]string{languageAuthorMap[isoName], canonicalAuthors} {
+		for id, name := range names {
+			if strings.EqualFold(norm.NFC.String(name), author) {
+				return id, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown author '%s'", author)
+}
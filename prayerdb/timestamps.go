@@ -0,0 +1,60 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"os"
+)
+
+// prayerStamps are the createdAt and updatedAt of a prayer in an earlier
+// database, along with the hash of its content back then
+type prayerStamps struct {
+	createdAt   string
+	updatedAt   string
+	contentHash string
+}
+
+// previousStamps reads the timestamps of the prayers in the database that a
+// scrape is about to replace, so they carry over. There are none if the
+// database doesn't exist yet, or predates the timestamps.
+func previousStamps(ctx context.Context, dbPath string) map[int]prayerStamps {
+	stamps := make(map[int]prayerStamps)
+	if _, err := os.Stat(dbPath); err != nil {
+		return stamps
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return stamps
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT id, createdAt, updatedAt, contentHash FROM prayers WHERE deleted=0`)
+	if err != nil {
+		return stamps
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var s prayerStamps
+		if err := rows.Scan(&id, &s.createdAt, &s.updatedAt, &s.contentHash); err != nil {
+			return make(map[int]prayerStamps)
+		}
+		stamps[id] = s
+	}
+	return stamps
+}
+
+// stamp returns the createdAt and updatedAt of a scraped prayer, scraped at
+// scrapeTime. A prayer keeps its creation time, and its update time unless
+// its content changed.
+func stamp(previous map[int]prayerStamps, id int, hash, scrapeTime string) (createdAt, updatedAt string) {
+	s, ok := previous[id]
+	if !ok || s.createdAt == "" {
+		return scrapeTime, scrapeTime
+	}
+	if s.contentHash != hash || s.updatedAt == "" {
+		return s.createdAt, scrapeTime
+	}
+	return s.createdAt, s.updatedAt
+}
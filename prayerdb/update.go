@@ -0,0 +1,163 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+)
+
+// prayerColumns are the columns of the per-language prayers table that come
+// from the scrape, in the order prayerValues returns them
+var prayerColumns = []string{"id", "category", "prayerText", "openingWords", "citation", "author", "language", "plainText", "footnotes", "rawText", "hasInstructions", "authorId", "wordCount", "searchText", "sortKey", "contentHash", "createdAt", "updatedAt", "sortOrder", "source"}
+
+// insertPrayerSQL inserts a freshly scraped prayer
+var insertPrayerSQL = fmt.Sprintf(`INSERT INTO prayers (%s) VALUES (%s)`,
+	strings.Join(prayerColumns, ", "),
+	strings.TrimSuffix(strings.Repeat("?, ", len(prayerColumns)), ", "))
+
+// upsertPrayerSQL inserts or updates a scraped prayer, bringing it back if
+// it had been tombstoned. Prayers with local overrides are left alone. An
+// updated prayer keeps its createdAt, and its updatedAt too unless its
+// content hash changed.
+var upsertPrayerSQL = insertPrayerSQL + ` ON CONFLICT (id) DO UPDATE SET ` + upsertAssignments() + `, deleted=0 WHERE overridden=0`
+
+func upsertAssignments() string {
+	var assignments []string
+	for _, column := range prayerColumns[1:] {
+		switch column {
+		case "createdAt":
+		case "updatedAt":
+			assignments = append(assignments, "updatedAt=CASE WHEN contentHash=excluded.contentHash THEN updatedAt ELSE excluded.updatedAt END")
+		default:
+			assignments = append(assignments, fmt.Sprintf("%s=excluded.%s", column, column))
+		}
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// updateDatabase merges a scrape into an existing per-language database.
+// Prayers are matched by ID: changed ones are updated, new ones inserted,
+// and the ones missing from the scrape are tombstoned with the deleted
+// column, so they drop out of the merged database. Rows marked overridden
+// keep their local edits.
+func updateDatabase(ctx context.Context, s Scrape, lang bpnet.Language, dbPath string, opts Options) error {
+	if err := Migrate(ctx, dbPath); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existing := make(map[int]bool)
+	overridden := make(map[int]bool)
+	rows, err := tx.QueryContext(ctx, `SELECT id, overridden FROM prayers`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		var isOverridden bool
+		if err := rows.Scan(&id, &isOverridden); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[id] = true
+		overridden[id] = isOverridden
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	upsert, err := tx.PrepareContext(ctx, upsertPrayerSQL)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+
+	inserted, updated, kept := 0, 0, 0
+	scraped := make(map[int]bool)
+	var changeable []Prayer
+	for _, prayer := range s.Prayers {
+		scraped[prayer.ID] = true
+		if overridden[prayer.ID] {
+			kept++
+			continue
+		}
+		changeable = append(changeable, prayer)
+
+		values, err := prayerValues(prayer, lang, nil, opts.ScrapeTime)
+		if err != nil {
+			return err
+		}
+		if _, err := upsert.ExecContext(ctx, values...); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM removed_prayers WHERE id=?`, prayer.ID); err != nil {
+			return err
+		}
+		if existing[prayer.ID] {
+			updated++
+		} else {
+			inserted++
+		}
+	}
+
+	deleted := 0
+	for id := range existing {
+		if scraped[id] || overridden[id] {
+			continue
+		}
+		result, err := tx.ExecContext(ctx, `UPDATE prayers SET deleted=1 WHERE id=? AND deleted=0`, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			deleted++
+		}
+		if err := recordRemoval(ctx, tx, id, lang.ISOName, opts.ScrapeTime); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM prayer_tags WHERE prayerId NOT IN (SELECT id FROM prayers WHERE overridden=1)`)
+	if err != nil {
+		return err
+	}
+	err = populateTags(ctx, tx, changeable)
+	if err != nil {
+		return err
+	}
+	err = populateAuthors(ctx, tx, lang.ISOName)
+	if err != nil {
+		return err
+	}
+	err = populateCategories(ctx, tx, s, lang)
+	if err != nil {
+		return err
+	}
+	err = WriteMeta(ctx, tx, scrapeMeta(s, lang, opts))
+	if err != nil {
+		return err
+	}
+
+	logger().Info("updated database", "phase", "update", "language", lang.ISOName, "db", dbPath, "new", inserted, "updated", updated, "deleted", deleted, "overridden", kept)
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return Compact(ctx, db)
+}
@@ -0,0 +1,54 @@
+package prayerdb
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+)
+
+// SearchMismatch is a prayer whose stored search fields aren't what its
+// markup derives today, e.g. because the database was built with older
+// HTML stripping
+type SearchMismatch struct {
+	PrayerID int
+	Language string
+	// Column is wordCount or searchText
+	Column          string
+	Stored, Derived string
+}
+
+// VerifySearch derives the wordCount and searchText of every prayer of a
+// per-language or merged database from its prayerText again, returning the
+// ones whose stored values differ
+func VerifySearch(ctx context.Context, db *sql.DB) ([]SearchMismatch, error) {
+	hasDeleted, err := hasColumn(ctx, db, "prayers", "deleted")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT id, language, prayerText, openingWords, wordCount, searchText FROM prayers`
+	if hasDeleted {
+		query += ` WHERE deleted=0`
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY language, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []SearchMismatch
+	for rows.Next() {
+		var id, wordCount int
+		var lang, prayerText, openingWords, searchText string
+		if err := rows.Scan(&id, &lang, &prayerText, &openingWords, &wordCount, &searchText); err != nil {
+			return nil, err
+		}
+		derivedCount, derivedText, _ := searchFields(prayerText, openingWords, lang)
+		if derivedCount != wordCount {
+			mismatches = append(mismatches, SearchMismatch{PrayerID: id, Language: lang, Column: "wordCount", Stored: strconv.Itoa(wordCount), Derived: strconv.Itoa(derivedCount)})
+		}
+		if derivedText != searchText {
+			mismatches = append(mismatches, SearchMismatch{PrayerID: id, Language: lang, Column: "searchText", Stored: searchText, Derived: derivedText})
+		}
+	}
+	return mismatches, rows.Err()
+}
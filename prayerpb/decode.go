@@ -0,0 +1,72 @@
+package prayerpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrMalformed is returned by Decode for a message that isn't in the wire
+// format
+var ErrMalformed = errors.New("malformed request message")
+
+// Fields are the scalar fields of a decoded message. As in proto3, a field
+// that's repeated keeps its last value, and one that's missing has the
+// default value.
+type Fields struct {
+	varints map[int]uint64
+	bytes   map[int][]byte
+}
+
+// Decode decodes a message in the wire format, skipping the fixed size
+// fields, which the service's requests don't have
+func Decode(buf []byte) (Fields, error) {
+	fields := Fields{varints: make(map[int]uint64), bytes: make(map[int][]byte)}
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return fields, ErrMalformed
+		}
+		buf = buf[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(buf)
+			if n <= 0 {
+				return fields, ErrMalformed
+			}
+			fields.varints[field] = v
+			buf = buf[n:]
+		case 1:
+			if len(buf) < 8 {
+				return fields, ErrMalformed
+			}
+			buf = buf[8:]
+		case 2:
+			size, n := binary.Uvarint(buf)
+			if n <= 0 || size > uint64(len(buf)-n) {
+				return fields, ErrMalformed
+			}
+			fields.bytes[field] = buf[n : n+int(size)]
+			buf = buf[n+int(size):]
+		case 5:
+			if len(buf) < 4 {
+				return fields, ErrMalformed
+			}
+			buf = buf[4:]
+		default:
+			return fields, fmt.Errorf("unsupported wire type %d in the request message", key&7)
+		}
+	}
+	return fields, nil
+}
+
+// String reads a string field
+func (f Fields) String(field int) string {
+	return string(f.bytes[field])
+}
+
+// Int32 reads an int32 field, whose negative values are sign extended
+func (f Fields) Int32(field int) int {
+	return int(int32(f.varints[field]))
+}
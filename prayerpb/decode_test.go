@@ -0,0 +1,78 @@
+package prayerpb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		name string
+		buf  []byte
+		// int32 and string are what fields 1 and 2 decode to
+		int32  int
+		string string
+	}{
+		{"empty", nil, 0, ""},
+		{"varint", []byte{0x08, 0x96, 0x01}, 150, ""},
+		{"negative int32", []byte{0x08, 0xea, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, -150, ""},
+		{"minus one", []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, -1, ""},
+		{"int32 truncated to 32 bits", []byte{0x08, 0x85, 0x80, 0x80, 0x80, 0x10}, 5, ""},
+		{"string", []byte{0x12, 0x03, 'a', 'b', 'c'}, 0, "abc"},
+		{"empty string", []byte{0x12, 0x00}, 0, ""},
+		{"repeated field keeps the last", []byte{0x08, 0x01, 0x08, 0x02}, 2, ""},
+		{"unknown field", []byte{0x18, 0x05, 0x08, 0x07}, 7, ""},
+		{"fixed64 skipped", []byte{0x09, 1, 2, 3, 4, 5, 6, 7, 8, 0x08, 0x07}, 7, ""},
+		{"fixed32 skipped", []byte{0x0d, 1, 2, 3, 4, 0x08, 0x07}, 7, ""},
+		{"fixed64 of the same field", []byte{0x08, 0x07, 0x09, 1, 2, 3, 4, 5, 6, 7, 8}, 7, ""},
+		{"field number over 15", []byte{0x80, 0x01, 0x01, 0x12, 0x01, 'x'}, 0, "x"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fields, err := Decode(c.buf)
+			if err != nil {
+				t.Fatalf("Decode(% x) failed: %v", c.buf, err)
+			}
+			if got := fields.Int32(1); got != c.int32 {
+				t.Errorf("field 1 of % x = %d, want %d", c.buf, got, c.int32)
+			}
+			if got := fields.String(2); got != c.string {
+				t.Errorf("field 2 of % x = %q, want %q", c.buf, got, c.string)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	cases := []struct {
+		name string
+		buf  []byte
+		// malformed is whether the error is ErrMalformed, rather than one
+		// naming an unsupported wire type
+		malformed bool
+	}{
+		{"truncated key", []byte{0x80}, true},
+		{"truncated varint", []byte{0x08, 0x96}, true},
+		{"varint over ten bytes", []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, true},
+		{"length over the message", []byte{0x12, 0x05, 'a', 'b'}, true},
+		{"length over the message by one", []byte{0x12, 0x03, 'a', 'b'}, true},
+		{"huge length", []byte{0x12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 'a'}, true},
+		{"truncated length", []byte{0x12}, true},
+		{"truncated fixed64", []byte{0x09, 1, 2, 3, 4, 5, 6, 7}, true},
+		{"truncated fixed32", []byte{0x0d, 1, 2, 3}, true},
+		{"start group", []byte{0x0b}, false},
+		{"end group", []byte{0x0c}, false},
+		{"wire type 7", []byte{0x0f}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Decode(c.buf)
+			if err == nil {
+				t.Fatalf("Decode(% x) succeeded, want an error", c.buf)
+			}
+			if got := errors.Is(err, ErrMalformed); got != c.malformed {
+				t.Errorf("Decode(% x) = %v, is ErrMalformed %v, want %v", c.buf, err, got, c.malformed)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+// Package prayerpb encodes and decodes the protocol buffers of merged
+// databases, in the schema of prayers.proto, without generated code or a
+// protobuf library. The export package writes a whole Corpus with it, and
+// the gRPC service of the server package answers with its messages:
+//
+//	var listing prayerpb.Message
+//	listing.Int32(1, id)
+//	listing.String(2, language)
+//	var resp prayerpb.Message
+//	resp.Embed(1, listing)
+//
+// Requests are decoded into their scalar Fields with Decode.
+package prayerpb
+
+import (
+	"fmt"
+	"math"
+)
+
+// Schema is prayers.proto, the schema of the protobuf export and of the gRPC
+// service. It's written next to every export so consumers can generate
+// their types from it, and has to be kept in sync with the encoders of the
+// messages. Fields are only ever added, never renumbered.
+const Schema = `// The prayers of a merged database, as written by bpnet-scraper -export protobuf
+// and served by bpnet-scraper -serve
+syntax = "proto3";
+
+package bpnet.prayers.v1;
+
+message Corpus {
+  string scraper_version = 1;
+  string merged_at = 2;
+  // ordered by language code
+  repeated Language languages = 3;
+}
+
+message Language {
+  string code = 1;
+  string scraped_at = 2;
+  repeated Tag tags = 3;
+  repeated Author authors = 4;
+  // ordered by category, then the way the app lists them
+  repeated Prayer prayers = 5;
+}
+
+message Tag {
+  int32 id = 1;
+  string name = 2;
+  // GENERAL, OBLIGATORY, OCCASSIONAL or TABLETS
+  string kind = 3;
+}
+
+message Author {
+  int32 id = 1;
+  string name = 2;
+  string localized_name = 3;
+}
+
+message Prayer {
+  int32 id = 1;
+  string category = 2;
+  string opening_words = 3;
+  // HTML
+  string prayer_text = 4;
+  string plain_text = 5;
+  string citation = 6;
+  string author = 7;
+  int32 author_id = 8;
+  int32 word_count = 9;
+  bool has_instructions = 10;
+  repeated Footnote footnotes = 11;
+  // set when merged with -duplicates link
+  int32 duplicate_of = 12;
+  string content_hash = 13;
+  string created_at = 14;
+  string updated_at = 15;
+  // in the order the prayer was tagged
+  repeated int32 tag_ids = 16;
+}
+
+message Footnote {
+  int32 number = 1;
+  string text = 2;
+}
+
+// PrayerService serves the prayers of merged.db, on the address of
+// bpnet-scraper -serve
+service PrayerService {
+  rpc ListLanguages(ListLanguagesRequest) returns (ListLanguagesResponse);
+  rpc ListPrayers(ListPrayersRequest) returns (ListPrayersResponse);
+  rpc GetPrayer(GetPrayerRequest) returns (GetPrayerResponse);
+  // finds the prayers with every word of the query, ignoring case and accents
+  rpc Search(SearchRequest) returns (ListPrayersResponse);
+}
+
+message ListLanguagesRequest {}
+
+message ListLanguagesResponse {
+  // ordered by language code
+  repeated LanguageSummary languages = 1;
+}
+
+message LanguageSummary {
+  string code = 1;
+  int32 prayers = 2;
+  // ordered by name
+  repeated CategorySummary categories = 3;
+}
+
+message CategorySummary {
+  string name = 1;
+  int32 prayers = 2;
+}
+
+// an empty language or category lists them all
+message ListPrayersRequest {
+  string language = 1;
+  string category = 2;
+}
+
+message ListPrayersResponse {
+  // ordered by language, then the way the app lists them
+  repeated PrayerListing prayers = 1;
+}
+
+message PrayerListing {
+  int32 id = 1;
+  string language = 2;
+  string category = 3;
+  string opening_words = 4;
+  string author = 5;
+  int32 word_count = 6;
+}
+
+message GetPrayerRequest {
+  int32 id = 1;
+}
+
+message GetPrayerResponse {
+  string language = 1;
+  Prayer prayer = 2;
+}
+
+message SearchRequest {
+  string query = 1;
+  // empty searches every language
+  string language = 2;
+  // 0 or more than 50 returns up to 50 prayers
+  int32 limit = 3;
+}
+`
+
+// Message encodes a protobuf message in the wire format. Like proto3, it
+// leaves out fields with default values.
+type Message []byte
+
+func (m *Message) varint(v uint64) {
+	for v >= 0x80 {
+		*m = append(*m, byte(v)|0x80)
+		v >>= 7
+	}
+	*m = append(*m, byte(v))
+}
+
+func (m *Message) key(field, wireType int) {
+	m.varint(uint64(field<<3 | wireType))
+}
+
+// Int32 writes an int32 field. Negative values are sign extended to ten
+// bytes, as the wire format requires. It panics when v doesn't fit in 32
+// bits.
+func (m *Message) Int32(field int, v int) {
+	if v == 0 {
+		return
+	}
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		panic(fmt.Sprintf("%d doesn't fit protobuf field %d", v, field))
+	}
+	m.key(field, 0)
+	m.varint(uint64(int64(v)))
+}
+
+// Bool writes a bool field
+func (m *Message) Bool(field int, v bool) {
+	if v {
+		m.key(field, 0)
+		m.varint(1)
+	}
+}
+
+// Bytes writes a bytes field, even an empty one
+func (m *Message) Bytes(field int, v []byte) {
+	m.key(field, 2)
+	m.varint(uint64(len(v)))
+	*m = append(*m, v...)
+}
+
+// String writes a string field
+func (m *Message) String(field int, v string) {
+	if v != "" {
+		m.Bytes(field, []byte(v))
+	}
+}
+
+// Embed writes an embedded message, which is written even when it's empty,
+// since it's an element of a repeated field
+func (m *Message) Embed(field int, v Message) {
+	m.Bytes(field, v)
+}
+
+// Packed writes a repeated int32 field, packed as proto3 does by default
+func (m *Message) Packed(field int, vs []int) {
+	if len(vs) == 0 {
+		return
+	}
+	var packed Message
+	for _, v := range vs {
+		packed.varint(uint64(int64(v)))
+	}
+	m.Bytes(field, packed)
+}
@@ -0,0 +1,86 @@
+package prayerpb
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMessage(t *testing.T) {
+	minusOne := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	cases := []struct {
+		name  string
+		write func(m *Message)
+		want  []byte
+	}{
+		{"int32", func(m *Message) { m.Int32(1, 150) }, []byte{0x08, 0x96, 0x01}},
+		{"zero int32 left out", func(m *Message) { m.Int32(1, 0) }, nil},
+		{"negative int32", func(m *Message) { m.Int32(1, -1) }, append([]byte{0x08}, minusOne...)},
+		{"largest int32", func(m *Message) { m.Int32(1, math.MaxInt32) }, []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0x07}},
+		{"field number over 15", func(m *Message) { m.Int32(16, 1) }, []byte{0x80, 0x01, 0x01}},
+		{"true", func(m *Message) { m.Bool(3, true) }, []byte{0x18, 0x01}},
+		{"false left out", func(m *Message) { m.Bool(3, false) }, nil},
+		{"string", func(m *Message) { m.String(2, "abc") }, []byte{0x12, 0x03, 'a', 'b', 'c'}},
+		{"UTF-8 string", func(m *Message) { m.String(2, "Báb") }, []byte{0x12, 0x04, 'B', 0xc3, 0xa1, 'b'}},
+		{"empty string left out", func(m *Message) { m.String(2, "") }, nil},
+		{"long string", func(m *Message) { m.String(2, strings.Repeat("a", 200)) }, append([]byte{0x12, 0xc8, 0x01}, strings.Repeat("a", 200)...)},
+		{"empty bytes", func(m *Message) { m.Bytes(1, nil) }, []byte{0x0a, 0x00}},
+		{"empty embedded message", func(m *Message) { m.Embed(1, nil) }, []byte{0x0a, 0x00}},
+		{"embedded message", func(m *Message) {
+			var inner Message
+			inner.Int32(1, 150)
+			m.Embed(4, inner)
+		}, []byte{0x22, 0x03, 0x08, 0x96, 0x01}},
+		{"packed", func(m *Message) { m.Packed(5, []int{1, 150, -1}) }, append([]byte{0x2a, 0x0d, 0x01, 0x96, 0x01}, minusOne...)},
+		{"empty packed left out", func(m *Message) { m.Packed(5, nil) }, nil},
+		{"fields in order", func(m *Message) {
+			m.Int32(1, 1)
+			m.String(2, "en")
+			m.Bool(3, true)
+		}, []byte{0x08, 0x01, 0x12, 0x02, 'e', 'n', 0x18, 0x01}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var m Message
+			c.write(&m)
+			if !bytes.Equal(m, c.want) {
+				t.Errorf("the message is % x, want % x", []byte(m), c.want)
+			}
+		})
+	}
+}
+
+func TestMessageDecodes(t *testing.T) {
+	var m Message
+	m.Int32(1, -150)
+	m.String(2, "O God, my God!")
+	m.Packed(3, []int{1, 2, 3})
+	fields, err := Decode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fields.Int32(1); got != -150 {
+		t.Errorf("field 1 = %d, want -150", got)
+	}
+	if got := fields.String(2); got != "O God, my God!" {
+		t.Errorf("field 2 = %q, want %q", got, "O God, my God!")
+	}
+	if got := fields.String(3); got != "\x01\x02\x03" {
+		t.Errorf("field 3 = %q, want the packed varints", got)
+	}
+}
+
+func TestInt32Overflow(t *testing.T) {
+	for _, v := range []int{math.MaxInt32 + 1, math.MinInt32 - 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Int32(1, %d) didn't panic", v)
+				}
+			}()
+			var m Message
+			m.Int32(1, v)
+		}()
+	}
+}
@@ -0,0 +1,180 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// Config holds the settings of the markup that can be changed without
+// touching the code, usually loaded from a JSON file with LoadConfig
+type Config struct {
+	Languages map[string]LanguageConfig `json:"languages"`
+
+	// Classes renames the CSS classes used in the generated HTML, so the
+	// databases can target a different stylesheet. The keys are the default
+	// names: opening, versal, refrain, comment, commentcaps, instruction,
+	// blockquote, centered, noindent and footnotes.
+	Classes map[string]string `json:"classes"`
+
+	// Titles decides whether prayers that have a title (obligatory and
+	// occasional prayers get the name of their tag) are listed by it, or by
+	// their opening words. Keys are category names or tag kinds (GENERAL,
+	// OBLIGATORY, OCCASSIONAL, TABLETS), and values are "title" or
+	// "openingWords". Prayers are listed by their title by default.
+	Titles map[string]string `json:"titles"`
+
+	// CategoryWeights override prayerdb.DefaultCategoryWeights, which order
+	// the categories of each language. Keys are category names or tag kinds,
+	// like Titles, and lighter categories come first.
+	CategoryWeights map[string]int `json:"categoryWeights"`
+
+	// PrayerOrder decides whether the prayers of a category are numbered in
+	// the order of the API ("api") or alphabetically by their opening words
+	// ("alphabetical"), for the app to list them by. Keys are category names
+	// or tag kinds, like Titles. Prayers are alphabetical by default.
+	PrayerOrder map[string]string `json:"prayerOrder"`
+}
+
+// LanguageConfig holds the settings for a single language. They're keyed by
+// the language's ISO name in Config.Languages.
+type LanguageConfig struct {
+	// Versal turns the drop cap on the first letter of a prayer on or off.
+	// When it's not set, the script of the first letter decides.
+	Versal *bool `json:"versal,omitempty"`
+
+	// OpeningLength overrides the opening length of the Scraper for the
+	// language
+	OpeningLength int `json:"openingLength,omitempty"`
+
+	// Ellipsis is appended to truncated opening words. It defaults to "…" for
+	// left-to-right languages and nothing for right-to-left ones.
+	Ellipsis *string `json:"ellipsis,omitempty"`
+
+	// Quotes overrides the quotation marks of the typography: opening and
+	// closing double quotes, then opening and closing single quotes.
+	Quotes []string `json:"quotes,omitempty"`
+
+	// Titles overrides the top level Titles for the language
+	Titles map[string]string `json:"titles,omitempty"`
+
+	// CategoryWeights overrides the top level CategoryWeights for the
+	// language
+	CategoryWeights map[string]int `json:"categoryWeights,omitempty"`
+
+	// PrayerOrder overrides the top level PrayerOrder for the language
+	PrayerOrder map[string]string `json:"prayerOrder,omitempty"`
+
+	// Counterparts maps the IDs of prayers of the language to the IDs of
+	// the same prayers in English, so their word counts can be compared.
+	// The API doesn't link translations, so this is curated by hand.
+	Counterparts map[int]int `json:"counterparts,omitempty"`
+}
+
+// Title precedences
+const (
+	titleFirst   = "title"
+	openingFirst = "openingWords"
+)
+
+// LoadConfig reads a Config from the JSON file at path
+func LoadConfig(path string) (Config, error) {
+	config := Config{}
+	f, err := os.Open(path)
+	if err != nil {
+		return config, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&config); err != nil {
+		return config, err
+	}
+
+	allTitles := []map[string]string{config.Titles}
+	for _, lc := range config.Languages {
+		allTitles = append(allTitles, lc.Titles)
+	}
+	for _, titles := range allTitles {
+		for category, precedence := range titles {
+			if precedence != titleFirst && precedence != openingFirst {
+				return config, fmt.Errorf("invalid title precedence '%s' for '%s'", precedence, category)
+			}
+		}
+	}
+
+	allOrders := []map[string]string{config.PrayerOrder}
+	for _, lc := range config.Languages {
+		allOrders = append(allOrders, lc.PrayerOrder)
+	}
+	for _, orders := range allOrders {
+		for category, order := range orders {
+			if order != prayerdb.OrderAPI && order != prayerdb.OrderAlphabetical {
+				return config, fmt.Errorf("invalid prayer order '%s' for '%s'", order, category)
+			}
+		}
+	}
+	return config, nil
+}
+
+// Counterparts returns the Counterparts of every language that has them,
+// keyed by its ISO name
+func (c Config) Counterparts() map[string]map[int]int {
+	counterparts := make(map[string]map[int]int)
+	for lang, lc := range c.Languages {
+		if len(lc.Counterparts) > 0 {
+			counterparts[lang] = lc.Counterparts
+		}
+	}
+	return counterparts
+}
+
+func (c Config) language(isoName string) LanguageConfig {
+	return c.Languages[isoName]
+}
+
+// titlePrecedence returns whether a prayer in the category is listed by its
+// title or its opening words. Settings for the language win over the global
+// ones, and settings for the category name win over the ones for the kind.
+func (c Config) titlePrecedence(lang bpnet.Language, category, kind string) string {
+	for _, titles := range []map[string]string{c.language(lang.ISOName).Titles, c.Titles} {
+		if p, ok := titles[category]; ok {
+			return p
+		}
+		if p, ok := titles[kind]; ok {
+			return p
+		}
+	}
+	return titleFirst
+}
+
+// categoryWeight returns the weight of a category of the language, with the
+// same precedence as titlePrecedence, falling back on the default weight of
+// its kind
+func (c Config) categoryWeight(lang bpnet.Language, category, kind string) int {
+	for _, weights := range []map[string]int{c.language(lang.ISOName).CategoryWeights, c.CategoryWeights} {
+		if w, ok := weights[category]; ok {
+			return w
+		}
+		if w, ok := weights[kind]; ok {
+			return w
+		}
+	}
+	return prayerdb.DefaultCategoryWeight(category, kind)
+}
+
+// prayerOrder returns how the prayers of a category are ordered, with the
+// same precedence as titlePrecedence
+func (c Config) prayerOrder(lang bpnet.Language, category, kind string) string {
+	for _, orders := range []map[string]string{c.language(lang.ISOName).PrayerOrder, c.PrayerOrder} {
+		if o, ok := orders[category]; ok {
+			return o
+		}
+		if o, ok := orders[kind]; ok {
+			return o
+		}
+	}
+	return prayerdb.OrderAlphabetical
+}
@@ -0,0 +1,22 @@
+package scraper
+
+import (
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/markup"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// lint checks the markers of every prayer, logging the issues found. When
+// repairing, the prayers' text is replaced by the repaired one.
+func (s *Scraper) lint(scrape *prayerdb.Scrape, lang bpnet.Language) {
+	for i := range scrape.Prayers {
+		prayer := &scrape.Prayers[i]
+		repaired, issues := markup.Lint(prayer.Text)
+		for _, issue := range issues {
+			s.log().Warn(issue.Problem, "phase", "lint", "language", lang.ISOName, "prayer", prayer.ID, "paragraph", issue.Paragraph+1, "repaired", s.repairMarkers && issue.Repaired)
+		}
+		if s.repairMarkers && len(issues) > 0 {
+			prayer.Text = repaired
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package scraper
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMarkup renders every case of the golden corpus in testdata/markup, the
+// one -selftest checks, and compares it with its golden file. After a
+// deliberate change to the markup, rewrite them with
+// bpnet-scraper -update-golden -golden-dir scraper/testdata/markup.
+func TestMarkup(t *testing.T) {
+	cases, err := LoadGoldenCases(os.DirFS("testdata/markup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("the corpus has no cases")
+	}
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			if c.Golden == "" {
+				t.Fatalf("%s.txt has no golden file", c.Name)
+			}
+			got, err := s.RenderGolden(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.Golden {
+				m := GoldenMismatch{Name: c.Name, Want: c.Golden, Got: got}
+				t.Errorf("the markup doesn't match %s.html\n%s", c.Name, m.Diff())
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/markup"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// versalScripts are the scripts where a drop cap on the first letter of a
+// prayer makes sense. Scripts without letter case, or whose letters join
+// (Arabic, Chinese, ...), don't get a versal.
+var versalScripts = []*unicode.RangeTable{
+	unicode.Latin,
+	unicode.Greek,
+	unicode.Cyrillic,
+	unicode.Armenian,
+	unicode.Georgian,
+}
+
+// Versal reports whether a prayer of the language starting with the letter
+// first should have it marked up as a versal. Only letters are, even when
+// the language turns versals on.
+func (s *Scraper) Versal(l bpnet.Language, first rune) bool {
+	if !unicode.IsLetter(first) {
+		return false
+	}
+	if v := s.config.language(l.ISOName).Versal; v != nil {
+		return *v
+	}
+	return l.LeftToRight && unicode.In(first, versalScripts...)
+}
+
+// openingLengthOf is the most runes the opening words of a prayer of the
+// language can have
+func (s *Scraper) openingLengthOf(l bpnet.Language) int {
+	if n := s.config.language(l.ISOName).OpeningLength; n > 0 {
+		return n
+	}
+	return s.openingLength
+}
+
+// ellipsis is appended to opening words of the language that had to be
+// truncated
+func (s *Scraper) ellipsis(l bpnet.Language) string {
+	if e := s.config.language(l.ISOName).Ellipsis; e != nil {
+		return *e
+	}
+	if l.LeftToRight {
+		return "…"
+	}
+	return ""
+}
+
+// Scrape retrieves the prayers of the language query names from the source,
+// marks them up and stores them in the sinks
+func (s *Scraper) Scrape(ctx context.Context, query string) error {
+	lang, err := s.source.Language(ctx, query)
+	if err != nil {
+		return err
+	}
+	s.log().Info("looked up language", "phase", "scrape", "language", lang.ISOName, "id", lang.ID)
+	return s.scrape(ctx, *lang)
+}
+
+// Refusal is a language whose prayers the API refused to hand out, flagging
+// its response as in error
+type Refusal struct {
+	Language bpnet.Language
+	Err      *bpnet.APIError
+}
+
+// Failure is what a scrape of a language fails with, saying where it failed
+// so the error can be reported with that context. Prayer is 0 when the
+// failure isn't about a single prayer.
+type Failure struct {
+	Phase    string
+	Language string
+	Prayer   int
+	Err      error
+}
+
+func (f *Failure) Error() string {
+	return f.Err.Error()
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// fail wraps err in a Failure of phase, unless it has one already
+func fail(phase string, lang bpnet.Language, err error) error {
+	var f *Failure
+	if errors.As(err, &f) {
+		return err
+	}
+	return &Failure{Phase: phase, Language: lang.ISOName, Err: err}
+}
+
+// ScrapeAll scrapes every language of the source. The languages the API
+// refuses are skipped and returned, and so are the Failures of the ones
+// that fail otherwise, so one language doesn't hold up the rest. Only an
+// error listing the languages, or a cancelled ctx, stops the scrape.
+func (s *Scraper) ScrapeAll(ctx context.Context) ([]Refusal, []*Failure, error) {
+	langs, err := s.source.Languages(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var refusals []Refusal
+	var failures []*Failure
+	for _, lang := range langs {
+		err := s.scrape(ctx, lang)
+		var apiErr *bpnet.APIError
+		if errors.As(err, &apiErr) {
+			s.log().Warn("the API refused the language", "phase", "scrape", "language", lang.ISOName, "id", lang.ID, "message", apiErr.ErrorMessage)
+			refusals = append(refusals, Refusal{Language: lang, Err: apiErr})
+			continue
+		}
+		if ctx.Err() != nil {
+			return refusals, failures, ctx.Err()
+		}
+		if err != nil {
+			var f *Failure
+			errors.As(fail("scrape", lang, err), &f)
+			args := []interface{}{"phase", f.Phase, "language", lang.ISOName, "error", f.Err}
+			if f.Prayer != 0 {
+				args = append(args, "prayer", f.Prayer)
+			}
+			s.log().Error("the scrape of the language failed", args...)
+			failures = append(failures, f)
+		}
+	}
+	return refusals, failures, nil
+}
+
+// scrape retrieves the prayers of lang from the source, marks them up and
+// stores them in the sinks
+func (s *Scraper) scrape(ctx context.Context, lang bpnet.Language) error {
+	started := time.Now()
+	log := s.log().With("phase", "scrape", "language", lang.ISOName)
+	pr, err := s.source.Prayers(ctx, lang)
+	if err != nil {
+		return fail("scrape", lang, err)
+	}
+	log.Info("retrieved prayers", "count", len(pr.Prayers), "version", pr.Version)
+
+	if err := s.checkAuthors(pr.Prayers, lang); err != nil {
+		return fail("authors", lang, err)
+	}
+
+	scrape := prayerdb.NewScrape(pr)
+	scrape.Started = started
+
+	s.PrepareText(scrape, lang)
+
+	if err := prayerdb.Categorize(scrape, lang, s.translations); err != nil {
+		return fail("categorize", lang, fmt.Errorf("unable to categorize the prayers: %w", err))
+	}
+	prayerdb.OrderCategories(scrape, lang, func(category, kind string) int {
+		return s.config.categoryWeight(lang, category, kind)
+	})
+
+	if err := s.markupPrayers(scrape, lang); err != nil {
+		return fail("markup", lang, fmt.Errorf("unable to mark up the prayers: %w", err))
+	}
+	prayerdb.OrderPrayers(scrape, lang, func(category, kind string) string {
+		return s.config.prayerOrder(lang, category, kind)
+	})
+
+	if s.format == MarkupHTML {
+		if err := s.validate(scrape, lang); err != nil {
+			return fail("validate", lang, err)
+		}
+	}
+	if _, err := s.checkWordCounts(ctx, scrape, lang); err != nil {
+		return fail("validate", lang, fmt.Errorf("unable to check the word counts: %w", err))
+	}
+
+	for _, sink := range s.sinks {
+		if err := sink.Store(ctx, *scrape, lang); err != nil {
+			return fail("store", lang, fmt.Errorf("unable to populate the %s: %w", sink.Name(), err))
+		}
+		log.Info("populated "+sink.Name(), "sink", sink.Name())
+	}
+	return nil
+}
+
+// checkAuthors logs the authors of the prayers that have no name in the
+// language, with a few of their prayers, before anything is stored without
+// one. With strict authors, any of them is an error.
+func (s *Scraper) checkAuthors(prayers []bpnet.Prayer, lang bpnet.Language) error {
+	missing := prayerdb.MissingAuthors(lang.ISOName, prayers)
+	for _, m := range missing {
+		s.log().Warn("author has no name in the language", "phase", "authors", "language", lang.ISOName, "author", m.AuthorID, "prayers", m.Prayers, "samples", strings.Join(m.Samples, " | "))
+	}
+	if len(missing) > 0 && s.strictAuthors {
+		var ids []string
+		for _, m := range missing {
+			ids = append(ids, strconv.Itoa(m.AuthorID))
+		}
+		return fmt.Errorf("authors without a name in %s: %s", lang.ISOName, strings.Join(ids, ", "))
+	}
+	return nil
+}
+
+// PrepareText cleans up the text of the prayers before it's parsed
+func (s *Scraper) PrepareText(scrape *prayerdb.Scrape, lang bpnet.Language) {
+	for i := range scrape.Prayers {
+		scrape.Prayers[i].Normalize()
+	}
+
+	if s.typography {
+		s.typeset(scrape, lang)
+	}
+
+	if s.lintMarkers {
+		s.lint(scrape, lang)
+	}
+}
+
+// listingWords returns what the prayer is listed by in the app: its title or
+// its opening words, depending on the title precedence configured for its
+// category
+func (s *Scraper) listingWords(p prayerdb.Prayer, lang bpnet.Language) string {
+	if p.Title != "" && s.config.titlePrecedence(lang, p.Category, p.Kind) == titleFirst {
+		return p.Title
+	}
+	return p.OpeningWords
+}
+
+// markupPrayers parses the text of every prayer, deriving its markup, plain
+// text, listing words, citation and footnotes. The prayers don't depend on
+// each other, so they're marked up by a pool of workers. The error of the
+// first prayer that fails is returned, as a Failure naming the prayer.
+func (s *Scraper) markupPrayers(scrape *prayerdb.Scrape, lang bpnet.Language) error {
+	errs := make([]error, len(scrape.Prayers))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < s.workerCount(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = s.markupPrayer(&scrape.Prayers[i], lang)
+			}
+		}()
+	}
+	for i := range scrape.Prayers {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return &Failure{Phase: "markup", Language: lang.ISOName, Prayer: scrape.Prayers[i].ID, Err: err}
+		}
+	}
+	return nil
+}
+
+// markupPrayer parses the text of a prayer, deriving its markup, plain text,
+// listing words, citation and footnotes
+func (s *Scraper) markupPrayer(prayer *prayerdb.Prayer, lang bpnet.Language) error {
+	doc := markup.Parse(prayer.Text)
+	if prayer.Kind == bpnet.TagKindObligatory {
+		doc.MarkInstructions()
+	}
+	prayer.HasInstructions = doc.HasInstructions()
+	prayer.OpeningWords = doc.OpeningWords(s.openingLengthOf(lang), s.ellipsis(lang))
+	prayer.OpeningWords = s.listingWords(*prayer, lang)
+	prayer.Footnotes = doc.Footnotes()
+	var citations []string
+	for _, c := range doc.Citations() {
+		if s.format == MarkupHTML {
+			var err error
+			if c, err = s.renderer.Citation(c); err != nil {
+				return fmt.Errorf("prayer %d: %w", prayer.ID, err)
+			}
+		}
+		citations = append(citations, c)
+	}
+	prayer.Citation = strings.Join(citations, "\n")
+	if s.format == MarkupMarkdown {
+		prayer.PrayerText = markup.Markdown(doc)
+	} else {
+		html, err := s.renderer.HTML(doc, func(first rune) bool { return s.Versal(lang, first) })
+		if err != nil {
+			return fmt.Errorf("prayer %d: %w", prayer.ID, err)
+		}
+		prayer.PrayerText = html
+	}
+	prayer.PlainText = markup.Plain(doc)
+	return nil
+}
@@ -0,0 +1,334 @@
+// Package scraper turns the prayers of a language into what the apps ship.
+// It reads them from a prayerdb.PrayerSource, cleans up, categorizes and
+// marks up their text, and stores them in prayerdb.PrayerSinks:
+//
+//	s, err := scraper.New(scraper.WithCacheDir("cache"), scraper.WithRateLimit(time.Second))
+//	...
+//	err = s.Scrape(ctx, "de")
+package scraper
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"time"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/markup"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// Formats that prayers can be marked up in
+const (
+	MarkupHTML     = "html"
+	MarkupMarkdown = "markdown"
+)
+
+// DefaultOpeningLength is the most runes the opening words of a prayer have,
+// unless WithOpeningLength or the config of its language says otherwise
+const DefaultOpeningLength = 35
+
+// Scraper runs the pipeline, as its Options configure it
+type Scraper struct {
+	source       prayerdb.PrayerSource
+	extraSources []prayerdb.PrayerSource
+	sinks        []prayerdb.PrayerSink
+	logger       *slog.Logger
+
+	httpClient *http.Client
+	middleware []bpnet.Middleware
+	baseURL    string
+	cacheDir   string
+	rateLimit  time.Duration
+	retries    int
+	strictAPI  bool
+
+	config        Config
+	templatesDir  string
+	format        string
+	openingLength int
+	translations  prayerdb.Translations
+	renderer      *markup.Renderer
+	workers       int
+
+	typography    bool
+	lintMarkers   bool
+	repairMarkers bool
+	strictHTML    bool
+	strictAuthors bool
+}
+
+// Option configures a Scraper
+type Option func(s *Scraper) error
+
+// New returns a Scraper that reads from the API and writes the per-language
+// SQLite database, unless its options say otherwise
+func New(opts ...Option) (*Scraper, error) {
+	s := &Scraper{
+		retries:       1,
+		format:        MarkupHTML,
+		openingLength: DefaultOpeningLength,
+		translations:  prayerdb.DefaultTranslations,
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	s.renderer = markup.NewRenderer(s.config.Classes)
+	if s.templatesDir != "" {
+		if err := s.renderer.LoadTemplates(s.templatesDir); err != nil {
+			return nil, fmt.Errorf("unable to load templates: %w", err)
+		}
+	}
+	if s.source == nil {
+		s.source = prayerdb.APISource{Client: s.client()}
+	}
+	if len(s.extraSources) > 0 {
+		s.source = append(prayerdb.Sources{s.source}, s.extraSources...)
+	}
+	if s.sinks == nil {
+		s.sinks = []prayerdb.PrayerSink{prayerdb.SQLiteSink{}}
+	}
+	return s, nil
+}
+
+// client returns the API client the options describe
+func (s *Scraper) client() *bpnet.Client {
+	c := &bpnet.Client{BaseURL: s.baseURL, HTTPClient: s.httpClient, Strict: s.strictAPI}
+	c.Middleware = append(c.Middleware, s.middleware...)
+	if s.cacheDir != "" {
+		c.Middleware = append(c.Middleware, bpnet.DiskCache(s.cacheDir, 0))
+	}
+	if s.retries > 1 {
+		c.Middleware = append(c.Middleware, bpnet.Retry(s.retries, time.Second))
+	}
+	if s.rateLimit > 0 {
+		c.Middleware = append(c.Middleware, bpnet.RateLimit(s.rateLimit))
+	}
+	return c
+}
+
+// Config returns the markup settings of the languages
+func (s *Scraper) Config() Config {
+	return s.config
+}
+
+func (s *Scraper) log() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
+	}
+	return slog.Default()
+}
+
+// WithSource reads the prayers from source instead of the API. The options
+// for the API client don't apply to it.
+func WithSource(source prayerdb.PrayerSource) Option {
+	return func(s *Scraper) error {
+		s.source = source
+		return nil
+	}
+}
+
+// WithExtraSources reads the languages the source doesn't have from
+// sources, in order, like text files or another API
+func WithExtraSources(sources ...prayerdb.PrayerSource) Option {
+	return func(s *Scraper) error {
+		s.extraSources = append(s.extraSources, sources...)
+		return nil
+	}
+}
+
+// WithSinks stores the prayers in sinks, in order, instead of only the
+// per-language SQLite database
+func WithSinks(sinks ...prayerdb.PrayerSink) Option {
+	return func(s *Scraper) error {
+		s.sinks = sinks
+		return nil
+	}
+}
+
+// WithLogger logs to logger instead of slog's default logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Scraper) error {
+		s.logger = logger
+		return nil
+	}
+}
+
+// WithHTTPClient makes the requests to the API with client instead of
+// http.DefaultClient, e.g. to instrument, proxy or record them. The cache,
+// retries and rate limit wrap its transport.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Scraper) error {
+		s.httpClient = client
+		return nil
+	}
+}
+
+// WithMiddleware wraps the requests to the API in middleware, outside the
+// cache, retries and rate limit, e.g. to count them and their cache hits
+func WithMiddleware(middleware ...bpnet.Middleware) Option {
+	return func(s *Scraper) error {
+		s.middleware = append(s.middleware, middleware...)
+		return nil
+	}
+}
+
+// WithBaseURL makes requests to the API at baseURL instead of bpnet.BaseURL
+func WithBaseURL(baseURL string) Option {
+	return func(s *Scraper) error {
+		s.baseURL = baseURL
+		return nil
+	}
+}
+
+// WithCacheDir keeps the responses of the API in dir, and answers from them
+// instead of the API when they're there. Delete the directory to scrape
+// afresh.
+func WithCacheDir(dir string) Option {
+	return func(s *Scraper) error {
+		s.cacheDir = dir
+		return nil
+	}
+}
+
+// WithRateLimit spaces the requests to the API at least every apart
+func WithRateLimit(every time.Duration) Option {
+	return func(s *Scraper) error {
+		if every < 0 {
+			return fmt.Errorf("invalid rate limit %v", every)
+		}
+		s.rateLimit = every
+		return nil
+	}
+}
+
+// WithRetries makes up to attempts tries of a failed request to the API
+func WithRetries(attempts int) Option {
+	return func(s *Scraper) error {
+		if attempts < 1 {
+			return fmt.Errorf("invalid number of attempts %d", attempts)
+		}
+		s.retries = attempts
+		return nil
+	}
+}
+
+// WithStrictDecoding fails the requests to the API whose responses have
+// fields the client doesn't know, naming the fields, so new or renamed ones
+// are noticed before they silently drop data
+func WithStrictDecoding(on bool) Option {
+	return func(s *Scraper) error {
+		s.strictAPI = on
+		return nil
+	}
+}
+
+// WithConfig sets the markup settings of the languages
+func WithConfig(config Config) Option {
+	return func(s *Scraper) error {
+		s.config = config
+		return nil
+	}
+}
+
+// WithTemplates overrides the default markup with the templates in dir
+func WithTemplates(dir string) Option {
+	return func(s *Scraper) error {
+		s.templatesDir = dir
+		return nil
+	}
+}
+
+// WithMarkupFormat marks up the prayers as MarkupHTML or MarkupMarkdown
+func WithMarkupFormat(format string) Option {
+	return func(s *Scraper) error {
+		if format != MarkupHTML && format != MarkupMarkdown {
+			return fmt.Errorf("unknown markup format '%s'", format)
+		}
+		s.format = format
+		return nil
+	}
+}
+
+// WithOpeningLength sets the most runes the opening words of a prayer can
+// have, for the languages whose config doesn't
+func WithOpeningLength(n int) Option {
+	return func(s *Scraper) error {
+		if n < 1 {
+			return fmt.Errorf("invalid opening length %d", n)
+		}
+		s.openingLength = n
+		return nil
+	}
+}
+
+// WithWorkers sets how many prayers are marked up at once. It defaults to
+// runtime.GOMAXPROCS.
+func WithWorkers(n int) Option {
+	return func(s *Scraper) error {
+		if n < 1 {
+			return fmt.Errorf("invalid number of workers %d", n)
+		}
+		s.workers = n
+		return nil
+	}
+}
+
+// WithTranslations names the categories with t instead of
+// prayerdb.DefaultTranslations
+func WithTranslations(t prayerdb.Translations) Option {
+	return func(s *Scraper) error {
+		s.translations = t
+		return nil
+	}
+}
+
+// WithTypography cleans up the quotes, ellipses, dashes and spacing of the
+// text before it's marked up
+func WithTypography(on bool) Option {
+	return func(s *Scraper) error {
+		s.typography = on
+		return nil
+	}
+}
+
+// WithMarkerLint logs the malformed paragraph markers in the text, and with
+// repair fixes the ones it can
+func WithMarkerLint(on, repair bool) Option {
+	return func(s *Scraper) error {
+		s.lintMarkers = on || repair
+		s.repairMarkers = repair
+		return nil
+	}
+}
+
+// WithStrictHTML fails the scrape when the HTML of a prayer is invalid,
+// instead of only logging it
+func WithStrictHTML(on bool) Option {
+	return func(s *Scraper) error {
+		s.strictHTML = on
+		return nil
+	}
+}
+
+// WithStrictAuthors fails the scrape of a language when any of its prayers
+// is by an author without a name in the language, instead of only logging
+// the authors and storing the prayers without one
+func WithStrictAuthors(on bool) Option {
+	return func(s *Scraper) error {
+		s.strictAuthors = on
+		return nil
+	}
+}
+
+// workerCount is how many prayers are marked up at once
+func (s *Scraper) workerCount() int {
+	if s.workers > 0 {
+		return s.workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
@@ -0,0 +1,171 @@
+package scraper
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// goldenCorpus holds the tricky prayers the markup is checked against
+//
+//go:embed testdata/markup
+var goldenCorpus embed.FS
+
+// GoldenCorpus is the built in corpus of GoldenCases, in testdata/markup
+var GoldenCorpus, _ = fs.Sub(goldenCorpus, "testdata/markup")
+
+// GoldenCase is a raw prayer of the corpus, along with the markup it should
+// get. A case is a pair of files: <name>.txt, with "key: value" headers
+// followed by a blank line and the text as the API would send it, and
+// <name>.html, with what the prayer should render to. The headers are:
+//
+//	language:   the ISO code of the prayer's language (default en)
+//	tag:        the kind of its tag, and the name after it (default GENERAL),
+//	            or none for a prayer without tags
+//	typography: on to clean up its typography even when the Scraper doesn't
+type GoldenCase struct {
+	Name       string
+	Language   bpnet.Language
+	Prayer     bpnet.Prayer
+	Typography bool
+	// Golden is the expected rendering, empty when there's none yet
+	Golden string
+}
+
+// LoadGoldenCases reads the cases of a corpus, sorted by name
+func LoadGoldenCases(fsys fs.FS) ([]GoldenCase, error) {
+	paths, err := fs.Glob(fsys, "*.txt")
+	if err != nil {
+		return nil, err
+	}
+	var cases []GoldenCase
+	for i, p := range paths {
+		raw, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(path.Base(p), ".txt")
+		c := GoldenCase{
+			Name:     name,
+			Language: bpnet.Language{ISOName: "en", LeftToRight: true},
+			Prayer:   bpnet.Prayer{ID: i + 1, Tags: []bpnet.Tag{{Kind: bpnet.TagKindGeneral, Name: "General"}}},
+		}
+		headers, text, _ := strings.Cut(strings.ReplaceAll(string(raw), "\r\n", "\n"), "\n\n")
+		for _, line := range strings.Split(headers, "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("%s: malformed header '%s'", p, line)
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "language":
+				c.Language.ISOName = value
+				c.Language.LeftToRight = !bpnet.RightToLeft(value)
+			case "typography":
+				c.Typography = value == "on"
+			case "tag":
+				if value == "none" {
+					c.Prayer.Tags = nil
+					break
+				}
+				kind, tagName, _ := strings.Cut(value, " ")
+				c.Prayer.Tags = []bpnet.Tag{{Kind: kind, Name: strings.TrimSpace(tagName)}}
+			default:
+				return nil, fmt.Errorf("%s: unknown header '%s'", p, key)
+			}
+		}
+		c.Prayer.Text = text
+		c.Prayer.LanguageID = c.Language.ID
+		if len(c.Prayer.Tags) > 0 {
+			c.Prayer.FirstTagName = c.Prayer.Tags[0].Name
+		}
+
+		golden, err := fs.ReadFile(fsys, name+".html")
+		if err == nil {
+			c.Golden = string(golden)
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// RenderGolden marks up the prayer of a case the way a scrape would, and
+// returns it as it's compared with the golden file: its opening words, its
+// HTML and its citation
+func (s *Scraper) RenderGolden(c GoldenCase) (string, error) {
+	scrape := prayerdb.NewScrape(&bpnet.PrayersResponse{Prayers: []bpnet.Prayer{c.Prayer}})
+	s.PrepareText(scrape, c.Language)
+	if c.Typography && !s.typography {
+		s.typeset(scrape, c.Language)
+	}
+	if err := prayerdb.Categorize(scrape, c.Language, s.translations); err != nil {
+		return "", err
+	}
+	if err := s.markupPrayers(scrape, c.Language); err != nil {
+		return "", err
+	}
+	prayer := scrape.Prayers[0]
+	var out strings.Builder
+	fmt.Fprintf(&out, "<!-- opening words: %s -->\n", prayer.OpeningWords)
+	out.WriteString(prayer.PrayerText)
+	out.WriteString("\n")
+	if prayer.Citation != "" {
+		fmt.Fprintf(&out, "<!-- citation -->\n%s\n", prayer.Citation)
+	}
+	return out.String(), nil
+}
+
+// GoldenMismatch is a case whose markup isn't what its golden file says
+type GoldenMismatch struct {
+	Name      string
+	Want, Got string
+}
+
+// Diff lists the lines where the markup differs from the golden file, the
+// wanted ones marked with '-' and the ones gotten with '+'
+func (m GoldenMismatch) Diff() string {
+	wantLines := strings.Split(m.Want, "\n")
+	gotLines := strings.Split(m.Got, "\n")
+	var diff strings.Builder
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			fmt.Fprintf(&diff, "-%s\n+%s\n", w, g)
+		}
+	}
+	return diff.String()
+}
+
+// SelfTest marks up every case of the corpus, returning the ones that don't
+// match their golden files. A case without a golden file is a mismatch.
+func (s *Scraper) SelfTest(fsys fs.FS) ([]GoldenMismatch, error) {
+	cases, err := LoadGoldenCases(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("the corpus has no cases")
+	}
+	var mismatches []GoldenMismatch
+	for _, c := range cases {
+		got, err := s.RenderGolden(c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		if got != c.Golden {
+			mismatches = append(mismatches, GoldenMismatch{Name: c.Name, Want: c.Golden, Got: got})
+		}
+	}
+	return mismatches, nil
+}
@@ -0,0 +1,26 @@
+package scraper
+
+import (
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/markup"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// quotes returns the quotation marks the language uses
+func (s *Scraper) quotes(l bpnet.Language) markup.Quotes {
+	if q := s.config.language(l.ISOName).Quotes; len(q) == 4 {
+		return markup.Quotes{q[0], q[1], q[2], q[3]}
+	}
+	return markup.LanguageQuotes(l.ISOName)
+}
+
+// typeset cleans up the typography of the prayers' text and titles before
+// they're marked up, so every language ships with consistent typography.
+func (s *Scraper) typeset(scrape *prayerdb.Scrape, lang bpnet.Language) {
+	q := s.quotes(lang)
+	for i := range scrape.Prayers {
+		prayer := &scrape.Prayers[i]
+		prayer.Text = markup.Typeset(prayer.Text, q)
+		prayer.Title = markup.Typeset(prayer.Title, q)
+	}
+}
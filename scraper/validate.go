@@ -0,0 +1,27 @@
+package scraper
+
+import (
+	"fmt"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// validate checks the HTML of every prayer, logging the prayers that have
+// problems. With strict HTML, any problem is returned as an error.
+func (s *Scraper) validate(scrape *prayerdb.Scrape, lang bpnet.Language) error {
+	invalid := 0
+	for _, prayer := range scrape.Prayers {
+		problems := s.renderer.Validate(prayer.PrayerText)
+		for _, p := range problems {
+			s.log().Warn("invalid HTML", "phase", "validate", "language", lang.ISOName, "prayer", prayer.ID, "problem", p)
+		}
+		if len(problems) > 0 {
+			invalid++
+		}
+	}
+	if invalid > 0 && s.strictHTML {
+		return fmt.Errorf("%d prayers have invalid HTML", invalid)
+	}
+	return nil
+}
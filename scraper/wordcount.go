@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"context"
+	"os"
+
+	"arashpayan.com/bpnet-scraper/bpnet"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+)
+
+// wordCountRatio is how many times more, or fewer, words a prayer can have
+// than its English counterpart before its word count is implausible
+const wordCountRatio = 3.0
+
+// englishDB is the database of the English prayers, which the word counts
+// of their counterparts are compared with
+const englishDB = "en.db"
+
+// checkWordCounts logs the prayers whose word count is implausible: the ones
+// without any words, which usually means stripping their HTML went wrong,
+// and the ones with wordCountRatio times more or fewer words than their
+// English counterpart, when the config names it and en.db has it. It
+// returns how many prayers it logged.
+func (s *Scraper) checkWordCounts(ctx context.Context, scrape *prayerdb.Scrape, lang bpnet.Language) (int, error) {
+	counterparts := s.config.language(lang.ISOName).Counterparts
+	var english map[int]int
+	if len(counterparts) > 0 && lang.ISOName != "en" {
+		if _, err := os.Stat(englishDB); err == nil {
+			var err error
+			if english, err = prayerdb.WordCounts(ctx, englishDB); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	implausible := 0
+	for _, prayer := range scrape.Prayers {
+		count := prayerdb.WordCount(prayer.PrayerText)
+		if count == 0 {
+			s.log().Warn("prayer has no words", "phase", "validate", "language", lang.ISOName, "prayer", prayer.ID)
+			implausible++
+			continue
+		}
+		englishID, ok := counterparts[prayer.ID]
+		if !ok {
+			continue
+		}
+		englishCount, ok := english[englishID]
+		if !ok || englishCount == 0 {
+			continue
+		}
+		ratio := float64(count) / float64(englishCount)
+		if ratio > wordCountRatio || ratio < 1/wordCountRatio {
+			s.log().Warn("implausible word count", "phase", "validate", "language", lang.ISOName, "prayer", prayer.ID, "words", count, "english", englishID, "englishWords", englishCount)
+			implausible++
+		}
+	}
+	return implausible, nil
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"arashpayan.com/bpnet-scraper/markup"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+	"arashpayan.com/bpnet-scraper/prayerpb"
+)
+
+// searchLimit is how many prayers /search responds with at most, unless
+// asked for fewer
+const searchLimit = 50
+
+// API serves the prayers of merged.db as JSON and over gRPC, for clients
+// that would rather not bundle the database. Its zero value is ready to use.
+type API struct {
+	mu sync.Mutex
+	db *sql.DB
+	// info is of the merged.db db has open, to tell when a rebuild has
+	// replaced it
+	info os.FileInfo
+	// users counts the requests using each open database, so one that's
+	// been replaced is closed once the last of them is done with it
+	users map[*sql.DB]int
+}
+
+// apiLanguage is an entry of /languages
+type apiLanguage struct {
+	Language   string        `json:"language"`
+	Prayers    int           `json:"prayers"`
+	Categories []apiCategory `json:"categories"`
+}
+
+type apiCategory struct {
+	Name    string `json:"name"`
+	Prayers int    `json:"prayers"`
+}
+
+// apiListing is a prayer of /prayers and /search, which /prayers/<id> has
+// the rest of
+type apiListing struct {
+	ID           int    `json:"id"`
+	Language     string `json:"language"`
+	Category     string `json:"category"`
+	OpeningWords string `json:"openingWords"`
+	Author       string `json:"author"`
+	WordCount    int    `json:"wordCount"`
+	URL          string `json:"url"`
+}
+
+// apiPrayer is what /prayers/<id> responds with
+type apiPrayer struct {
+	apiListing
+	PrayerText      string            `json:"prayerText"`
+	PlainText       string            `json:"plainText"`
+	Citation        string            `json:"citation"`
+	AuthorID        int               `json:"authorId"`
+	HasInstructions bool              `json:"hasInstructions"`
+	Footnotes       []markup.Footnote `json:"footnotes"`
+	DuplicateOf     int               `json:"duplicateOf,omitempty"`
+	CreatedAt       string            `json:"createdAt"`
+	UpdatedAt       string            `json:"updatedAt"`
+	Source          string            `json:"source"`
+}
+
+// apiError is the body of the API's error responses
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Register adds the API's endpoints to mux, along with the gRPC service
+// and its schema
+func (a *API) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/languages", a.languages)
+	mux.HandleFunc("/prayers", a.prayers)
+	mux.HandleFunc("/prayers/", a.prayer)
+	mux.HandleFunc("/search", a.search)
+	mux.HandleFunc(grpcService, a.grpc)
+	mux.HandleFunc("/prayers.proto", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, prayerpb.Schema)
+	})
+}
+
+// open returns the database to answer from, reopening merged.db once a
+// rebuild has renamed a new one into place, and a func to call when the
+// request is done with it. The old database is closed once the requests
+// using it have released it.
+func (a *API) open() (*sql.DB, func(), error) {
+	info, err := os.Stat("merged.db")
+	if err != nil {
+		return nil, nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.db == nil || !os.SameFile(a.info, info) {
+		db, err := sql.Open("sqlite3", "merged.db")
+		if err != nil {
+			return nil, nil, err
+		}
+		if a.db != nil && a.users[a.db] == 0 {
+			a.db.Close()
+		}
+		a.db, a.info = db, info
+	}
+	if a.users == nil {
+		a.users = make(map[*sql.DB]int)
+	}
+	db := a.db
+	a.users[db]++
+	return db, func() { a.release(db) }, nil
+}
+
+// release is called when a request is done with db, closing it if it's the
+// last one using it and merged.db has since been replaced
+func (a *API) release(db *sql.DB) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.users[db]--
+	if a.users[db] > 0 {
+		return
+	}
+	delete(a.users, db)
+	if db != a.db {
+		db.Close()
+	}
+}
+
+// languages lists the languages with how many prayers each has in each
+// category
+func (a *API) languages(w http.ResponseWriter, r *http.Request) {
+	db, release, ok := a.database(w)
+	if !ok {
+		return
+	}
+	defer release()
+	langs, err := prayerdb.Catalog(r.Context(), db)
+	if err != nil {
+		a.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	response := []apiLanguage{}
+	for _, l := range langs {
+		lang := apiLanguage{Language: l.Language, Prayers: l.Prayers}
+		for _, c := range l.Categories {
+			lang.Categories = append(lang.Categories, apiCategory{Name: c.Name, Prayers: c.Count})
+		}
+		response = append(response, lang)
+	}
+	writeJSON(w, response)
+}
+
+// prayers lists the prayers, optionally only those of ?language= and
+// ?category=
+func (a *API) prayers(w http.ResponseWriter, r *http.Request) {
+	db, release, ok := a.database(w)
+	if !ok {
+		return
+	}
+	defer release()
+	query := r.URL.Query()
+	listings, err := prayerdb.ListPrayers(r.Context(), db, query.Get("language"), query.Get("category"))
+	if err != nil {
+		a.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, apiListings(listings))
+}
+
+// prayer responds with the prayer of /prayers/<id>
+func (a *API) prayer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/prayers/"))
+	if err != nil {
+		a.fail(w, http.StatusNotFound, errors.New("no such prayer"))
+		return
+	}
+	db, release, ok := a.database(w)
+	if !ok {
+		return
+	}
+	defer release()
+	p, err := prayerdb.GetPrayer(r.Context(), db, id)
+	if errors.Is(err, prayerdb.ErrPrayerNotFound) {
+		a.fail(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		a.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	footnotes := p.Footnotes
+	if footnotes == nil {
+		footnotes = []markup.Footnote{}
+	}
+	writeJSON(w, apiPrayer{
+		apiListing:      apiListingOf(p.PrayerListing),
+		PrayerText:      p.PrayerText,
+		PlainText:       p.PlainText,
+		Citation:        p.Citation,
+		AuthorID:        p.AuthorID,
+		HasInstructions: p.HasInstructions,
+		Footnotes:       footnotes,
+		DuplicateOf:     p.DuplicateOf,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		Source:          p.Source,
+	})
+}
+
+// search finds the prayers with every word of ?q=, optionally only those of
+// ?language=, up to ?limit= or searchLimit of them
+func (a *API) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if strings.TrimSpace(query.Get("q")) == "" {
+		a.fail(w, http.StatusBadRequest, errors.New("search needs a query in q"))
+		return
+	}
+	limit := searchLimit
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			a.fail(w, http.StatusBadRequest, errors.New("limit has to be a positive number"))
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+	db, release, ok := a.database(w)
+	if !ok {
+		return
+	}
+	defer release()
+	listings, err := prayerdb.SearchPrayers(r.Context(), db, query.Get("q"), query.Get("language"), limit)
+	if err != nil {
+		a.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, apiListings(listings))
+}
+
+// database opens the database for a request, responding with an error when
+// there's none yet. The request has to call release when it's done with it.
+func (a *API) database(w http.ResponseWriter) (*sql.DB, func(), bool) {
+	db, release, err := a.open()
+	if err != nil {
+		a.fail(w, http.StatusServiceUnavailable, errors.New("merged.db isn't built yet"))
+		return nil, nil, false
+	}
+	return db, release, true
+}
+
+// fail responds with err as a JSON error, logging the ones that aren't the
+// client's fault
+func (a *API) fail(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		logger().Error("unable to respond", "phase", "serve", "error", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSON(w, apiError{Error: err.Error()})
+}
+
+func apiListings(listings []prayerdb.PrayerListing) []apiListing {
+	response := make([]apiListing, 0, len(listings))
+	for _, l := range listings {
+		response = append(response, apiListingOf(l))
+	}
+	return response
+}
+
+func apiListingOf(l prayerdb.PrayerListing) apiListing {
+	return apiListing{
+		ID:           l.ID,
+		Language:     l.Language,
+		Category:     l.Category,
+		OpeningWords: l.OpeningWords,
+		Author:       l.Author,
+		WordCount:    l.WordCount,
+		URL:          "/prayers/" + strconv.Itoa(l.ID),
+	}
+}
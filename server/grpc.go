@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"arashpayan.com/bpnet-scraper/export"
+	"arashpayan.com/bpnet-scraper/prayerdb"
+	"arashpayan.com/bpnet-scraper/prayerpb"
+)
+
+// grpcService is the path prefix of the methods of the PrayerService in
+// prayerpb.Schema
+const grpcService = "/bpnet.prayers.v1.PrayerService/"
+
+// grpcMaxMessage is the largest request message the service reads, which is
+// gRPC's default
+const grpcMaxMessage = 4 << 20
+
+// The gRPC status codes the service responds with
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcNotFound          = 5
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+)
+
+// grpcError is an error with the gRPC status to respond with
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (e grpcError) Error() string {
+	return e.message
+}
+
+// grpcMethod answers a request message of the PrayerService
+type grpcMethod func(ctx context.Context, db *sql.DB, req prayerpb.Fields) (prayerpb.Message, error)
+
+var grpcMethods = map[string]grpcMethod{
+	"ListLanguages": grpcListLanguages,
+	"ListPrayers":   grpcListPrayers,
+	"GetPrayer":     grpcGetPrayer,
+	"Search":        grpcSearch,
+}
+
+// grpc serves the unary methods of the PrayerService, the same queries as
+// the JSON API, for clients that would rather have generated types. gRPC
+// needs HTTP/2, which Server accepts without TLS too.
+func (a *API) grpc(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	resp, err := a.grpcCall(r)
+	if err == nil {
+		frame := make([]byte, 5, 5+len(resp))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(resp)))
+		w.Write(append(frame, resp...))
+	}
+
+	status := grpcError{code: grpcOK}
+	if err != nil && !errors.As(err, &status) {
+		logger().Error("unable to respond", "phase", "serve", "method", r.URL.Path, "error", err)
+		status = grpcError{code: grpcInternal, message: err.Error()}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(status.code))
+	if status.message != "" {
+		w.Header().Set("Grpc-Message", grpcEscape(status.message))
+	}
+}
+
+// grpcCall reads the request message and runs the method it's for
+func (a *API) grpcCall(r *http.Request) (prayerpb.Message, error) {
+	method, ok := grpcMethods[strings.TrimPrefix(r.URL.Path, grpcService)]
+	if !ok {
+		return nil, grpcError{grpcUnimplemented, "unknown method " + r.URL.Path}
+	}
+
+	var header [5]byte
+	if _, err := io.ReadFull(r.Body, header[:]); err != nil {
+		return nil, grpcError{grpcInvalidArgument, "no request message"}
+	}
+	if header[0] != 0 {
+		return nil, grpcError{grpcUnimplemented, "compressed messages aren't supported"}
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > grpcMaxMessage {
+		return nil, grpcError{grpcResourceExhausted, fmt.Sprintf("the request message is larger than %d bytes", grpcMaxMessage)}
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r.Body, buf); err != nil {
+		return nil, grpcError{grpcInvalidArgument, "the request message is cut off"}
+	}
+	req, err := prayerpb.Decode(buf)
+	if err != nil {
+		return nil, grpcError{grpcInvalidArgument, err.Error()}
+	}
+
+	db, release, err := a.open()
+	if err != nil {
+		return nil, grpcError{grpcUnavailable, "merged.db isn't built yet"}
+	}
+	defer release()
+	return method(r.Context(), db, req)
+}
+
+func grpcListLanguages(ctx context.Context, db *sql.DB, req prayerpb.Fields) (prayerpb.Message, error) {
+	langs, err := prayerdb.Catalog(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var resp prayerpb.Message
+	for _, l := range langs {
+		var lang prayerpb.Message
+		lang.String(1, l.Language)
+		lang.Int32(2, l.Prayers)
+		for _, c := range l.Categories {
+			var category prayerpb.Message
+			category.String(1, c.Name)
+			category.Int32(2, c.Count)
+			lang.Embed(3, category)
+		}
+		resp.Embed(1, lang)
+	}
+	return resp, nil
+}
+
+func grpcListPrayers(ctx context.Context, db *sql.DB, req prayerpb.Fields) (prayerpb.Message, error) {
+	listings, err := prayerdb.ListPrayers(ctx, db, req.String(1), req.String(2))
+	if err != nil {
+		return nil, err
+	}
+	return protoListings(listings), nil
+}
+
+func grpcGetPrayer(ctx context.Context, db *sql.DB, req prayerpb.Fields) (prayerpb.Message, error) {
+	p, err := prayerdb.GetPrayer(ctx, db, req.Int32(1))
+	if errors.Is(err, prayerdb.ErrPrayerNotFound) {
+		return nil, grpcError{grpcNotFound, err.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp prayerpb.Message
+	resp.String(1, p.Language)
+	resp.Embed(2, export.ProtoPrayer(p.Category, export.Prayer{
+		ID:              p.ID,
+		OpeningWords:    p.OpeningWords,
+		PrayerText:      p.PrayerText,
+		PlainText:       p.PlainText,
+		Citation:        p.Citation,
+		Author:          p.Author,
+		AuthorID:        p.AuthorID,
+		WordCount:       p.WordCount,
+		HasInstructions: p.HasInstructions,
+		Footnotes:       p.Footnotes,
+		DuplicateOf:     p.DuplicateOf,
+		ContentHash:     p.ContentHash,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+	}, p.TagIDs))
+	return resp, nil
+}
+
+func grpcSearch(ctx context.Context, db *sql.DB, req prayerpb.Fields) (prayerpb.Message, error) {
+	query := req.String(1)
+	if strings.TrimSpace(query) == "" {
+		return nil, grpcError{grpcInvalidArgument, "search needs a query"}
+	}
+	limit := req.Int32(3)
+	if limit < 1 || limit > searchLimit {
+		limit = searchLimit
+	}
+	listings, err := prayerdb.SearchPrayers(ctx, db, query, req.String(2), limit)
+	if err != nil {
+		return nil, err
+	}
+	return protoListings(listings), nil
+}
+
+// protoListings encodes a ListPrayersResponse
+func protoListings(listings []prayerdb.PrayerListing) prayerpb.Message {
+	var resp prayerpb.Message
+	for _, l := range listings {
+		var listing prayerpb.Message
+		listing.Int32(1, l.ID)
+		listing.String(2, l.Language)
+		listing.String(3, l.Category)
+		listing.String(4, l.OpeningWords)
+		listing.String(5, l.Author)
+		listing.Int32(6, l.WordCount)
+		resp.Embed(1, listing)
+	}
+	return resp
+}
+
+// grpcEscape percent-encodes a status message for the Grpc-Message trailer
+func grpcEscape(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"arashpayan.com/bpnet-scraper/prayerdb"
+	"arashpayan.com/bpnet-scraper/prayerpb"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// inMergedDir runs the test in a directory with a merged.db of one prayer
+func inMergedDir(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", dir+"/merged.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := prayerdb.CreateMerged(context.Background(), db, false); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO prayers VALUES (1, 'General', '<p>O God!</p>', 'O God!', '', 'The Báb', 1, 'en', 2, 'o god', x'00', 'O God!', '[]', 'O God!', 0, 0, '', '2020-01-01', '2020-01-01', 0, '')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inDir(t, dir)
+}
+
+// inDir runs the test in dir, since the API serves the merged.db of the
+// current directory
+func inDir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// grpcFrame frames a request message, with flags as its first byte and size
+// as its length
+func grpcFrame(flags byte, size int, message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(size))
+	return append(frame, message...)
+}
+
+func TestGRPC(t *testing.T) {
+	inMergedDir(t)
+	cases := []struct {
+		name, method string
+		body         []byte
+		code         int
+		message      string
+		// language is what the response has in languageField of its first
+		// embedded message, or in its own field 1 when that's 0
+		language      string
+		languageField int
+	}{
+		{"list languages", "ListLanguages", grpcFrame(0, 0, nil), grpcOK, "", "en", 1},
+		{"list prayers", "ListPrayers", grpcFrame(0, 4, []byte{0x0a, 0x02, 'e', 'n'}), grpcOK, "", "en", 2},
+		{"get prayer", "GetPrayer", grpcFrame(0, 2, []byte{0x08, 0x01}), grpcOK, "", "en", 0},
+		{"missing prayer", "GetPrayer", grpcFrame(0, 2, []byte{0x08, 0x63}), grpcNotFound, "prayer not found", "", 0},
+		{"search without a query", "Search", grpcFrame(0, 0, nil), grpcInvalidArgument, "search needs a query", "", 0},
+		{"unknown method", "Pray", grpcFrame(0, 0, nil), grpcUnimplemented, "unknown method " + grpcService + "Pray", "", 0},
+		{"no request message", "ListLanguages", nil, grpcInvalidArgument, "no request message", "", 0},
+		{"cut off header", "ListLanguages", []byte{0, 0, 0}, grpcInvalidArgument, "no request message", "", 0},
+		{"cut off message", "GetPrayer", grpcFrame(0, 10, []byte{0x08, 0x01}), grpcInvalidArgument, "the request message is cut off", "", 0},
+		{"compressed", "ListLanguages", grpcFrame(1, 0, nil), grpcUnimplemented, "compressed messages aren't supported", "", 0},
+		{"too large", "ListLanguages", grpcFrame(0, grpcMaxMessage+1, nil), grpcResourceExhausted, "the request message is larger than 4194304 bytes", "", 0},
+		{"malformed", "GetPrayer", grpcFrame(0, 2, []byte{0x08, 0x96}), grpcInvalidArgument, "malformed request message", "", 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, grpcService+c.method, bytes.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/grpc")
+			rec := httptest.NewRecorder()
+			(&API{}).grpc(rec, req)
+			resp := rec.Result()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("the HTTP status is %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if got := resp.Trailer.Get("Grpc-Status"); got != strconv.Itoa(c.code) {
+				t.Errorf("the grpc-status trailer is %q, want %d", got, c.code)
+			}
+			if got := resp.Trailer.Get("Grpc-Message"); got != c.message {
+				t.Errorf("the grpc-message trailer is %q, want %q", got, c.message)
+			}
+
+			body := rec.Body.Bytes()
+			if c.code != grpcOK {
+				if len(body) != 0 {
+					t.Errorf("the failed call responded with % x, want no message", body)
+				}
+				return
+			}
+			if len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+				t.Fatalf("the response % x isn't a single uncompressed message", body)
+			}
+			fields, err := prayerpb.Decode(body[5:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			language := fields.String(1)
+			if c.languageField != 0 {
+				first, err := prayerpb.Decode([]byte(fields.String(1)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				language = first.String(c.languageField)
+			}
+			if language != c.language {
+				t.Errorf("the response is for %q, want %q", language, c.language)
+			}
+		})
+	}
+}
+
+func TestGRPCWithoutMergedDB(t *testing.T) {
+	inDir(t, t.TempDir())
+	req := httptest.NewRequest(http.MethodPost, grpcService+"ListLanguages", bytes.NewReader(grpcFrame(0, 0, nil)))
+	req.Header.Set("Content-Type", "application/grpc")
+	rec := httptest.NewRecorder()
+	(&API{}).grpc(rec, req)
+	if got := rec.Result().Trailer.Get("Grpc-Status"); got != strconv.Itoa(grpcUnavailable) {
+		t.Errorf("the grpc-status trailer is %q, want %d", got, grpcUnavailable)
+	}
+}
+
+func TestGRPCRejectsOtherRequests(t *testing.T) {
+	cases := []struct {
+		name, method, contentType string
+	}{
+		{"GET", http.MethodGet, "application/grpc"},
+		{"JSON", http.MethodPost, "application/json"},
+		{"no content type", http.MethodPost, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, grpcService+"ListLanguages", nil)
+			if c.contentType != "" {
+				req.Header.Set("Content-Type", c.contentType)
+			}
+			rec := httptest.NewRecorder()
+			(&API{}).grpc(rec, req)
+			if rec.Code != http.StatusUnsupportedMediaType {
+				t.Errorf("the HTTP status is %d, want %d", rec.Code, http.StatusUnsupportedMediaType)
+			}
+		})
+	}
+}
+
+func TestGRPCEscape(t *testing.T) {
+	cases := []struct {
+		name, message, want string
+	}{
+		{"plain", "search needs a query", "search needs a query"},
+		{"printable punctuation", "it's ~ {ok}", "it's ~ {ok}"},
+		{"percent", "100% done", "100%25 done"},
+		{"newline", "line one\nline two", "line one%0Aline two"},
+		{"tab", "a\tb", "a%09b"},
+		{"delete", "a\x7fb", "a%7Fb"},
+		{"UTF-8", "naïve", "na%C3%AFve"},
+		{"empty", "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := grpcEscape(c.message); got != c.want {
+				t.Errorf("grpcEscape(%q) = %q, want %q", c.message, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package server
+
+import "log/slog"
+
+// Logger gets what the package logs, with the phase a message is about as
+// an attribute. When nil, slog.Default() is used.
+var Logger *slog.Logger
+
+func logger() *slog.Logger {
+	if Logger != nil {
+		return Logger
+	}
+	return slog.Default()
+}
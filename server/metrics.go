@@ -0,0 +1,197 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the buckets of the
+// latency histograms, Prometheus' defaults
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PromWriter writes metrics in Prometheus' text exposition format
+type PromWriter struct {
+	strings.Builder
+}
+
+// Family starts the samples of a metric with its help and type
+func (m *PromWriter) Family(name, kind, help string) {
+	fmt.Fprintf(m, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// Sample writes a sample of a metric, with labels as PromLabels makes them
+func (m *PromWriter) Sample(name, labels string, v float64) {
+	fmt.Fprintf(m, "%s%s %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// PromLabels formats name, value pairs as the labels of a sample
+func PromLabels(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var labels []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, pairs[i]+`="`+escaper.Replace(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// histogram counts observations into latencyBuckets
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]int64, len(latencyBuckets))
+	}
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// write writes the samples of the histogram, whose labels are the pairs of
+// PromLabels
+func (h *histogram) write(m *PromWriter, name string, labels ...string) {
+	for i, bound := range latencyBuckets {
+		var count int64
+		if h.counts != nil {
+			count = h.counts[i]
+		}
+		m.Sample(name+"_bucket", PromLabels(append(labels, "le", strconv.FormatFloat(bound, 'g', -1, 64))...), float64(count))
+	}
+	m.Sample(name+"_bucket", PromLabels(append(labels, "le", "+Inf")...), float64(h.count))
+	m.Sample(name+"_sum", PromLabels(labels...), h.sum)
+	m.Sample(name+"_count", PromLabels(labels...), float64(h.count))
+}
+
+// serverMetrics are the metrics of the Server itself: its rebuilds, and the
+// latencies of its endpoints
+type serverMetrics struct {
+	mu        sync.Mutex
+	rescrapes map[string]int64
+	duration  histogram
+	// latencies and responses are by endpoint, and responses then by status
+	latencies map[string]*histogram
+	responses map[string]map[int]int64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		rescrapes: make(map[string]int64),
+		latencies: make(map[string]*histogram),
+		responses: make(map[string]map[int]int64),
+	}
+}
+
+// rescraped records a rescrape that took took, and whether it failed
+func (s *serverMetrics) rescraped(took time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rescrapes[result]++
+	s.duration.observe(took.Seconds())
+}
+
+// statusRecorder keeps the status a handler responds with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// instrument times the requests mux handles, by the pattern they match, so
+// the endpoints of the API with IDs in their paths are timed together
+func (s *serverMetrics) instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		mux.ServeHTTP(recorder, r)
+		took := time.Since(started)
+
+		_, endpoint := mux.Handler(r)
+		if endpoint == grpcService {
+			// a method of the gRPC service, if it has one by that name
+			if _, ok := grpcMethods[strings.TrimPrefix(r.URL.Path, grpcService)]; ok {
+				endpoint = r.URL.Path
+			}
+		}
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.latencies[endpoint] == nil {
+			s.latencies[endpoint] = &histogram{}
+			s.responses[endpoint] = make(map[int]int64)
+		}
+		s.latencies[endpoint].observe(took.Seconds())
+		s.responses[endpoint][recorder.status]++
+	})
+}
+
+// write writes the metrics of the Server
+func (s *serverMetrics) write(m *PromWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m.Family("bpnet_scraper_rescrapes_total", "counter", "Rescrapes the daemon ran, by whether they succeeded")
+	for _, result := range []string{"success", "failure"} {
+		m.Sample("bpnet_scraper_rescrapes_total", PromLabels("result", result), float64(s.rescrapes[result]))
+	}
+	m.Family("bpnet_scraper_rescrape_duration_seconds", "histogram", "How long the rescrapes of the daemon took, merging included")
+	s.duration.write(m, "bpnet_scraper_rescrape_duration_seconds")
+
+	endpoints := make([]string, 0, len(s.latencies))
+	for endpoint := range s.latencies {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	m.Family("bpnet_scraper_http_requests_total", "counter", "Requests the daemon answered, by endpoint and status")
+	for _, endpoint := range endpoints {
+		statuses := make([]int, 0, len(s.responses[endpoint]))
+		for status := range s.responses[endpoint] {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			m.Sample("bpnet_scraper_http_requests_total", PromLabels("endpoint", endpoint, "status", strconv.Itoa(status)), float64(s.responses[endpoint][status]))
+		}
+	}
+	m.Family("bpnet_scraper_http_request_duration_seconds", "histogram", "How long the daemon took to answer, by endpoint")
+	for _, endpoint := range endpoints {
+		s.latencies[endpoint].write(m, "bpnet_scraper_http_request_duration_seconds", "endpoint", endpoint)
+	}
+}
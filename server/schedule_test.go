@@ -0,0 +1,92 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	cases := []struct {
+		name, spec, after, want string
+	}{
+		{"hourly", "hourly", "2024-01-01 10:30", "2024-01-01 11:00"},
+		{"strictly after", "0 * * * *", "2024-01-01 10:00", "2024-01-01 11:00"},
+		{"every minute", "* * * * *", "2024-01-01 10:59", "2024-01-01 11:00"},
+		{"daily into the next month", "daily", "2024-01-31 23:59", "2024-02-01 00:00"},
+		{"weekly", "weekly", "2024-01-03 12:00", "2024-01-07 00:00"},
+		{"monthly into the next year", "monthly", "2024-12-15 00:00", "2025-01-01 00:00"},
+		{"interval", "12h", "2024-01-01 10:30", "2024-01-01 22:30"},
+		{"weekday range", "30 3 * * 1-5", "2024-01-05 04:00", "2024-01-08 03:30"},
+		{"weekday range the same day", "30 3 * * 1-5", "2024-01-05 03:00", "2024-01-05 03:30"},
+		{"step", "*/15 * * * *", "2024-01-01 10:16", "2024-01-01 10:30"},
+		{"step into the next hour", "*/15 * * * *", "2024-01-01 10:45", "2024-01-01 11:00"},
+		{"stepped range", "0 8-18/4 * * *", "2024-01-01 12:01", "2024-01-01 16:00"},
+		{"stepped range into the next day", "0 8-18/4 * * *", "2024-01-01 16:01", "2024-01-02 08:00"},
+		{"stepped value", "5/20 * * * *", "2024-01-01 10:26", "2024-01-01 10:45"},
+		{"lists", "0,30 9,17 * * *", "2024-01-01 09:30", "2024-01-01 17:00"},
+		{"list of a range and a value", "0 0 * * 1-2,5", "2024-01-09 00:00", "2024-01-12 00:00"},
+		{"Sunday as 7", "0 0 * * 7", "2024-01-03 00:00", "2024-01-07 00:00"},
+		{"day of month or day of week, the weekday", "0 0 13 * 5", "2024-10-07 00:00", "2024-10-11 00:00"},
+		{"day of month or day of week, the day", "0 0 13 * 5", "2024-10-11 00:00", "2024-10-13 00:00"},
+		{"day of month or day of week, the next weekday", "0 0 13 * 5", "2024-10-13 00:00", "2024-10-18 00:00"},
+		{"day of month only", "0 0 13 * *", "2024-10-07 00:00", "2024-10-13 00:00"},
+		{"day of month past a short month", "0 0 31 * *", "2024-04-01 00:00", "2024-05-31 00:00"},
+		{"leap day", "0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"month", "0 0 1 6 *", "2024-06-02 00:00", "2025-06-01 00:00"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := ParseSchedule(c.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) failed: %v", c.spec, err)
+			}
+			after, err := time.Parse("2006-01-02 15:04", c.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(after).Format("2006-01-02 15:04"); got != c.want {
+				t.Errorf("%q fires after %s at %s, want %s", c.spec, c.after, got, c.want)
+			}
+		})
+	}
+}
+
+func TestScheduleNextSkipsSeconds(t *testing.T) {
+	s, err := ParseSchedule("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Date(2024, 1, 1, 10, 59, 30, 0, time.UTC)
+	if got, want := s.Next(after), time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("every minute fires after %s at %s, want %s", after, got, want)
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	cases := []struct {
+		name, spec string
+	}{
+		{"empty", ""},
+		{"four fields", "0 * * *"},
+		{"six fields", "0 0 * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day of month 0", "0 0 0 * *"},
+		{"month 13", "0 0 * 13 *"},
+		{"day of week 8", "0 0 * * 8"},
+		{"backwards range", "5-1 * * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"bad step", "*/x * * * *"},
+		{"bad value", "a * * * *"},
+		{"bad range end", "1-x * * * *"},
+		{"name of a weekday", "0 0 * * mon"},
+		{"too frequent", "30s"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParseSchedule(c.spec); err == nil {
+				t.Errorf("ParseSchedule(%q) succeeded, want an error", c.spec)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Package server serves the databases of a directory of scraped prayers,
+// and the prayers of its merged.db through a JSON API and a gRPC service,
+// rebuilding them on a schedule:
+//
+//	schedule, err := server.ParseSchedule("daily")
+//	...
+//	s := &server.Server{Schedule: schedule, Rebuild: rebuild}
+//	err = s.ListenAndServe(ctx, ":8080")
+//
+// It serves the databases of the current directory. The API can also be
+// served on its own, with API.Register. merged.db is opened with the
+// "sqlite3" database/sql driver, which the program has to register.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// The extensions of the checksum and the signature of a database, which are
+// served along with it
+const (
+	ChecksumExt  = ".sha256"
+	SignatureExt = ".sig"
+)
+
+// Server rebuilds the databases on a schedule, serves them, and tracks how
+// the rebuilds are going for the status endpoint
+type Server struct {
+	// Schedule is when Rebuild runs. It also runs right away when there's no
+	// merged.db yet.
+	Schedule Schedule
+	// Rebuild rescrapes every language and replaces merged.db, renaming the
+	// new one into place so a request reading the old one can finish. It
+	// returns the languages it couldn't rescrape, which merged.db has as
+	// they were before.
+	Rebuild func(ctx context.Context) ([]string, error)
+	// ScrapeMetrics is a file of metrics in Prometheus' text format, like
+	// those the scrapes of Rebuild write, for /metrics to serve along with
+	// the server's own
+	ScrapeMetrics string
+
+	metrics *serverMetrics
+
+	mu       sync.Mutex
+	running  bool
+	next     time.Time
+	started  time.Time
+	finished time.Time
+	err      error
+	// failed are the languages the last rebuild couldn't rescrape
+	failed []string
+}
+
+// ListenAndServe serves the databases, and the prayers of merged.db
+// through the API, on addr until ctx is done, rebuilding them on the
+// Schedule meanwhile
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	s.metrics = newServerMetrics()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/download/", s.download)
+	mux.HandleFunc("/status", s.status)
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	api := &API{}
+	api.Register(mux)
+	// gRPC clients speak HTTP/2 without TLS
+	server := &http.Server{Addr: addr, Handler: h2c.NewHandler(s.metrics.instrument(mux), &http2.Server{})}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+	go s.run(ctx)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// run rebuilds whenever the schedule fires, and right away when there's no
+// merged.db yet
+func (s *Server) run(ctx context.Context) {
+	if _, err := os.Stat("merged.db"); err != nil {
+		s.rebuild(ctx)
+	}
+	for {
+		next := s.Schedule.Next(time.Now())
+		s.mu.Lock()
+		s.next = next
+		s.mu.Unlock()
+		logger().Info("next rescrape", "phase", "serve", "at", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.rebuild(ctx)
+	}
+}
+
+// rebuild runs Rebuild, keeping track of how it went
+func (s *Server) rebuild(ctx context.Context) {
+	started := time.Now()
+	s.mu.Lock()
+	s.running, s.started = true, started
+	s.mu.Unlock()
+
+	failed, err := s.Rebuild(ctx)
+	s.metrics.rescraped(time.Since(started), err)
+	switch {
+	case err != nil:
+		logger().Error("the rescrape failed", "phase", "serve", "error", err)
+	case len(failed) > 0:
+		logger().Warn("rebuilt merged.db without rescraping some languages", "phase", "serve", "languages", strings.Join(failed, ","))
+	default:
+		logger().Info("rebuilt merged.db", "phase", "serve")
+	}
+
+	s.mu.Lock()
+	s.running, s.finished, s.err, s.failed = false, time.Now(), err, failed
+	s.mu.Unlock()
+}
+
+// artifact is an entry of the download index
+type artifact struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Modified string `json:"modified"`
+	URL      string `json:"url"`
+	// Checksum and Signature are the URLs of the database's checksum and
+	// signature, when it has them
+	Checksum  string `json:"checksum,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// download serves the databases: /download/ lists them, /download/<name>
+// is the database itself, and /download/<name>.sig and .sha256 its
+// signature and checksum
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/download/")
+	if name == "" {
+		s.index(w, r)
+		return
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") || filepath.Ext(strings.TrimSuffix(strings.TrimSuffix(name, SignatureExt), ChecksumExt)) != ".db" {
+		http.NotFound(w, r)
+		return
+	}
+	// merged.db is replaced by renaming, so an open file stays whole
+	f, err := os.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if strings.HasSuffix(name, SignatureExt) || strings.HasSuffix(name, ChecksumExt) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	}
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// index lists the databases that can be downloaded
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	paths, err := filepath.Glob("*.db")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(paths)
+	artifacts := []artifact{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		a := artifact{
+			Name:     path,
+			Size:     info.Size(),
+			Modified: info.ModTime().UTC().Format(time.RFC3339),
+			URL:      "/download/" + path,
+		}
+		if _, err := os.Stat(path + ChecksumExt); err == nil {
+			a.Checksum = a.URL + ChecksumExt
+		}
+		if _, err := os.Stat(path + SignatureExt); err == nil {
+			a.Signature = a.URL + SignatureExt
+		}
+		artifacts = append(artifacts, a)
+	}
+	writeJSON(w, artifacts)
+}
+
+// status is what /status responds with
+type serverStatus struct {
+	Running         bool     `json:"running"`
+	NextRun         string   `json:"nextRun,omitempty"`
+	LastStarted     string   `json:"lastStarted,omitempty"`
+	LastFinished    string   `json:"lastFinished,omitempty"`
+	LastError       string   `json:"lastError,omitempty"`
+	FailedLanguages []string `json:"failedLanguages,omitempty"`
+}
+
+// status reports when the server last rebuilt, how it went, and when it
+// rebuilds next
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := serverStatus{Running: s.running, NextRun: formatTime(s.next), LastStarted: formatTime(s.started), LastFinished: formatTime(s.finished)}
+	if s.err != nil {
+		status.LastError = s.err.Error()
+	}
+	status.FailedLanguages = s.failed
+	s.mu.Unlock()
+	writeJSON(w, status)
+}
+
+// serveMetrics responds with the metrics of the server in Prometheus' text
+// format, followed by those of ScrapeMetrics
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	var m PromWriter
+	s.mu.Lock()
+	running, next := s.running, s.next
+	s.mu.Unlock()
+	m.Family("bpnet_scraper_rescrape_running", "gauge", "Whether the daemon is rescraping")
+	runningValue := 0.0
+	if running {
+		runningValue = 1
+	}
+	m.Sample("bpnet_scraper_rescrape_running", "", runningValue)
+	if !next.IsZero() {
+		m.Family("bpnet_scraper_next_rescrape_timestamp_seconds", "gauge", "When the daemon rescrapes next")
+		m.Sample("bpnet_scraper_next_rescrape_timestamp_seconds", "", float64(next.Unix()))
+	}
+	s.metrics.write(&m)
+	if s.ScrapeMetrics != "" {
+		if scrape, err := ioutil.ReadFile(s.ScrapeMetrics); err == nil {
+			m.Write(scrape)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, m.String())
+}
+
+// formatTime formats t for a JSON response, leaving the zero time out
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeJSON responds with v as JSON, leaving the markup of prayers readable
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logger().Error("unable to write the response", "phase", "serve", "error", err)
+	}
+}
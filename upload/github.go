@@ -0,0 +1,171 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// GitHubAPI is the address of GitHub's REST API
+const GitHubAPI = "https://api.github.com"
+
+// Repository is a GitHub repository that releases are published to
+type Repository struct {
+	// APIURL is the address of the API, GitHubAPI when empty, or that of a
+	// GitHub Enterprise server
+	APIURL string
+	Owner  string
+	Name   string
+	// Token is a token with write access to the repository's contents
+	Token string
+
+	// HTTPClient makes the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// ParseRepository reads a repository named owner/name
+func ParseRepository(fullName string) (*Repository, error) {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid repository '%s', want owner/name", fullName)
+	}
+	return &Repository{Owner: owner, Name: name}, nil
+}
+
+// Release is a release to create
+type Release struct {
+	Tag string
+	// Target is the branch or commit the tag is created at, if it doesn't
+	// exist yet; the default branch when empty
+	Target string
+	Name   string
+	// Notes are the body of the release, in Markdown
+	Notes string
+	// Draft keeps the release from being published until PublishRelease
+	Draft bool
+}
+
+// PublishedRelease is a release GitHub created
+type PublishedRelease struct {
+	ID      int64  `json:"id"`
+	HTMLURL string `json:"html_url"`
+	// UploadURL is where the release's assets are uploaded, a URI template
+	UploadURL string `json:"upload_url"`
+}
+
+// CreateRelease creates a release, and publishes it unless it's a draft.
+// GitHub refuses a tag that already has one.
+func (r *Repository) CreateRelease(ctx context.Context, release Release) (*PublishedRelease, error) {
+	body, err := json.Marshal(struct {
+		Tag    string `json:"tag_name"`
+		Target string `json:"target_commitish,omitempty"`
+		Name   string `json:"name,omitempty"`
+		Notes  string `json:"body"`
+		Draft  bool   `json:"draft"`
+	}{release.Tag, release.Target, release.Name, release.Notes, release.Draft})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.releasesURL(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var published PublishedRelease
+	if err := r.do(req, &published); err != nil {
+		return nil, err
+	}
+	return &published, nil
+}
+
+// PublishRelease publishes a draft release, once its assets are uploaded.
+// Its HTMLURL changes to that of the published release.
+func (r *Repository) PublishRelease(ctx context.Context, release *PublishedRelease) error {
+	endpoint := fmt.Sprintf("%s/%d", r.releasesURL(), release.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, endpoint, strings.NewReader(`{"draft":false}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return r.do(req, release)
+}
+
+// DeleteRelease deletes a release, along with its assets
+func (r *Repository) DeleteRelease(ctx context.Context, release *PublishedRelease) error {
+	endpoint := fmt.Sprintf("%s/%d", r.releasesURL(), release.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return r.do(req, nil)
+}
+
+// releasesURL is the endpoint of the repository's releases
+func (r *Repository) releasesURL() string {
+	apiURL := r.APIURL
+	if apiURL == "" {
+		apiURL = GitHubAPI
+	}
+	return fmt.Sprintf("%s/repos/%s/%s/releases", strings.TrimSuffix(apiURL, "/"), url.PathEscape(r.Owner), url.PathEscape(r.Name))
+}
+
+// UploadAsset attaches the file at filePath to the release as name
+func (r *Repository) UploadAsset(ctx context.Context, release *PublishedRelease, name, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// the upload URL is a template, ending in {?name,label}
+	uploadURL := release.UploadURL
+	if i := strings.Index(uploadURL, "{"); i >= 0 {
+		uploadURL = uploadURL[:i]
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL+"?name="+url.QueryEscape(name), ioutil.NopCloser(f))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", contentType(path.Base(name)))
+	return r.do(req, nil)
+}
+
+// do makes an authenticated request to the API, decoding what it responds
+// with into v unless it's nil
+func (r *Repository) do(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+r.Token)
+
+	client := http.DefaultClient
+	if r.HTTPClient != nil {
+		client = r.HTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
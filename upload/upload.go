@@ -0,0 +1,166 @@
+// Package upload puts the scraper's artifacts into an S3 or Google Cloud
+// Storage bucket. It speaks the S3 API, which GCS also serves with HMAC
+// keys, signing the requests itself so it needs no SDK:
+//
+//	bucket, err := upload.ParseBucket("s3://releases/prayers")
+//	...
+//	bucket.AccessKey, bucket.SecretKey = id, secret
+//	sum, err := bucket.PutFile(ctx, "merged.db", "merged.db")
+//
+// It also publishes them as the assets of a GitHub release:
+//
+//	repo, err := upload.ParseRepository("owner/prayers")
+//	...
+//	repo.Token = token
+//	release, err := repo.CreateRelease(ctx, upload.Release{Tag: "prayers-2024-01-01"})
+//	...
+//	err = repo.UploadAsset(ctx, release, "merged.db", "merged.db")
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// GCSEndpoint is where gs:// buckets are
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// Bucket is where objects are uploaded to
+type Bucket struct {
+	// Endpoint is the address of the object store, with the bucket in the
+	// path. When empty, it's S3 in the Region, with the bucket in the host
+	// name.
+	Endpoint string
+	Region   string
+	Name     string
+	// Prefix is prepended to the keys of the objects, e.g. a directory
+	Prefix string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// HTTPClient makes the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// ParseBucket reads a bucket URL, s3://<bucket>/<prefix> or
+// gs://<bucket>/<prefix>. S3 buckets are in us-east-1 until Region says
+// otherwise.
+func ParseBucket(bucketURL string) (*Bucket, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no bucket in '%s'", bucketURL)
+	}
+	b := &Bucket{Name: u.Host, Prefix: strings.Trim(u.Path, "/")}
+	switch u.Scheme {
+	case "s3":
+		b.Region = "us-east-1"
+	case "gs":
+		b.Endpoint, b.Region = GCSEndpoint, "auto"
+	default:
+		return nil, fmt.Errorf("unknown bucket scheme '%s' (s3 or gs)", u.Scheme)
+	}
+	return b, nil
+}
+
+// HTTPError is returned when the object store or GitHub refuses a request
+type HTTPError struct {
+	StatusCode int
+	// Body is what the store responded with, which usually says what's
+	// wrong
+	Body string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http code %d - %s", e.StatusCode, e.Body)
+}
+
+// Key returns the key of the object named name, under the Prefix
+func (b *Bucket) Key(name string) string {
+	if b.Prefix == "" {
+		return name
+	}
+	return path.Join(b.Prefix, name)
+}
+
+// objectURL returns the address of the object with the key
+func (b *Bucket) objectURL(key string) string {
+	if b.Endpoint == "" {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", b.Name, b.Region, uriEncode(key, false))
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(b.Endpoint, "/"), b.Name, uriEncode(key, false))
+}
+
+// PutFile uploads the file at filePath as the object named name, and
+// returns its SHA-256, as hex. The store checks the content against its
+// MD5 and SHA-256, so a corrupted upload fails instead of being kept.
+func (b *Bucket) PutFile(ctx context.Context, name, filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sha, md := sha256.New(), md5.New()
+	size, err := io.Copy(io.MultiWriter(sha, md), f)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sum := fmt.Sprintf("%x", sha.Sum(nil))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.objectURL(b.Key(name)), ioutil.NopCloser(f))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType(name))
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md.Sum(nil)))
+	req.Header.Set("X-Amz-Meta-Sha256", sum)
+	b.sign(req, "s3", sum, time.Now())
+
+	client := http.DefaultClient
+	if b.HTTPClient != nil {
+		client = b.HTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return sum, nil
+}
+
+// contentType is the Content-Type of an object, by its name
+func contentType(name string) string {
+	switch path.Ext(name) {
+	case ".json":
+		return "application/json"
+	case ".db":
+		return "application/vnd.sqlite3"
+	case ".zip":
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}